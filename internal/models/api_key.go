@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type (
+	// APIKey is the persisted representation of a merchant API key. Only the
+	// hash of the secret part is stored; the plaintext key is shown once when issued.
+	APIKey struct {
+		ID         string     `json:"id"`
+		MerchantID string     `json:"merchant_id"`
+		Hash       string     `json:"hash,omitempty"`
+		CreatedAt  time.Time  `json:"created_at"`
+		RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	}
+
+	CreateAPIKeyRequest struct {
+		MerchantID string `json:"merchant_id" validate:"required"`
+		Rotate     bool   `json:"rotate"`
+	}
+
+	CreateAPIKeyResponse struct {
+		ID         string `json:"id"`
+		MerchantID string `json:"merchant_id"`
+		Key        string `json:"key"`
+		// SigningSecret is the hex encoded HMAC key used to sign requests made with this key.
+		SigningSecret string    `json:"signing_secret"`
+		CreatedAt     time.Time `json:"created_at"`
+	}
+)
+
+// Active reports whether the key can still be used to authenticate.
+func (k *APIKey) Active() bool {
+	return k.RevokedAt == nil
+}
@@ -2,16 +2,22 @@ package models
 
 type (
 	AuthorizationRequest struct {
-		Mti            string `json:"mti" validate:"required"`
-		ProcessingCode string `json:"processing_code" validate:"required"`
-		Amount         string `json:"amount" validate:"required"`
-		EntryMode      string `json:"entry_mode" validate:"required"`
-		Track2         string `json:"track2" validate:"required"`
-		TerminalID     string `json:"terminal_id" validate:"required"`
-		MerchantID     string `json:"merchant_id" validate:"required"`
+		Mti            string `json:"mti" validate:"required,len=4,numeric"`
+		ProcessingCode string `json:"processing_code" validate:"required,len=6,numeric"`
+		Amount         string `json:"amount" validate:"required,numeric,max=12"`
+		EntryMode      string `json:"entry_mode" validate:"required,len=3,numeric"`
+		Track2         string `json:"track2" validate:"required,max=37"`
+		TerminalID     string `json:"terminal_id" validate:"required,max=8"`
+		MerchantID     string `json:"merchant_id" validate:"required,max=15"`
+		Currency       string `json:"currency,omitempty" validate:"omitempty,len=3,numeric"` // ISO 4217 numeric code, e.g. "986".
+		STAN           string `json:"stan,omitempty" validate:"omitempty,len=6,numeric"`     // System trace audit number of the terminal, generated when empty.
 	}
 
 	AuthorizationResponse struct {
-		ResponseCode string `json:"response_code"`
+		TransactionID string `json:"transaction_id"`
+		ResponseCode  string `json:"response_code"`
+		// STAN and RRN identify the message sent to the gateway, e.g. to look the payment up.
+		STAN string `json:"stan"`
+		RRN  string `json:"rrn"`
 	}
 )
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+const (
+	// BatchOpen is being reconciled with the gateway.
+	BatchOpen BatchStatus = "open"
+	// BatchSettled was in balance with the gateway totals.
+	BatchSettled BatchStatus = "settled"
+	// BatchSettledAfterUpload was out of balance, and in balance once its transactions were uploaded.
+	BatchSettledAfterUpload BatchStatus = "settled_after_upload"
+	// BatchFailed could not be reconciled; the next close of the terminal covers its transactions again.
+	BatchFailed BatchStatus = "failed"
+)
+
+type (
+	BatchStatus string
+
+	// Batch holds the transactions of a terminal settled with one gateway, those
+	// created from the cutoff of the previous settled batch to its own cutoff.
+	// Numbers follow each other per terminal and gateway.
+	Batch struct {
+		ID         string       `json:"id"`
+		Number     string       `json:"number"`
+		MerchantID string       `json:"merchant_id"`
+		TerminalID string       `json:"terminal_id"`
+		Gateway    string       `json:"gateway"`
+		From       time.Time    `json:"from"` // Zero for the first batch of the terminal on the gateway.
+		Cutoff     time.Time    `json:"cutoff"`
+		Totals     []BatchTotal `json:"totals"`
+		Status     BatchStatus  `json:"status"`
+		// ResponseCode answers the first 0500; "95" when the batch was out of balance.
+		ResponseCode string `json:"response_code,omitempty"`
+		// Uploaded counts the transactions sent in 0320 after an out of balance answer.
+		Uploaded           int       `json:"uploaded,omitempty"`
+		UploadResponseCode string    `json:"upload_response_code,omitempty"`
+		CreatedAt          time.Time `json:"created_at"`
+		UpdatedAt          time.Time `json:"updated_at"`
+	}
+
+	// BatchTotal counts the approved transactions of an operation in a batch.
+	BatchTotal struct {
+		Operation string `json:"operation"`
+		Count     int    `json:"count"`
+		Amount    string `json:"amount"`
+	}
+
+	BatchCloseRequest struct {
+		MerchantID string `json:"merchant_id" validate:"required"`
+		TerminalID string `json:"terminal_id" validate:"required"`
+	}
+
+	BatchCloseResponse struct {
+		// Batches lists the batch opened on each gateway holding transactions of the
+		// terminal, reconciled in the background.
+		Batches []*Batch `json:"batches"`
+	}
+)
@@ -0,0 +1,28 @@
+package models
+
+type (
+	// IncrementalAuthorizationRequest adds Amount to the amount held by an approved
+	// pre-authorization, e.g. for a longer hotel stay. The MTI and processing code
+	// are set by the service.
+	IncrementalAuthorizationRequest struct {
+		Amount     string `json:"amount" validate:"required,numeric,max=12"`
+		EntryMode  string `json:"entry_mode" validate:"required,len=3,numeric"`
+		Track2     string `json:"track2" validate:"required,max=37"`
+		TerminalID string `json:"terminal_id" validate:"required,max=8"`
+		MerchantID string `json:"merchant_id" validate:"required,max=15"`
+		Currency   string `json:"currency,omitempty" validate:"omitempty,len=3,numeric"` // The currency of the pre-authorization when empty.
+		STAN       string `json:"stan,omitempty" validate:"omitempty,len=6,numeric"`     // System trace audit number of the terminal, generated when empty.
+		// OriginalTransactionID is the pre-authorization topped up, sent to the gateway that handled it.
+		OriginalTransactionID string `json:"original_transaction_id" validate:"required"`
+	}
+
+	IncrementalAuthorizationResponse struct {
+		TransactionID string `json:"transaction_id"`
+		ResponseCode  string `json:"response_code"`
+		// STAN and RRN identify the message sent to the gateway, e.g. to look the payment up.
+		STAN string `json:"stan"`
+		RRN  string `json:"rrn"`
+		// HeldAmount is the total held by the pre-authorization, this increment included when approved.
+		HeldAmount string `json:"held_amount"`
+	}
+)
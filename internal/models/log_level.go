@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type (
+	// SetLogLevelRequest changes the global level, or the level of Component when set.
+	// With TTL (a Go duration such as "15m") the previous level is restored once it expires.
+	SetLogLevelRequest struct {
+		Level     string `json:"level" validate:"required,oneof=panic fatal error warn warning info debug trace"`
+		Component string `json:"component"`
+		TTL       string `json:"ttl"`
+	}
+
+	LogLevelResponse struct {
+		Level      string                       `json:"level"`
+		RevertAt   *time.Time                   `json:"revert_at,omitempty"`
+		Components map[string]ComponentLogLevel `json:"components"`
+	}
+
+	// ComponentLogLevel is the effective level of a component. Override is false
+	// when it follows the global level.
+	ComponentLogLevel struct {
+		Level    string     `json:"level"`
+		Override bool       `json:"override"`
+		RevertAt *time.Time `json:"revert_at,omitempty"`
+	}
+)
@@ -0,0 +1,106 @@
+package models
+
+import "time"
+
+const (
+	// PaymentAuthorized is an approved pre-authorization holding its amount, not captured yet.
+	PaymentAuthorized PaymentState = "authorized"
+	// PaymentExpired is an approved pre-authorization that was not captured within its window.
+	PaymentExpired PaymentState = "expired"
+	// PaymentCaptured is an approved authorization, or a confirmed pre-authorization.
+	PaymentCaptured PaymentState = "captured"
+	// PaymentPartiallyRefunded and PaymentRefunded had part or all of their captured amount refunded.
+	PaymentPartiallyRefunded PaymentState = "partially_refunded"
+	PaymentRefunded          PaymentState = "refunded"
+	// PaymentCanceled and PaymentReversed were voided by an approved cancellation or reversal.
+	PaymentCanceled PaymentState = "canceled"
+	PaymentReversed PaymentState = "reversed"
+)
+
+type (
+	// PaymentState is the lifecycle state of a payment. Besides the states above,
+	// a payment that was not approved is in the state named after its transaction
+	// status, e.g. "declined" or "unresolved", and a follow-up operation is in the
+	// state named after its own status.
+	PaymentState string
+
+	// Payment is a transaction with the follow-up operations referring to it.
+	Payment struct {
+		TransactionID     string       `json:"transaction_id"`
+		Operation         string       `json:"operation"`
+		State             PaymentState `json:"state"`
+		ResponseCode      string       `json:"response_code,omitempty"`
+		AuthorizationCode string       `json:"authorization_code,omitempty"`
+		MerchantID        string       `json:"merchant_id"`
+		TerminalID        string       `json:"terminal_id"`
+		STAN              string       `json:"stan"`
+		RRN               string       `json:"rrn"`
+		PANLast4          string       `json:"pan_last4,omitempty"`
+		Amount            string       `json:"amount"`
+		Currency          string       `json:"currency,omitempty"`
+		// HeldAmount is the amount held by a pre-authorization, its incremental authorizations included.
+		HeldAmount     string     `json:"held_amount,omitempty"`
+		CapturedAmount string     `json:"captured_amount"`
+		RefundedAmount string     `json:"refunded_amount"`
+		CreatedAt      time.Time  `json:"created_at"`
+		UpdatedAt      time.Time  `json:"updated_at"`
+		ExpiredAt      *time.Time `json:"expired_at,omitempty"`
+		// History lists the transaction and its follow-up operations, oldest first.
+		History []PaymentEvent `json:"history"`
+	}
+
+	// PaymentEvent is one operation of the history of a payment.
+	PaymentEvent struct {
+		TransactionID     string            `json:"transaction_id"`
+		Operation         string            `json:"operation"`
+		Status            TransactionStatus `json:"status"`
+		ResponseCode      string            `json:"response_code,omitempty"`
+		AuthorizationCode string            `json:"authorization_code,omitempty"`
+		Amount            string            `json:"amount"`
+		CreatedAt         time.Time         `json:"created_at"`
+		UpdatedAt         time.Time         `json:"updated_at"`
+	}
+
+	// PaymentSearchRequest holds the filters of a search of the transactions of the
+	// authenticated merchant. Empty filters match every transaction.
+	PaymentSearchRequest struct {
+		MerchantID   string
+		TerminalID   string
+		Statuses     []string  `validate:"dive,oneof=pending approved declined failed unresolved reversed"`
+		Operations   []string  `validate:"dive,oneof=authorization pre_authorization incremental_authorization confirmation cancellation reversal refund"`
+		From         time.Time // Inclusive.
+		To           time.Time `validate:"omitempty,gtfield=From"` // Exclusive.
+		MinAmount    string    `validate:"omitempty,numeric,max=12"`
+		MaxAmount    string    `validate:"omitempty,numeric,max=12"`
+		PANLast4     string    `validate:"omitempty,len=4,numeric"`
+		ResponseCode string    `validate:"omitempty,len=2"`
+		Sort         string    `validate:"omitempty,oneof=created_at -created_at amount -amount"` // "-created_at" when empty.
+		Cursor       string
+		Limit        int `validate:"gte=0,lte=500"` // 50 when zero.
+	}
+
+	// PaymentPage is a page of the transactions found by a search.
+	PaymentPage struct {
+		Transactions []PaymentSummary `json:"transactions"`
+		NextCursor   string           `json:"next_cursor,omitempty"`
+	}
+
+	// PaymentSummary is a transaction found by a search.
+	PaymentSummary struct {
+		TransactionID         string            `json:"transaction_id"`
+		Operation             string            `json:"operation"`
+		Status                TransactionStatus `json:"status"`
+		ResponseCode          string            `json:"response_code,omitempty"`
+		AuthorizationCode     string            `json:"authorization_code,omitempty"`
+		MerchantID            string            `json:"merchant_id"`
+		TerminalID            string            `json:"terminal_id"`
+		STAN                  string            `json:"stan"`
+		RRN                   string            `json:"rrn"`
+		PANLast4              string            `json:"pan_last4,omitempty"`
+		Amount                string            `json:"amount"`
+		Currency              string            `json:"currency,omitempty"`
+		OriginalTransactionID string            `json:"original_transaction_id,omitempty"`
+		CreatedAt             time.Time         `json:"created_at"`
+		UpdatedAt             time.Time         `json:"updated_at"`
+	}
+)
@@ -0,0 +1,30 @@
+package models
+
+type (
+	// RefundRequest returns part or all of the captured amount of an approved
+	// authorization or confirmed pre-authorization. The MTI and processing code are
+	// set by the service.
+	RefundRequest struct {
+		Amount     string `json:"amount" validate:"required,numeric,max=12"`
+		EntryMode  string `json:"entry_mode" validate:"required,len=3,numeric"`
+		Track2     string `json:"track2" validate:"required,max=37"`
+		TerminalID string `json:"terminal_id" validate:"required,max=8"`
+		MerchantID string `json:"merchant_id" validate:"required,max=15"`
+		Currency   string `json:"currency,omitempty" validate:"omitempty,len=3,numeric"` // The currency of the original transaction when empty.
+		STAN       string `json:"stan,omitempty" validate:"omitempty,len=6,numeric"`     // System trace audit number of the terminal, generated when empty.
+		// OriginalTransactionID is the payment refunded, sent to the gateway that handled it.
+		OriginalTransactionID string `json:"original_transaction_id" validate:"required"`
+	}
+
+	RefundResponse struct {
+		TransactionID string `json:"transaction_id"`
+		ResponseCode  string `json:"response_code"`
+		// STAN and RRN identify the message sent to the gateway, e.g. to look the payment up.
+		STAN string `json:"stan"`
+		RRN  string `json:"rrn"`
+		// RefundedAmount is the total refunded from the payment, this refund included when approved.
+		RefundedAmount string `json:"refunded_amount"`
+		// RefundableAmount is the captured amount left to refund.
+		RefundableAmount string `json:"refundable_amount"`
+	}
+)
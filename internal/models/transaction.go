@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+const (
+	// TransactionPending is sent to the gateway and waiting for its response.
+	TransactionPending TransactionStatus = "pending"
+	// TransactionApproved and TransactionDeclined got a response, with response code 00 or not.
+	TransactionApproved TransactionStatus = "approved"
+	TransactionDeclined TransactionStatus = "declined"
+	// TransactionFailed never reached the gateway or got no response while serving.
+	TransactionFailed TransactionStatus = "failed"
+	// TransactionUnresolved was still pending when the server stopped; a reversal is queued.
+	TransactionUnresolved TransactionStatus = "unresolved"
+	// TransactionReversed was unresolved and its reversal was acknowledged by the gateway.
+	TransactionReversed TransactionStatus = "reversed"
+)
+
+type (
+	TransactionStatus string
+
+	// Transaction is a financial message exchanged with the gateway. The card data
+	// (track 2) is never persisted, only the last four digits of the card number.
+	Transaction struct {
+		ID                   string            `json:"id"`
+		Operation            string            `json:"operation"`
+		MTI                  string            `json:"mti"`
+		ProcessingCode       string            `json:"processing_code"`
+		Amount               string            `json:"amount"`
+		Currency             string            `json:"currency,omitempty"`
+		EntryMode            string            `json:"entry_mode"`
+		TerminalID           string            `json:"terminal_id"`
+		MerchantID           string            `json:"merchant_id"`
+		PANLast4             string            `json:"pan_last4,omitempty"`
+		MCC                  string            `json:"mcc,omitempty"`
+		STAN                 string            `json:"stan"`
+		RRN                  string            `json:"rrn"`
+		TransmissionDateTime string            `json:"transmission_date_time"`
+		Status               TransactionStatus `json:"status"`
+		ResponseCode         string            `json:"response_code,omitempty"`
+		AuthorizationCode    string            `json:"authorization_code,omitempty"`
+		// Gateway names the gateway the transaction was sent to, which its follow-ups
+		// and its reversal go to as well. Empty for the transactions recorded before
+		// the routing, which belong to the default gateway.
+		Gateway string `json:"gateway,omitempty"`
+		// OriginalTransactionID is the transaction a follow-up operation refers to.
+		OriginalTransactionID string `json:"original_transaction_id,omitempty"`
+		// ReversalResponseCode is the response code of the reversal of an unresolved transaction.
+		ReversalResponseCode string `json:"reversal_response_code,omitempty"`
+		// ExpiredAt is when an approved pre-authorization left uncaptured expired.
+		ExpiredAt *time.Time `json:"expired_at,omitempty"`
+		CreatedAt time.Time  `json:"created_at"`
+		UpdatedAt time.Time  `json:"updated_at"`
+	}
+)
@@ -0,0 +1,290 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
+
+// racyWindow is how recent a modification time must be to not be trusted as a
+// sign the file is unchanged, wider than the timestamp granularity of common
+// file systems.
+const racyWindow = time.Second
+
+type (
+	APIKeyRepository interface {
+		Update(ctx context.Context, key *models.APIKey) error
+		FindByID(ctx context.Context, id string) (*models.APIKey, error)
+		ListByMerchant(ctx context.Context, merchantID string) ([]*models.APIKey, error)
+		// UpdateMerchant passes the keys of the merchant to fn and stores the keys
+		// it returns, new or changed, with no other write in between.
+		UpdateMerchant(ctx context.Context, merchantID string, fn func(keys []*models.APIKey) ([]*models.APIKey, error)) error
+		// Ping reports whether the store can be read and written.
+		Ping(ctx context.Context) error
+	}
+
+	// fileAPIKeyRepository keeps the keys in a JSON file so the HTTP server and the
+	// CLI share the same store. The file is reloaded whenever its modification time
+	// changes, which lets keys issued from the CLI take effect without a restart.
+	// Writes hold an exclusive lock on a sidecar file, so the server and the CLI
+	// never overwrite each other's changes.
+	fileAPIKeyRepository struct {
+		mu      sync.RWMutex
+		path    string
+		modTime time.Time
+		keys    map[string]*models.APIKey
+	}
+)
+
+func NewFileAPIKeyRepository(path string) (APIKeyRepository, error) {
+	repo := &fileAPIKeyRepository{path: path, keys: map[string]*models.APIKey{}}
+
+	if err := repo.reload(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *fileAPIKeyRepository) Update(ctx context.Context, key *models.APIKey) (err error) {
+	_, span := tracing.Start(ctx, "repositories.APIKeyRepository.Update")
+	defer tracing.End(span, &err)
+
+	return r.write(func() error {
+		if _, ok := r.keys[key.ID]; !ok {
+			return ErrNotFound
+		}
+
+		copied := *key
+		r.keys[key.ID] = &copied
+		return nil
+	})
+}
+
+func (r *fileAPIKeyRepository) UpdateMerchant(ctx context.Context, merchantID string, fn func(keys []*models.APIKey) ([]*models.APIKey, error)) (err error) {
+	_, span := tracing.Start(ctx, "repositories.APIKeyRepository.UpdateMerchant")
+	defer tracing.End(span, &err)
+
+	return r.write(func() error {
+		changed, err := fn(r.listLocked(merchantID))
+		if err != nil {
+			return err
+		}
+
+		for _, key := range changed {
+			copied := *key
+			r.keys[key.ID] = &copied
+		}
+		return nil
+	})
+}
+
+func (r *fileAPIKeyRepository) FindByID(ctx context.Context, id string) (_ *models.APIKey, err error) {
+	_, span := tracing.Start(ctx, "repositories.APIKeyRepository.FindByID")
+	defer tracing.End(span, &err)
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	copied := *key
+	return &copied, nil
+}
+
+func (r *fileAPIKeyRepository) ListByMerchant(ctx context.Context, merchantID string) (_ []*models.APIKey, err error) {
+	_, span := tracing.Start(ctx, "repositories.APIKeyRepository.ListByMerchant")
+	defer tracing.End(span, &err)
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.listLocked(merchantID), nil
+}
+
+// listLocked returns copies of the keys of the merchant, oldest first.
+func (r *fileAPIKeyRepository) listLocked(merchantID string) []*models.APIKey {
+	keys := []*models.APIKey{}
+	for _, key := range r.keys {
+		if key.MerchantID == merchantID {
+			copied := *key
+			keys = append(keys, &copied)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	return keys
+}
+
+// write re-reads the store, applies fn and persists the result while holding
+// the lock file, so that a key issued from the CLI while the server rotates
+// another one is never lost. The file is read again even when its modification
+// time looks unchanged, since it may have been replaced within its resolution.
+func (r *fileAPIKeyRepository) write(fn func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lock, err := os.OpenFile(r.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	// Closing the file releases the lock.
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	r.modTime = time.Time{}
+	if err := r.reloadLocked(); err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return r.persistLocked()
+}
+
+// Ping re-reads the file when it changed and checks its directory is writable,
+// since issuing a key replaces the whole file.
+func (r *fileAPIKeyRepository) Ping(ctx context.Context) error {
+	if err := r.reload(); err != nil {
+		return err
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(r.path), ".api-keys-ping-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+
+	return os.Remove(probe.Name())
+}
+
+func (r *fileAPIKeyRepository) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reloadLocked()
+}
+
+// reloadLocked re-reads the file when it changed since the last read. A missing
+// file is treated as an empty store.
+func (r *fileAPIKeyRepository) reloadLocked() error {
+	info, err := os.Stat(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.ModTime().Equal(r.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	var stored []*models.APIKey
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+	}
+
+	keys := make(map[string]*models.APIKey, len(stored))
+	for _, key := range stored {
+		keys[key.ID] = key
+	}
+
+	r.keys = keys
+	r.remember(info)
+
+	logger.Component(context.Background(), logger.ComponentRepository).WithField("keys", len(keys)).Debug("api keys file reloaded")
+
+	return nil
+}
+
+// persistLocked writes the whole store to a temporary file and renames it over
+// the original so readers never observe a partially written file.
+func (r *fileAPIKeyRepository) persistLocked() error {
+	stored := make([]*models.APIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		stored = append(stored, key)
+	}
+
+	sort.Slice(stored, func(i, j int) bool { return stored[i].ID < stored[j].ID })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.remember(info)
+	}
+
+	return nil
+}
+
+// remember records the modification time of the file just read or written. A
+// time within racyWindow of now is not kept: another process may write in the
+// same clock tick without changing it, so the next read reloads instead.
+func (r *fileAPIKeyRepository) remember(info fs.FileInfo) {
+	r.modTime = time.Time{}
+	if time.Since(info.ModTime()) > racyWindow {
+		r.modTime = info.ModTime()
+	}
+}
@@ -0,0 +1,254 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
+
+type (
+	BatchRepository interface {
+		Create(ctx context.Context, batch *models.Batch) error
+		Update(ctx context.Context, batch *models.Batch) error
+		// ListByTerminal returns the batches of the terminal, newest first.
+		ListByTerminal(ctx context.Context, merchantID, terminalID string) ([]*models.Batch, error)
+		// ListByStatus returns the batches in status, oldest first.
+		ListByStatus(ctx context.Context, status models.BatchStatus) ([]*models.Batch, error)
+		// Ping reports whether the store can be written.
+		Ping(ctx context.Context) error
+		Close() error
+	}
+
+	// fileBatchRepository appends every version of a batch to a JSON lines file and
+	// keeps the latest versions in memory, like the transaction store.
+	fileBatchRepository struct {
+		mu         sync.RWMutex
+		path       string
+		file       *os.File
+		batches    map[string]*models.Batch
+		byTerminal map[string][]string
+	}
+)
+
+func NewFileBatchRepository(path string) (BatchRepository, error) {
+	repo := &fileBatchRepository{path: path, batches: map[string]*models.Batch{}, byTerminal: map[string][]string{}}
+
+	if err := repo.load(); err != nil {
+		return nil, err
+	}
+
+	if err := repo.compact(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	repo.file = file
+
+	return repo, nil
+}
+
+func (r *fileBatchRepository) Create(ctx context.Context, batch *models.Batch) (err error) {
+	_, span := tracing.Start(ctx, "repositories.BatchRepository.Create")
+	defer tracing.End(span, &err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.batches[batch.ID]; ok {
+		return ErrConflict
+	}
+
+	return r.appendLocked(batch)
+}
+
+func (r *fileBatchRepository) Update(ctx context.Context, batch *models.Batch) (err error) {
+	_, span := tracing.Start(ctx, "repositories.BatchRepository.Update")
+	defer tracing.End(span, &err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.batches[batch.ID]; !ok {
+		return ErrNotFound
+	}
+
+	return r.appendLocked(batch)
+}
+
+func (r *fileBatchRepository) ListByTerminal(ctx context.Context, merchantID, terminalID string) (_ []*models.Batch, err error) {
+	_, span := tracing.Start(ctx, "repositories.BatchRepository.ListByTerminal")
+	defer tracing.End(span, &err)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	batches := []*models.Batch{}
+	for _, id := range r.byTerminal[terminalKey(merchantID, terminalID)] {
+		copied := *r.batches[id]
+		batches = append(batches, &copied)
+	}
+
+	sort.Slice(batches, func(i, j int) bool { return batches[i].CreatedAt.After(batches[j].CreatedAt) })
+
+	return batches, nil
+}
+
+func (r *fileBatchRepository) ListByStatus(ctx context.Context, status models.BatchStatus) (_ []*models.Batch, err error) {
+	_, span := tracing.Start(ctx, "repositories.BatchRepository.ListByStatus")
+	defer tracing.End(span, &err)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	batches := []*models.Batch{}
+	for _, batch := range r.batches {
+		if batch.Status == status {
+			copied := *batch
+			batches = append(batches, &copied)
+		}
+	}
+
+	sort.Slice(batches, func(i, j int) bool { return batches[i].CreatedAt.Before(batches[j].CreatedAt) })
+
+	return batches, nil
+}
+
+func (r *fileBatchRepository) Ping(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+
+	_, err := r.file.Stat()
+	return err
+}
+
+func (r *fileBatchRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := errors.Join(r.file.Sync(), r.file.Close())
+	r.file = nil
+
+	return err
+}
+
+// appendLocked writes batch as a new line and syncs it.
+func (r *fileBatchRepository) appendLocked(batch *models.Batch) error {
+	if r.file == nil {
+		return os.ErrClosed
+	}
+
+	line, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	if err := r.file.Sync(); err != nil {
+		return err
+	}
+
+	copied := *batch
+	r.store(&copied)
+
+	return nil
+}
+
+// store keeps batch as the latest version of its batch and indexes it the first
+// time it is seen.
+func (r *fileBatchRepository) store(batch *models.Batch) {
+	if _, ok := r.batches[batch.ID]; !ok {
+		key := terminalKey(batch.MerchantID, batch.TerminalID)
+		r.byTerminal[key] = append(r.byTerminal[key], batch.ID)
+	}
+
+	r.batches[batch.ID] = batch
+}
+
+// load replays the file; later lines of a batch replace the earlier ones. A
+// truncated last line, left by a crash during a write, is ignored.
+func (r *fileBatchRepository) load() error {
+	file, err := os.Open(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var batch models.Batch
+		if err := json.Unmarshal(scanner.Bytes(), &batch); err != nil {
+			logger.Component(context.Background(), logger.ComponentRepository).WithError(err).Warn("skipping unreadable batch line")
+			continue
+		}
+
+		r.store(&batch)
+	}
+
+	return scanner.Err()
+}
+
+// compact rewrites the file with the latest version of every batch.
+func (r *fileBatchRepository) compact() error {
+	stored := make([]*models.Batch, 0, len(r.batches))
+	for _, batch := range r.batches {
+		stored = append(stored, batch)
+	}
+
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt.Before(stored[j].CreatedAt) })
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+
+	for _, batch := range stored {
+		if err := encoder.Encode(batch); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	if err := errors.Join(writer.Flush(), tmp.Chmod(0o600), tmp.Sync()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), r.path)
+}
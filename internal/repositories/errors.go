@@ -0,0 +1,13 @@
+package repositories
+
+import "errors"
+
+// ErrNotFound is returned by repositories when the requested record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned when a record is not in the state an update expects.
+var ErrConflict = errors.New("record was modified concurrently")
+
+// ErrInvalidCursor is returned when a pagination cursor is malformed or comes from
+// a query sorted in another order.
+var ErrInvalidCursor = errors.New("invalid cursor")
@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+)
+
+const (
+	SortByCreatedAt TransactionSortField = "created_at"
+	SortByAmount    TransactionSortField = "amount"
+)
+
+type (
+	TransactionSortField string
+
+	// TransactionQuery selects transactions of one merchant. Zero fields other than
+	// MerchantID do not filter.
+	TransactionQuery struct {
+		MerchantID   string
+		TerminalID   string
+		Statuses     []models.TransactionStatus
+		Operations   []string
+		CreatedFrom  time.Time // Inclusive.
+		CreatedTo    time.Time // Exclusive.
+		MinAmount    int64
+		MaxAmount    int64 // No upper bound when zero.
+		PANLast4     string
+		ResponseCode string
+		SortBy       TransactionSortField // SortByCreatedAt when empty.
+		Descending   bool
+		Cursor       string // NextCursor of the previous page, empty for the first page.
+		Limit        int
+	}
+
+	// TransactionPage is a page of the transactions matching a query. NextCursor is
+	// empty on the last page.
+	TransactionPage struct {
+		Transactions []*models.Transaction
+		NextCursor   string
+	}
+
+	// cursor is the position of the last transaction of a page in its sort order.
+	cursor struct {
+		Sort string `json:"s"`
+		Key  int64  `json:"k"`
+		ID   string `json:"i"`
+	}
+
+	indexEntry struct {
+		createdAt time.Time
+		id        string
+	}
+
+	// timeIndex holds transaction IDs ordered by creation time.
+	timeIndex []indexEntry
+)
+
+// insert adds id, keeping the index ordered; transactions are mostly created in
+// order, so the position is searched from the end.
+func (idx timeIndex) insert(createdAt time.Time, id string) timeIndex {
+	i := len(idx)
+	for i > 0 && idx[i-1].createdAt.After(createdAt) {
+		i--
+	}
+
+	return slices.Insert(idx, i, indexEntry{createdAt, id})
+}
+
+// between returns the entries created in [from, to), a zero bound being open.
+func (idx timeIndex) between(from, to time.Time) timeIndex {
+	start, end := 0, len(idx)
+
+	if !from.IsZero() {
+		start = sort.Search(len(idx), func(i int) bool { return !idx[i].createdAt.Before(from) })
+	}
+	if !to.IsZero() {
+		end = sort.Search(len(idx), func(i int) bool { return !idx[i].createdAt.Before(to) })
+	}
+	if start > end {
+		return nil
+	}
+
+	return idx[start:end]
+}
+
+// matches applies the filters not answered by the indexes.
+func (q TransactionQuery) matches(tx *models.Transaction) bool {
+	amount, _ := strconv.ParseInt(tx.Amount, 10, 64)
+
+	switch {
+	case tx.MerchantID != q.MerchantID:
+		return false
+	case q.TerminalID != "" && tx.TerminalID != q.TerminalID:
+		return false
+	case len(q.Statuses) > 0 && !slices.Contains(q.Statuses, tx.Status):
+		return false
+	case len(q.Operations) > 0 && !slices.Contains(q.Operations, tx.Operation):
+		return false
+	case amount < q.MinAmount, q.MaxAmount > 0 && amount > q.MaxAmount:
+		return false
+	case q.PANLast4 != "" && tx.PANLast4 != q.PANLast4:
+		return false
+	case q.ResponseCode != "" && tx.ResponseCode != q.ResponseCode:
+		return false
+	}
+
+	return true
+}
+
+// order names the sort order of q, which a cursor is bound to.
+func (q TransactionQuery) order() string {
+	field := q.SortBy
+	if field == "" {
+		field = SortByCreatedAt
+	}
+
+	if q.Descending {
+		return string(field) + ":desc"
+	}
+
+	return string(field) + ":asc"
+}
+
+// key returns the sort key of tx in the order of q.
+func (q TransactionQuery) key(tx *models.Transaction) int64 {
+	if q.SortBy == SortByAmount {
+		amount, _ := strconv.ParseInt(tx.Amount, 10, 64)
+		return amount
+	}
+
+	return tx.CreatedAt.UnixNano()
+}
+
+// before reports whether a sorts before b in the order of q, ties broken by ID.
+func (q TransactionQuery) before(a, b cursor) bool {
+	if a == b {
+		return false
+	}
+	if a.Key == b.Key {
+		return a.ID < b.ID != q.Descending
+	}
+
+	return a.Key < b.Key != q.Descending
+}
+
+func (q TransactionQuery) position(tx *models.Transaction) cursor {
+	return cursor{Sort: q.order(), Key: q.key(tx), ID: tx.ID}
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads the cursor of a page of q, rejecting the cursors of other orders.
+func (q TransactionQuery) decodeCursor(value string) (cursor, error) {
+	var c cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.Sort != q.order() {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
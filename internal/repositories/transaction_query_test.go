@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tx := &models.Transaction{ID: "tx1", Amount: "000000001500", CreatedAt: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name    string
+		query   TransactionQuery
+		wantKey int64
+	}{
+		{"default order", TransactionQuery{}, tx.CreatedAt.UnixNano()},
+		{"created at descending", TransactionQuery{SortBy: SortByCreatedAt, Descending: true}, tx.CreatedAt.UnixNano()},
+		{"amount ascending", TransactionQuery{SortBy: SortByAmount}, 1500},
+		{"amount descending", TransactionQuery{SortBy: SortByAmount, Descending: true}, 1500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := tt.query.position(tx)
+			if position.Key != tt.wantKey || position.ID != tx.ID {
+				t.Fatalf("position = %+v, want key %d and id %s", position, tt.wantKey, tx.ID)
+			}
+
+			decoded, err := tt.query.decodeCursor(encodeCursor(position))
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if decoded != position {
+				t.Fatalf("decoded = %+v, want %+v", decoded, position)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsForeignCursors(t *testing.T) {
+	byDate := TransactionQuery{SortBy: SortByCreatedAt, Descending: true}
+	position := byDate.position(&models.Transaction{ID: "tx1", Amount: "100", CreatedAt: time.Now()})
+
+	tests := []struct {
+		name   string
+		query  TransactionQuery
+		cursor string
+	}{
+		{"not base64", byDate, "%%%"},
+		{"not json", byDate, base64.RawURLEncoding.EncodeToString([]byte("not json"))},
+		{"padded base64", byDate, base64.URLEncoding.EncodeToString([]byte(`{"s":"created_at:desc","k":1,"i":"x"}`))},
+		{"other field", TransactionQuery{SortBy: SortByAmount, Descending: true}, encodeCursor(position)},
+		{"other direction", TransactionQuery{SortBy: SortByCreatedAt}, encodeCursor(position)},
+		{"default order is ascending", TransactionQuery{}, encodeCursor(position)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.query.decodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestCursorBefore(t *testing.T) {
+	tests := []struct {
+		name       string
+		descending bool
+		a, b       cursor
+		want       bool
+	}{
+		{"lower key first ascending", false, cursor{Key: 1, ID: "b"}, cursor{Key: 2, ID: "a"}, true},
+		{"higher key first descending", true, cursor{Key: 2, ID: "a"}, cursor{Key: 1, ID: "b"}, true},
+		{"tie broken by id ascending", false, cursor{Key: 1, ID: "a"}, cursor{Key: 1, ID: "b"}, true},
+		{"tie broken by id descending", true, cursor{Key: 1, ID: "b"}, cursor{Key: 1, ID: "a"}, true},
+		{"same position", false, cursor{Key: 1, ID: "a"}, cursor{Key: 1, ID: "a"}, false},
+		{"same position descending", true, cursor{Key: 1, ID: "a"}, cursor{Key: 1, ID: "a"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (TransactionQuery{Descending: tt.descending}).before(tt.a, tt.b); got != tt.want {
+				t.Fatalf("before = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchPagesThroughEveryTransaction(t *testing.T) {
+	repo, err := NewFileTransactionRepository(filepath.Join(t.TempDir(), "transactions.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	// Amounts repeat and two transactions share a creation time, so ties must be
+	// broken by ID for every page to continue where the previous one stopped.
+	amounts := []string{"000000000300", "000000000100", "000000000300", "000000000200", "000000000100", "000000000300", "000000000200"}
+	for i, amount := range amounts {
+		createdAt := start.Add(time.Duration(i) * time.Second)
+		if i == 4 {
+			createdAt = start.Add(3 * time.Second)
+		}
+
+		tx := &models.Transaction{ID: fmt.Sprintf("tx%d", i), MerchantID: "M1", TerminalID: "T1", Amount: amount, CreatedAt: createdAt}
+		if err := repo.Create(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Create(ctx, &models.Transaction{ID: "other", MerchantID: "M2", Amount: "000000000100", CreatedAt: start}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query TransactionQuery
+		want  []string
+	}{
+		{"created at ascending", TransactionQuery{SortBy: SortByCreatedAt}, []string{"tx0", "tx1", "tx2", "tx3", "tx4", "tx5", "tx6"}},
+		{"created at descending", TransactionQuery{SortBy: SortByCreatedAt, Descending: true}, []string{"tx6", "tx5", "tx4", "tx3", "tx2", "tx1", "tx0"}},
+		{"amount ascending", TransactionQuery{SortBy: SortByAmount}, []string{"tx1", "tx4", "tx3", "tx6", "tx0", "tx2", "tx5"}},
+		{"amount descending", TransactionQuery{SortBy: SortByAmount, Descending: true}, []string{"tx5", "tx2", "tx0", "tx6", "tx3", "tx4", "tx1"}},
+		{"filtered by amount", TransactionQuery{SortBy: SortByAmount, MinAmount: 200, MaxAmount: 200}, []string{"tx3", "tx6"}},
+	}
+
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 10} {
+			t.Run(fmt.Sprintf("%s limit %d", tt.name, limit), func(t *testing.T) {
+				query := tt.query
+				query.MerchantID, query.Limit = "M1", limit
+
+				var got []string
+				for pages := 0; ; pages++ {
+					if pages > len(amounts) {
+						t.Fatalf("paging did not end, got %v", got)
+					}
+
+					page, err := repo.Search(ctx, query)
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, tx := range page.Transactions {
+						got = append(got, tx.ID)
+					}
+					if page.NextCursor == "" {
+						break
+					}
+					query.Cursor = page.NextCursor
+				}
+
+				if !slices.Equal(got, tt.want) {
+					t.Fatalf("ids = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
+
+type (
+	TransactionRepository interface {
+		Create(ctx context.Context, tx *models.Transaction) error
+		// Update replaces the stored transaction when its current status is one of
+		// from, and returns ErrConflict otherwise.
+		Update(ctx context.Context, tx *models.Transaction, from ...models.TransactionStatus) error
+		FindByID(ctx context.Context, id string) (*models.Transaction, error)
+		// FindBySTAN returns the transaction of the merchant's terminal with the STAN
+		// created on the UTC date of day, the latest one when the STAN was reused
+		// that day.
+		FindBySTAN(ctx context.Context, merchantID, terminalID, stan string, day time.Time) (*models.Transaction, error)
+		// LatestSTAN returns the STAN of the most recently created transaction, empty
+		// when there is none, so the STAN sequence can resume after a restart.
+		LatestSTAN(ctx context.Context) (string, error)
+		// ListFollowUps returns the transactions referring to originalID, oldest first.
+		ListFollowUps(ctx context.Context, originalID string) ([]*models.Transaction, error)
+		// Search returns a page of the transactions matching query, and ErrInvalidCursor
+		// when its cursor does not come from a previous page in the same order.
+		Search(ctx context.Context, query TransactionQuery) (*TransactionPage, error)
+		ListByStatus(ctx context.Context, status models.TransactionStatus) ([]*models.Transaction, error)
+		// Ping reports whether the store can be written.
+		Ping(ctx context.Context) error
+		Close() error
+	}
+
+	// fileTransactionRepository appends every version of a transaction to a JSON
+	// lines file and keeps the latest versions in memory. The file is compacted to
+	// one line per transaction when opened.
+	fileTransactionRepository struct {
+		mu           sync.RWMutex
+		path         string
+		file         *os.File
+		transactions map[string]*models.Transaction
+
+		// The indexes hold transaction IDs by original transaction ID, by terminal
+		// ID and STAN, and by merchant and terminal in creation order. The indexed
+		// fields never change once a transaction is created.
+		followUps  map[string][]string
+		bySTAN     map[string][]string
+		byMerchant map[string]timeIndex
+		byTerminal map[string]timeIndex
+	}
+)
+
+func NewFileTransactionRepository(path string) (TransactionRepository, error) {
+	repo := &fileTransactionRepository{
+		path:         path,
+		transactions: map[string]*models.Transaction{},
+		followUps:    map[string][]string{},
+		bySTAN:       map[string][]string{},
+		byMerchant:   map[string]timeIndex{},
+		byTerminal:   map[string]timeIndex{},
+	}
+
+	if err := repo.load(); err != nil {
+		return nil, err
+	}
+
+	if err := repo.compact(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	repo.file = file
+
+	return repo, nil
+}
+
+func (r *fileTransactionRepository) Create(ctx context.Context, tx *models.Transaction) (err error) {
+	_, span := tracing.Start(ctx, "repositories.TransactionRepository.Create")
+	defer tracing.End(span, &err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.transactions[tx.ID]; ok {
+		return ErrConflict
+	}
+
+	return r.appendLocked(tx)
+}
+
+func (r *fileTransactionRepository) Update(ctx context.Context, tx *models.Transaction, from ...models.TransactionStatus) (err error) {
+	_, span := tracing.Start(ctx, "repositories.TransactionRepository.Update")
+	defer tracing.End(span, &err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.transactions[tx.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if !slices.Contains(from, stored.Status) {
+		return ErrConflict
+	}
+
+	return r.appendLocked(tx)
+}
+
+func (r *fileTransactionRepository) FindByID(ctx context.Context, id string) (_ *models.Transaction, err error) {
+	_, span := tracing.Start(ctx, "repositories.TransactionRepository.FindByID")
+	defer tracing.End(span, &err)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tx, ok := r.transactions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	copied := *tx
+	return &copied, nil
+}
+
+func (r *fileTransactionRepository) FindBySTAN(ctx context.Context, merchantID, terminalID, stan string, day time.Time) (_ *models.Transaction, err error) {
+	_, span := tracing.Start(ctx, "repositories.TransactionRepository.FindBySTAN")
+	defer tracing.End(span, &err)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	year, month, date := day.UTC().Date()
+
+	var found *models.Transaction
+	for _, id := range r.bySTAN[stanKey(merchantID, terminalID, stan)] {
+		tx := r.transactions[id]
+
+		y, m, d := tx.CreatedAt.UTC().Date()
+		if y != year || m != month || d != date {
+			continue
+		}
+		if found == nil || tx.CreatedAt.After(found.CreatedAt) {
+			found = tx
+		}
+	}
+
+	if found == nil {
+		return nil, ErrNotFound
+	}
+
+	copied := *found
+	return &copied, nil
+}
+
+func (r *fileTransactionRepository) ListFollowUps(ctx context.Context, originalID string) (_ []*models.Transaction, err error) {
+	_, span := tracing.Start(ctx, "repositories.TransactionRepository.ListFollowUps")
+	defer tracing.End(span, &err)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	transactions := []*models.Transaction{}
+	for _, id := range r.followUps[originalID] {
+		copied := *r.transactions[id]
+		transactions = append(transactions, &copied)
+	}
+
+	sort.Slice(transactions, func(i, j int) bool { return transactions[i].CreatedAt.Before(transactions[j].CreatedAt) })
+
+	return transactions, nil
+}
+
+func (r *fileTransactionRepository) Search(ctx context.Context, query TransactionQuery) (_ *TransactionPage, err error) {
+	_, span := tracing.Start(ctx, "repositories.TransactionRepository.Search")
+	defer tracing.End(span, &err)
+
+	var after *cursor
+	if query.Cursor != "" {
+		position, err := query.decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &position
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	index := r.byMerchant[query.MerchantID]
+	if query.TerminalID != "" {
+		index = r.byTerminal[terminalKey(query.MerchantID, query.TerminalID)]
+	}
+
+	matched := []*models.Transaction{}
+	for _, entry := range index.between(query.CreatedFrom, query.CreatedTo) {
+		tx := r.transactions[entry.id]
+		if !query.matches(tx) {
+			continue
+		}
+		if after != nil && !query.before(*after, query.position(tx)) {
+			continue
+		}
+
+		matched = append(matched, tx)
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return query.before(query.position(matched[i]), query.position(matched[j])) })
+
+	page := &TransactionPage{Transactions: make([]*models.Transaction, 0, min(len(matched), query.Limit))}
+	if query.Limit > 0 && len(matched) > query.Limit {
+		matched = matched[:query.Limit]
+		page.NextCursor = encodeCursor(query.position(matched[len(matched)-1]))
+	}
+
+	for _, tx := range matched {
+		copied := *tx
+		page.Transactions = append(page.Transactions, &copied)
+	}
+
+	return page, nil
+}
+
+func (r *fileTransactionRepository) ListByStatus(ctx context.Context, status models.TransactionStatus) (_ []*models.Transaction, err error) {
+	_, span := tracing.Start(ctx, "repositories.TransactionRepository.ListByStatus")
+	defer tracing.End(span, &err)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	transactions := []*models.Transaction{}
+	for _, tx := range r.transactions {
+		if tx.Status == status {
+			copied := *tx
+			transactions = append(transactions, &copied)
+		}
+	}
+
+	sort.Slice(transactions, func(i, j int) bool { return transactions[i].CreatedAt.Before(transactions[j].CreatedAt) })
+
+	return transactions, nil
+}
+
+func (r *fileTransactionRepository) LatestSTAN(ctx context.Context) (_ string, err error) {
+	_, span := tracing.Start(ctx, "repositories.TransactionRepository.LatestSTAN")
+	defer tracing.End(span, &err)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest *models.Transaction
+	for _, tx := range r.transactions {
+		if latest == nil || tx.CreatedAt.After(latest.CreatedAt) {
+			latest = tx
+		}
+	}
+
+	if latest == nil {
+		return "", nil
+	}
+
+	return latest.STAN, nil
+}
+
+func (r *fileTransactionRepository) Ping(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+
+	_, err := r.file.Stat()
+	return err
+}
+
+func (r *fileTransactionRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := errors.Join(r.file.Sync(), r.file.Close())
+	r.file = nil
+
+	return err
+}
+
+// appendLocked writes tx as a new line and syncs it, so a crash never loses the
+// state of a transaction sent to the gateway.
+func (r *fileTransactionRepository) appendLocked(tx *models.Transaction) error {
+	if r.file == nil {
+		return os.ErrClosed
+	}
+
+	line, err := json.Marshal(tx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	if err := r.file.Sync(); err != nil {
+		return err
+	}
+
+	copied := *tx
+	r.store(&copied)
+
+	return nil
+}
+
+// store keeps tx as the latest version of its transaction and indexes it the
+// first time it is seen.
+func (r *fileTransactionRepository) store(tx *models.Transaction) {
+	if _, ok := r.transactions[tx.ID]; !ok {
+		if tx.OriginalTransactionID != "" {
+			r.followUps[tx.OriginalTransactionID] = append(r.followUps[tx.OriginalTransactionID], tx.ID)
+		}
+
+		key := stanKey(tx.MerchantID, tx.TerminalID, tx.STAN)
+		r.bySTAN[key] = append(r.bySTAN[key], tx.ID)
+
+		r.byMerchant[tx.MerchantID] = r.byMerchant[tx.MerchantID].insert(tx.CreatedAt, tx.ID)
+
+		key = terminalKey(tx.MerchantID, tx.TerminalID)
+		r.byTerminal[key] = r.byTerminal[key].insert(tx.CreatedAt, tx.ID)
+	}
+
+	r.transactions[tx.ID] = tx
+}
+
+func stanKey(merchantID, terminalID, stan string) string {
+	return merchantID + "/" + terminalID + "/" + stan
+}
+
+func terminalKey(merchantID, terminalID string) string {
+	return merchantID + "/" + terminalID
+}
+
+// load replays the file; later lines of a transaction replace the earlier ones.
+// A truncated last line, left by a crash during a write, is ignored.
+func (r *fileTransactionRepository) load() error {
+	file, err := os.Open(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var tx models.Transaction
+		if err := json.Unmarshal(scanner.Bytes(), &tx); err != nil {
+			logger.Component(context.Background(), logger.ComponentRepository).WithError(err).Warn("skipping unreadable transaction line")
+			continue
+		}
+
+		r.store(&tx)
+	}
+
+	return scanner.Err()
+}
+
+// compact rewrites the file with the latest version of every transaction.
+func (r *fileTransactionRepository) compact() error {
+	stored := make([]*models.Transaction, 0, len(r.transactions))
+	for _, tx := range r.transactions {
+		stored = append(stored, tx)
+	}
+
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt.Before(stored[j].CreatedAt) })
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+
+	for _, tx := range stored {
+		if err := encoder.Encode(tx); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	if err := errors.Join(writer.Flush(), tmp.Chmod(0o600), tmp.Sync()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), r.path)
+}
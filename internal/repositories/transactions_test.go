@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+)
+
+func newTestTransaction(id string, createdAt time.Time) *models.Transaction {
+	return &models.Transaction{
+		ID:         id,
+		Operation:  "authorization",
+		MTI:        "0200",
+		Amount:     "000000001000",
+		TerminalID: "T1",
+		MerchantID: "M1",
+		STAN:       "000001",
+		Status:     models.TransactionPending,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}
+}
+
+func TestTransactionRepositoryUpdate(t *testing.T) {
+	repo, err := NewFileTransactionRepository(filepath.Join(t.TempDir(), "transactions.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	ctx := context.Background()
+	tx := newTestTransaction("tx-1", time.Now().UTC())
+
+	if err := repo.Create(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Create(ctx, tx); !errors.Is(err, ErrConflict) {
+		t.Fatalf("second create: err = %v, want %v", err, ErrConflict)
+	}
+
+	tx.Status = models.TransactionApproved
+	if err := repo.Update(ctx, tx, models.TransactionPending); err != nil {
+		t.Fatal(err)
+	}
+
+	// The transaction is no longer pending, so an update expecting it is rejected.
+	tx.Status = models.TransactionUnresolved
+	if err := repo.Update(ctx, tx, models.TransactionPending); !errors.Is(err, ErrConflict) {
+		t.Fatalf("update from a stale status: err = %v, want %v", err, ErrConflict)
+	}
+
+	stored, err := repo.FindByID(ctx, "tx-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.TransactionApproved {
+		t.Fatalf("status = %s, want %s", stored.Status, models.TransactionApproved)
+	}
+
+	if err := repo.Update(ctx, newTestTransaction("tx-2", time.Now()), models.TransactionPending); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("update of an unknown transaction: err = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestTransactionRepositoryReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.jsonl")
+	ctx := context.Background()
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	repo, err := NewFileTransactionRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"tx-3", "tx-1", "tx-2"} {
+		if err := repo.Create(ctx, newTestTransaction(id, start.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	approved := newTestTransaction("tx-1", start.Add(time.Minute))
+	approved.Status = models.TransactionApproved
+	if err := repo.Update(ctx, approved, models.TransactionPending); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash in the middle of a write leaves a truncated last line.
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"id":"tx-4","status":"pen`)
+	file.Close()
+
+	repo, err = NewFileTransactionRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	pending, err := repo.ListByStatus(ctx, models.TransactionPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != "tx-3" || pending[1].ID != "tx-2" {
+		t.Fatalf("pending transactions %v, want tx-3 and tx-2 by creation time", pending)
+	}
+
+	stored, err := repo.FindByID(ctx, "tx-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.TransactionApproved {
+		t.Fatalf("tx-1 status = %s, want the latest version %s", stored.Status, models.TransactionApproved)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Fatalf("%d lines after reopening, want the file compacted to 3", lines)
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+// maxActiveAPIKeys is the number of keys a merchant may hold at once: the
+// current one and the one being rotated in.
+const maxActiveAPIKeys = 2
+
+type (
+	APIKeyService interface {
+		Issue(ctx context.Context, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error)
+		Revoke(ctx context.Context, id string) error
+		List(ctx context.Context, merchantID string) ([]*models.APIKey, error)
+		Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error)
+	}
+
+	apiKeyService struct {
+		repo   repositories.APIKeyRepository
+		pepper string
+	}
+)
+
+// NewAPIKeyService returns the service issuing and authenticating the keys hashed
+// with pepper. The pepper is the apiKeys.pepper read at startup and is never
+// reloaded: rotating it invalidates every stored key, so it requires a restart.
+func NewAPIKeyService(repo repositories.APIKeyRepository, pepper string) APIKeyService {
+	return &apiKeyService{repo, pepper}
+}
+
+// Issue creates a new key for the merchant. When the merchant already holds the
+// maximum number of active keys the request is rejected, unless rotate is set,
+// in which case the oldest active key is revoked to make room for the new one.
+// The keys are checked and written in a single repository update, so
+// concurrent calls, from the server or the CLI, cannot exceed maxActiveAPIKeys.
+func (s *apiKeyService) Issue(ctx context.Context, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	id, secret, raw, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.APIKey{
+		ID:         id,
+		MerchantID: req.MerchantID,
+		Hash:       auth.HashAPIKeySecret(s.pepper, secret),
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	var revoked []*models.APIKey
+	err = s.repo.UpdateMerchant(ctx, req.MerchantID, func(keys []*models.APIKey) ([]*models.APIKey, error) {
+		active := []*models.APIKey{}
+		for _, key := range keys {
+			if key.Active() {
+				active = append(active, key)
+			}
+		}
+
+		if len(active) >= maxActiveAPIKeys {
+			if !req.Rotate {
+				return nil, ErrTooManyActiveKeys
+			}
+
+			now := time.Now().UTC()
+			for _, key := range active[:len(active)-maxActiveAPIKeys+1] {
+				key.RevokedAt = &now
+				revoked = append(revoked, key)
+			}
+		}
+
+		return append(revoked, key), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range revoked {
+		logger.Component(ctx, logger.ComponentService).WithField("merchant_id", key.MerchantID).WithField("key_id", key.ID).Info("api key revoked")
+	}
+	logger.Component(ctx, logger.ComponentService).WithField("merchant_id", key.MerchantID).WithField("key_id", key.ID).Info("api key issued")
+
+	return &models.CreateAPIKeyResponse{
+		ID:            key.ID,
+		MerchantID:    key.MerchantID,
+		Key:           raw,
+		SigningSecret: hex.EncodeToString(auth.DeriveSigningKey(s.pepper, key.ID)),
+		CreatedAt:     key.CreatedAt,
+	}, nil
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id string) error {
+	key, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !key.Active() {
+		return ErrAPIKeyAlreadyRevoked
+	}
+
+	return s.revoke(ctx, key)
+}
+
+func (s *apiKeyService) List(ctx context.Context, merchantID string) ([]*models.APIKey, error) {
+	keys, err := s.repo.ListByMerchant(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		key.Hash = ""
+	}
+
+	return keys, nil
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error) {
+	id, secret, err := auth.ParseAPIKey(rawKey)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !key.Active() || !auth.CompareAPIKeyHash(s.pepper, secret, key.Hash) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return key, nil
+}
+
+func (s *apiKeyService) revoke(ctx context.Context, key *models.APIKey) error {
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+
+	if err := s.repo.Update(ctx, key); err != nil {
+		return err
+	}
+
+	logger.Component(ctx, logger.ComponentService).WithField("merchant_id", key.MerchantID).WithField("key_id", key.ID).Info("api key revoked")
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+)
+
+const testPepper = "pepper"
+
+// newTestAPIKeys returns n services sharing a key file, as the server and the
+// CLI do.
+func newTestAPIKeys(t *testing.T, n int) []APIKeyService {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+
+	services := make([]APIKeyService, n)
+	for i := range services {
+		repo, err := repositories.NewFileAPIKeyRepository(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		services[i] = NewAPIKeyService(repo, testPepper)
+	}
+
+	return services
+}
+
+func issue(t *testing.T, service APIKeyService, rotate bool) *models.CreateAPIKeyResponse {
+	t.Helper()
+
+	issued, err := service.Issue(context.Background(), &models.CreateAPIKeyRequest{MerchantID: "M1", Rotate: rotate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issued
+}
+
+func activeKeys(t *testing.T, service APIKeyService) int {
+	t.Helper()
+
+	keys, err := service.List(context.Background(), "M1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active := 0
+	for _, key := range keys {
+		if key.Hash != "" {
+			t.Fatalf("key %s listed with its hash", key.ID)
+		}
+		if key.Active() {
+			active++
+		}
+	}
+	return active
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	service := newTestAPIKeys(t, 1)[0]
+	issued := issue(t, service, false)
+
+	key, err := service.Authenticate(context.Background(), issued.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ID != issued.ID || key.MerchantID != "M1" {
+		t.Fatalf("authenticated key %s of %s, want %s of M1", key.ID, key.MerchantID, issued.ID)
+	}
+
+	other, err := repositories.NewFileAPIKeyRepository(filepath.Join(t.TempDir(), "api_keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		service APIKeyService
+		rawKey  string
+	}{
+		{name: "malformed key", service: service, rawKey: "not-a-key"},
+		{name: "unknown key", service: service, rawKey: "0123456789abcdef." + issued.Key[len(issued.ID)+1:]},
+		{name: "wrong secret", service: service, rawKey: issued.ID + ".wrong"},
+		{name: "other pepper", service: NewAPIKeyService(other, "other pepper"), rawKey: issued.Key},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.service.Authenticate(context.Background(), tt.rawKey); !errors.Is(err, ErrInvalidAPIKey) {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidAPIKey)
+			}
+		})
+	}
+}
+
+func TestAPIKeyRevoke(t *testing.T) {
+	service := newTestAPIKeys(t, 1)[0]
+	issued := issue(t, service, false)
+
+	if err := service.Revoke(context.Background(), issued.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Authenticate(context.Background(), issued.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("authenticating a revoked key: err = %v, want %v", err, ErrInvalidAPIKey)
+	}
+	if err := service.Revoke(context.Background(), issued.ID); !errors.Is(err, ErrAPIKeyAlreadyRevoked) {
+		t.Fatalf("revoking twice: err = %v, want %v", err, ErrAPIKeyAlreadyRevoked)
+	}
+	if err := service.Revoke(context.Background(), "0123456789abcdef"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Fatalf("revoking an unknown key: err = %v, want %v", err, ErrAPIKeyNotFound)
+	}
+}
+
+func TestAPIKeyActiveCap(t *testing.T) {
+	service := newTestAPIKeys(t, 1)[0]
+	issue(t, service, false)
+	issue(t, service, false)
+
+	_, err := service.Issue(context.Background(), &models.CreateAPIKeyRequest{MerchantID: "M1"})
+	if !errors.Is(err, ErrTooManyActiveKeys) {
+		t.Fatalf("err = %v, want %v", err, ErrTooManyActiveKeys)
+	}
+	if active := activeKeys(t, service); active != maxActiveAPIKeys {
+		t.Fatalf("%d active keys, want %d", active, maxActiveAPIKeys)
+	}
+}
+
+func TestAPIKeyRotate(t *testing.T) {
+	service := newTestAPIKeys(t, 1)[0]
+	oldest := issue(t, service, false)
+	current := issue(t, service, false)
+	rotated := issue(t, service, true)
+
+	if active := activeKeys(t, service); active != maxActiveAPIKeys {
+		t.Fatalf("%d active keys, want %d", active, maxActiveAPIKeys)
+	}
+	if _, err := service.Authenticate(context.Background(), oldest.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("oldest key: err = %v, want %v", err, ErrInvalidAPIKey)
+	}
+	for _, issued := range []*models.CreateAPIKeyResponse{current, rotated} {
+		if _, err := service.Authenticate(context.Background(), issued.Key); err != nil {
+			t.Fatalf("key %s: %v", issued.ID, err)
+		}
+	}
+}
+
+func TestAPIKeyActiveCapAcrossStores(t *testing.T) {
+	// Two stores on the same file stand for the server and the CLI.
+	services := newTestAPIKeys(t, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(service APIKeyService) {
+			defer wg.Done()
+			_, err := service.Issue(context.Background(), &models.CreateAPIKeyRequest{MerchantID: "M1", Rotate: true})
+			if err != nil {
+				t.Error(err)
+			}
+		}(services[i%2])
+	}
+	wg.Wait()
+
+	keys, err := services[0].List(context.Background(), "M1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 20 {
+		t.Fatalf("%d keys stored, want the 20 issued", len(keys))
+	}
+	for _, service := range services {
+		if active := activeKeys(t, service); active != maxActiveAPIKeys {
+			t.Fatalf("%d active keys, want %d", active, maxActiveAPIKeys)
+		}
+	}
+}
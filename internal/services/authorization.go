@@ -3,7 +3,14 @@ package services
 import (
 	"context"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
 )
 
 type (
@@ -11,13 +18,45 @@ type (
 		Process(ctx context.Context, req *models.AuthorizationRequest) (*models.AuthorizationResponse, error)
 	}
 
-	authorizationService struct{}
+	authorizationService struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+	}
 )
 
-func NewAuthorizationService() AuthorizationService {
-	return &authorizationService{}
+func NewAuthorizationService(gateways *clients.Router, transactions repositories.TransactionRepository) AuthorizationService {
+	return &authorizationService{gateways, transactions}
 }
 
-func (s *authorizationService) Process(ctx context.Context, req *models.AuthorizationRequest) (*models.AuthorizationResponse, error) {
-	return nil, nil
+func (s *authorizationService) Process(ctx context.Context, req *models.AuthorizationRequest) (_ *models.AuthorizationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "services.AuthorizationService.Process", operationAttributes("authorization", req.MerchantID, req.TerminalID)...)
+	defer tracing.End(span, &err)
+
+	if err := checkMerchant(ctx, req.MerchantID); err != nil {
+		return nil, err
+	}
+
+	request := financialRequest{
+		MTI:            req.Mti,
+		ProcessingCode: req.ProcessingCode,
+		Amount:         req.Amount,
+		EntryMode:      req.EntryMode,
+		Track2:         req.Track2,
+		TerminalID:     req.TerminalID,
+		MerchantID:     req.MerchantID,
+		Currency:       req.Currency,
+		STAN:           req.STAN,
+	}
+
+	tx, resp, err := exchange(ctx, route(s.gateways, "authorization", request), s.transactions, "authorization", "", newFinancialMessage(ctx, request))
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+
+	responseCode := resp.Get(clients.FieldResponseCode)
+	span.SetAttributes(attribute.String("iso.response_code", responseCode))
+	metrics.ResponseCodes.WithLabelValues("authorization", responseCode).Inc()
+	logger.Component(ctx, logger.ComponentService).WithField("response_code", responseCode).Info("authorization processed")
+
+	return &models.AuthorizationResponse{TransactionID: tx.ID, ResponseCode: responseCode, STAN: tx.STAN, RRN: tx.RRN}, nil
 }
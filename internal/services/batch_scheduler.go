@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+type (
+	// BatchScheduler closes the batches of every terminal at the configured times
+	// of day. Runs missed while the server was down are not caught up: the next
+	// run settles their transactions.
+	BatchScheduler struct {
+		service  BatchService
+		times    []time.Duration
+		location *time.Location
+
+		stop     chan struct{}
+		done     chan struct{}
+		stopOnce sync.Once
+	}
+)
+
+// NewBatchScheduler schedules the closes at times, offsets from midnight in
+// location. No close is scheduled when times is empty.
+func NewBatchScheduler(service BatchService, times []time.Duration, location *time.Location) *BatchScheduler {
+	times = append([]time.Duration(nil), times...)
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	return &BatchScheduler{
+		service:  service,
+		times:    times,
+		location: location,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the scheduled closes until Close.
+func (s *BatchScheduler) Start() {
+	if len(s.times) == 0 {
+		close(s.done)
+		return
+	}
+
+	// Closing stop cancels the close in progress; its batches are recorded as failed.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-s.stop
+		cancel()
+	}()
+
+	go func() {
+		defer close(s.done)
+
+		for {
+			next := s.next(time.Now())
+			logger.Component(ctx, logger.ComponentService).WithField("next_close", next).Debug("batch close scheduled")
+
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(time.Until(next)):
+			}
+
+			s.service.CloseAll(ctx)
+		}
+	}()
+}
+
+// Close stops the scheduler and waits for it to return.
+func (s *BatchScheduler) Close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// next returns the first scheduled time after now.
+func (s *BatchScheduler) next(now time.Time) time.Time {
+	now = now.In(s.location)
+	year, month, day := now.Date()
+
+	// The clock time is set rather than the offset added, so the closes keep
+	// their time of day across DST changes.
+	for days := 0; ; days++ {
+		for _, offset := range s.times {
+			at := time.Date(year, month, day+days, int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, s.location)
+			if at.After(now) {
+				return at
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchSchedulerNext(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, location)
+	}
+
+	tests := []struct {
+		name  string
+		times []time.Duration
+		now   time.Time
+		want  time.Time
+	}{
+		{
+			name:  "later the same day",
+			times: []time.Duration{23 * time.Hour},
+			now:   at(2026, time.October, 19, 10, 0),
+			want:  at(2026, time.October, 19, 23, 0),
+		},
+		{
+			name:  "between two times",
+			times: []time.Duration{23 * time.Hour, 2 * time.Hour},
+			now:   at(2026, time.October, 19, 10, 0),
+			want:  at(2026, time.October, 19, 23, 0),
+		},
+		{
+			name:  "after the last time",
+			times: []time.Duration{2 * time.Hour, 14*time.Hour + 30*time.Minute},
+			now:   at(2026, time.October, 19, 15, 0),
+			want:  at(2026, time.October, 20, 2, 0),
+		},
+		{
+			name:  "exactly at a time",
+			times: []time.Duration{23 * time.Hour},
+			now:   at(2026, time.October, 19, 23, 0),
+			want:  at(2026, time.October, 20, 23, 0),
+		},
+		{
+			name:  "now in another zone",
+			times: []time.Duration{23 * time.Hour},
+			now:   time.Date(2026, time.October, 20, 2, 0, 0, 0, time.UTC),
+			want:  at(2026, time.October, 19, 23, 0),
+		},
+		{
+			name:  "keeps the time of day when clocks spring forward",
+			times: []time.Duration{23 * time.Hour},
+			now:   at(2026, time.March, 7, 23, 30),
+			want:  at(2026, time.March, 8, 23, 0),
+		},
+		{
+			name:  "keeps the time of day when clocks fall back",
+			times: []time.Duration{23 * time.Hour},
+			now:   at(2026, time.October, 31, 23, 30),
+			want:  at(2026, time.November, 1, 23, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewBatchScheduler(nil, tt.times, location).next(tt.now)
+			if !got.Equal(tt.want) {
+				t.Fatalf("next = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
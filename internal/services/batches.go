@@ -0,0 +1,546 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
+
+const (
+	settlementMTI  = "0500"
+	batchUploadMTI = "0320"
+	// settlementProcessingCode reconciles the totals of a batch, and
+	// settlementAfterUploadProcessingCode closes the batch upload that follows an
+	// out of balance answer.
+	settlementProcessingCode            = "920000"
+	settlementAfterUploadProcessingCode = "960000"
+	// responseOutOfBalance answers a 0500 whose totals differ from the gateway ones.
+	responseOutOfBalance = "95"
+	maxBatchNumber       = 999999
+)
+
+type (
+	// BatchService closes the batches of the terminals: the approved financial
+	// transactions of a terminal not settled yet are totaled by operation and
+	// reconciled with each gateway they were sent to.
+	BatchService interface {
+		// Close opens the batches of the terminal of req, one per gateway holding
+		// transactions to settle, and reconciles them in the background. The
+		// batches are returned open; List reports their outcome.
+		Close(ctx context.Context, req *models.BatchCloseRequest) (*models.BatchCloseResponse, error)
+		// List returns the batches of a terminal of the authenticated merchant, newest first.
+		List(ctx context.Context, terminalID string) ([]*models.Batch, error)
+		// CloseAll closes the batches of every terminal with transactions to settle.
+		CloseAll(ctx context.Context)
+		// Recover reconciles in the background the batches left open by a previous
+		// run, sending their 0500 again with the same number and totals.
+		Recover(ctx context.Context)
+		// Wait waits for the batches being reconciled in the background.
+		Wait()
+	}
+
+	batchService struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+		batches      repositories.BatchRepository
+		locks        *keyedLocks
+		jobs         sync.WaitGroup
+	}
+
+	// batchSettlement is an open batch with the transactions it settles.
+	batchSettlement struct {
+		gateway *clients.Gateway
+		batch   *models.Batch
+		totals  settlementTotals
+		settled []*models.Transaction
+	}
+
+	// settlementTotals are the counts and amounts of fields 74 to 89 of the 0500.
+	settlementTotals struct {
+		credits, creditReversals, debits, debitReversals                         int
+		creditsAmount, creditReversalsAmount, debitsAmount, debitReversalsAmount int64
+	}
+)
+
+func NewBatchService(gateways *clients.Router, transactions repositories.TransactionRepository, batches repositories.BatchRepository) BatchService {
+	return &batchService{gateways: gateways, transactions: transactions, batches: batches, locks: newKeyedLocks()}
+}
+
+func (s *batchService) Close(ctx context.Context, req *models.BatchCloseRequest) (_ *models.BatchCloseResponse, err error) {
+	ctx, span := tracing.Start(ctx, "services.BatchService.Close", attribute.String("merchant.id", req.MerchantID), attribute.String("terminal.id", req.TerminalID))
+	defer tracing.End(span, &err)
+
+	if err := checkMerchant(ctx, req.MerchantID); err != nil {
+		return nil, err
+	}
+
+	// Batches opened before a failure are reconciled all the same.
+	settlements, err := s.open(ctx, req.MerchantID, req.TerminalID)
+
+	// The batches are updated by the reconciliation, the answer gets copies.
+	batches := make([]*models.Batch, 0, len(settlements))
+	for _, settlement := range settlements {
+		copied := *settlement.batch
+		batches = append(batches, &copied)
+	}
+
+	if len(settlements) > 0 {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.settleAll(context.WithoutCancel(ctx), settlements)
+		}()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.BatchCloseResponse{Batches: batches}, nil
+}
+
+func (s *batchService) List(ctx context.Context, terminalID string) (_ []*models.Batch, err error) {
+	ctx, span := tracing.Start(ctx, "services.BatchService.List", attribute.String("terminal.id", terminalID))
+	defer tracing.End(span, &err)
+
+	merchantID := auth.MerchantFromContext(ctx)
+	if merchantID == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	return s.batches.ListByTerminal(ctx, merchantID, terminalID)
+}
+
+func (s *batchService) CloseAll(ctx context.Context) {
+	approved, err := s.transactions.ListByStatus(ctx, models.TransactionApproved)
+	if err != nil {
+		logger.Component(ctx, logger.ComponentService).WithError(err).Error("failed to list the terminals to close")
+		return
+	}
+
+	type terminal struct{ merchantID, terminalID string }
+
+	seen := map[terminal]bool{}
+	for _, tx := range approved {
+		key := terminal{tx.MerchantID, tx.TerminalID}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		entry := logger.Component(ctx, logger.ComponentService).WithField("merchant_id", key.merchantID).WithField("terminal_id", key.terminalID)
+
+		settlements, err := s.open(ctx, key.merchantID, key.terminalID)
+		if err != nil {
+			entry.WithError(err).Warn("scheduled batch close failed")
+		}
+		s.settleAll(ctx, settlements)
+	}
+}
+
+func (s *batchService) Recover(ctx context.Context) {
+	open, err := s.batches.ListByStatus(ctx, models.BatchOpen)
+	if err != nil {
+		logger.Component(ctx, logger.ComponentService).WithError(err).Error("failed to list the open batches")
+		return
+	}
+	if len(open) == 0 {
+		return
+	}
+
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+
+		for _, batch := range open {
+			s.reconcile(ctx, batch)
+		}
+	}()
+}
+
+func (s *batchService) Wait() {
+	s.jobs.Wait()
+}
+
+// reconcile settles again batch, left open when the server stopped during its
+// reconciliation, with the transactions of its gateway between its start and
+// its cutoff. A batch whose gateway is gone is recorded failed.
+func (s *batchService) reconcile(ctx context.Context, batch *models.Batch) {
+	entry := logger.Component(ctx, logger.ComponentService).WithFields(logrus.Fields{
+		"merchant_id":  batch.MerchantID,
+		"terminal_id":  batch.TerminalID,
+		"gateway":      batch.Gateway,
+		"batch_number": batch.Number,
+	})
+
+	gateway := s.gateways.Gateway(batch.Gateway)
+	if gateway == nil {
+		entry.Error("gateway of open batch is no longer configured, recording it failed")
+		batch.Status = models.BatchFailed
+		batch.UpdatedAt = time.Now().UTC()
+		if err := s.batches.Update(ctx, batch); err != nil {
+			entry.WithError(err).Error("failed to record the batch outcome")
+		}
+		return
+	}
+
+	page, err := s.transactions.Search(ctx, repositories.TransactionQuery{MerchantID: batch.MerchantID, TerminalID: batch.TerminalID, CreatedFrom: batch.From, CreatedTo: batch.Cutoff})
+	if err != nil {
+		entry.WithError(err).Error("failed to list the transactions of open batch")
+		return
+	}
+
+	var transactions []*models.Transaction
+	for _, tx := range page.Transactions {
+		if txGateway := s.gateways.Gateway(tx.Gateway); txGateway != nil && txGateway.Name == gateway.Name {
+			transactions = append(transactions, tx)
+		}
+	}
+
+	settled, totals, _, err := s.totals(ctx, transactions)
+	if err != nil {
+		entry.WithError(err).Error("failed to total the transactions of open batch")
+		return
+	}
+
+	entry.Info("reconciling batch left open")
+	s.settle(ctx, batchSettlement{gateway: gateway, batch: batch, totals: totals, settled: settled})
+}
+
+// open records open the batches of the transactions of the terminal created
+// before now and after the cutoff of its last settled batch on their gateway.
+// Nothing is opened while one of them is still pending, since its outcome is
+// part of the totals, nor while a batch of the terminal is being reconciled.
+func (s *batchService) open(ctx context.Context, merchantID, terminalID string) ([]batchSettlement, error) {
+	unlock := s.locks.lock(terminalKey(merchantID, terminalID))
+	defer unlock()
+
+	cutoff := time.Now().UTC()
+
+	previous, err := s.batches.ListByTerminal(ctx, merchantID, terminalID)
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range previous {
+		if batch.Status == models.BatchOpen {
+			return nil, ErrBatchOpen
+		}
+	}
+
+	page, err := s.transactions.Search(ctx, repositories.TransactionQuery{MerchantID: merchantID, TerminalID: terminalID, CreatedTo: cutoff})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		gateways []*clients.Gateway
+		open     = map[string][]*models.Transaction{}
+	)
+	for _, tx := range page.Transactions {
+		gateway := s.gateways.Gateway(tx.Gateway)
+		if gateway == nil {
+			transactionEntry(ctx, tx).Error("gateway of transaction is no longer configured, left out of the batch")
+			continue
+		}
+		if tx.CreatedAt.Before(lastSettledCutoff(previous, gateway.Name)) {
+			continue
+		}
+		if tx.Status == models.TransactionPending {
+			return nil, ErrBatchInFlight
+		}
+
+		if _, ok := open[gateway.Name]; !ok {
+			gateways = append(gateways, gateway)
+		}
+		open[gateway.Name] = append(open[gateway.Name], tx)
+	}
+
+	var opened []batchSettlement
+	for _, gateway := range gateways {
+		settled, totals, byOperation, err := s.totals(ctx, open[gateway.Name])
+		if err != nil {
+			return opened, err
+		}
+		if len(settled) == 0 {
+			continue
+		}
+
+		batch := &models.Batch{
+			ID:         newTransactionID(),
+			Number:     nextBatchNumber(previous, gateway.Name),
+			MerchantID: merchantID,
+			TerminalID: terminalID,
+			Gateway:    gateway.Name,
+			From:       lastSettledCutoff(previous, gateway.Name),
+			Cutoff:     cutoff,
+			Totals:     byOperation,
+			Status:     models.BatchOpen,
+			CreatedAt:  cutoff,
+			UpdatedAt:  cutoff,
+		}
+		if err := s.batches.Create(ctx, batch); err != nil {
+			return opened, err
+		}
+
+		opened = append(opened, batchSettlement{gateway: gateway, batch: batch, totals: totals, settled: settled})
+	}
+
+	return opened, nil
+}
+
+func (s *batchService) settleAll(ctx context.Context, settlements []batchSettlement) {
+	for _, settlement := range settlements {
+		s.settle(ctx, settlement)
+	}
+}
+
+// totals selects the transactions of a batch that are settled, the approved
+// financial ones, and totals them by ISO category and by operation. Holds of
+// pre-authorizations are not settled, nor the voids of holds, e.g. the release of
+// an expired pre-authorization; voids of refunds are credit reversals.
+func (s *batchService) totals(ctx context.Context, transactions []*models.Transaction) ([]*models.Transaction, settlementTotals, []models.BatchTotal, error) {
+	var (
+		settled     []*models.Transaction
+		totals      settlementTotals
+		byOperation []models.BatchTotal
+	)
+
+	for _, tx := range transactions {
+		if tx.Status != models.TransactionApproved {
+			continue
+		}
+
+		amount := parseAmount(tx.Amount)
+
+		switch tx.Operation {
+		case "authorization", "confirmation":
+			totals.debits++
+			totals.debitsAmount += amount
+		case "refund":
+			totals.credits++
+			totals.creditsAmount += amount
+		case "cancellation", "reversal":
+			voided, err := s.voidedOperation(ctx, tx)
+			if err != nil {
+				return nil, totals, nil, err
+			}
+
+			switch voided {
+			case "pre_authorization", "incremental_authorization":
+				continue
+			case "refund":
+				totals.creditReversals++
+				totals.creditReversalsAmount += amount
+			default:
+				totals.debitReversals++
+				totals.debitReversalsAmount += amount
+			}
+		default:
+			continue
+		}
+
+		settled = append(settled, tx)
+		byOperation = addBatchTotal(byOperation, tx.Operation, amount)
+	}
+
+	return settled, totals, byOperation, nil
+}
+
+// voidedOperation returns the operation of the transaction voided by tx, empty
+// when tx was sent without it.
+func (s *batchService) voidedOperation(ctx context.Context, tx *models.Transaction) (string, error) {
+	if tx.OriginalTransactionID == "" {
+		return "", nil
+	}
+
+	original, err := s.transactions.FindByID(ctx, tx.OriginalTransactionID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return original.Operation, nil
+}
+
+// settle reconciles the batch with its gateway and records the outcome. An out
+// of balance answer uploads every settled transaction in a 0320, then closes the
+// upload with a second 0500.
+func (s *batchService) settle(ctx context.Context, settlement batchSettlement) {
+	gateway, batch, totals, settled := settlement.gateway, settlement.batch, settlement.totals, settlement.settled
+
+	entry := logger.Component(ctx, logger.ComponentService).WithFields(logrus.Fields{
+		"merchant_id":  batch.MerchantID,
+		"terminal_id":  batch.TerminalID,
+		"gateway":      batch.Gateway,
+		"batch_number": batch.Number,
+	})
+
+	batch.Status = models.BatchFailed
+
+	resp, err := gateway.Client.Send(ctx, newSettlementMessage(batch, totals, settlementProcessingCode))
+	switch {
+	case err != nil:
+		entry.WithError(err).Error("batch reconciliation failed")
+	case resp.Get(clients.FieldResponseCode) == "00":
+		batch.ResponseCode = resp.Get(clients.FieldResponseCode)
+		batch.Status = models.BatchSettled
+	case resp.Get(clients.FieldResponseCode) == responseOutOfBalance:
+		batch.ResponseCode = responseOutOfBalance
+		entry.Warn("batch out of balance, uploading its transactions")
+		s.upload(ctx, gateway, batch, totals, settled)
+	default:
+		batch.ResponseCode = resp.Get(clients.FieldResponseCode)
+		entry.WithField("response_code", batch.ResponseCode).Error("batch reconciliation declined")
+	}
+
+	batch.UpdatedAt = time.Now().UTC()
+
+	// The context may be canceled already, the outcome must still be recorded.
+	if err := s.batches.Update(context.WithoutCancel(ctx), batch); err != nil {
+		entry.WithError(err).Error("failed to record the batch outcome")
+	}
+
+	metrics.Batches.WithLabelValues(batch.Gateway, string(batch.Status)).Inc()
+	entry.WithField("status", batch.Status).Info("batch closed")
+}
+
+func (s *batchService) upload(ctx context.Context, gateway *clients.Gateway, batch *models.Batch, totals settlementTotals, settled []*models.Transaction) {
+	for _, tx := range settled {
+		if _, err := gateway.Client.Send(ctx, newBatchUploadMessage(batch, tx)); err != nil {
+			transactionEntry(ctx, tx).WithError(err).WithField("batch_number", batch.Number).Error("batch upload failed")
+			return
+		}
+		batch.Uploaded++
+	}
+
+	resp, err := gateway.Client.Send(ctx, newSettlementMessage(batch, totals, settlementAfterUploadProcessingCode))
+	if err != nil {
+		logger.Component(ctx, logger.ComponentService).WithError(err).WithField("batch_number", batch.Number).Error("batch upload close failed")
+		return
+	}
+
+	batch.UploadResponseCode = resp.Get(clients.FieldResponseCode)
+	if batch.UploadResponseCode == "00" {
+		batch.Status = models.BatchSettledAfterUpload
+	}
+}
+
+// newSettlementMessage builds the 0500 of batch. Field 97 holds the net amount,
+// debits less credits and reversals, prefixed with D when it is owed to the
+// merchant and C otherwise.
+func newSettlementMessage(batch *models.Batch, totals settlementTotals, processingCode string) *clients.Message {
+	now := time.Now().UTC()
+
+	net := totals.debitsAmount - totals.debitReversalsAmount - totals.creditsAmount + totals.creditReversalsAmount
+	sign := "D"
+	if net < 0 {
+		sign, net = "C", -net
+	}
+
+	return clients.NewMessage(settlementMTI).
+		Set(clients.FieldProcessingCode, processingCode).
+		Set(clients.FieldTransmissionDateTime, now.Format("0102150405")).
+		Set(clients.FieldSTAN, clients.NextSTAN()).
+		Set(clients.FieldLocalTime, now.Format("150405")).
+		Set(clients.FieldLocalDate, now.Format("0102")).
+		Set(clients.FieldSettlementDate, batch.Cutoff.Format("0102")).
+		Set(clients.FieldTerminalID, batch.TerminalID).
+		Set(clients.FieldMerchantID, batch.MerchantID).
+		Set(clients.FieldBatchNumber, batch.Number).
+		Set(clients.FieldCreditsNumber, fmt.Sprintf("%010d", totals.credits)).
+		Set(clients.FieldCreditsReversalNumber, fmt.Sprintf("%010d", totals.creditReversals)).
+		Set(clients.FieldDebitsNumber, fmt.Sprintf("%010d", totals.debits)).
+		Set(clients.FieldDebitsReversalNumber, fmt.Sprintf("%010d", totals.debitReversals)).
+		Set(clients.FieldCreditsAmount, fmt.Sprintf("%016d", totals.creditsAmount)).
+		Set(clients.FieldCreditsReversalAmount, fmt.Sprintf("%016d", totals.creditReversalsAmount)).
+		Set(clients.FieldDebitsAmount, fmt.Sprintf("%016d", totals.debitsAmount)).
+		Set(clients.FieldDebitsReversalAmount, fmt.Sprintf("%016d", totals.debitReversalsAmount)).
+		Set(clients.FieldNetSettlementAmount, fmt.Sprintf("%s%016d", sign, net))
+}
+
+// newBatchUploadMessage builds the 0320 of tx, carrying its outcome and, in
+// field 90, its original data.
+func newBatchUploadMessage(batch *models.Batch, tx *models.Transaction) *clients.Message {
+	now := time.Now().UTC()
+
+	msg := clients.NewMessage(batchUploadMTI).
+		Set(clients.FieldProcessingCode, tx.ProcessingCode).
+		Set(clients.FieldAmount, tx.Amount).
+		Set(clients.FieldTransmissionDateTime, now.Format("0102150405")).
+		Set(clients.FieldSTAN, clients.NextSTAN()).
+		Set(clients.FieldLocalTime, now.Format("150405")).
+		Set(clients.FieldLocalDate, now.Format("0102")).
+		Set(clients.FieldEntryMode, tx.EntryMode).
+		Set(clients.FieldRRN, tx.RRN).
+		Set(clients.FieldAuthorizationCode, tx.AuthorizationCode).
+		Set(clients.FieldResponseCode, tx.ResponseCode).
+		Set(clients.FieldTerminalID, tx.TerminalID).
+		Set(clients.FieldMerchantID, tx.MerchantID).
+		Set(clients.FieldBatchNumber, batch.Number).
+		Set(clients.FieldOriginalData, originalData(tx))
+
+	if tx.Currency != "" {
+		msg.Set(clients.FieldCurrency, tx.Currency)
+	}
+
+	return msg
+}
+
+func addBatchTotal(totals []models.BatchTotal, operation string, amount int64) []models.BatchTotal {
+	for i := range totals {
+		if totals[i].Operation == operation {
+			totals[i].Count++
+			totals[i].Amount = formatAmount(parseAmount(totals[i].Amount) + amount)
+			return totals
+		}
+	}
+
+	return append(totals, models.BatchTotal{Operation: operation, Count: 1, Amount: formatAmount(amount)})
+}
+
+// lastSettledCutoff returns the cutoff of the last settled batch on gateway, the
+// start of the next one; zero when there is none. previous is newest first.
+func lastSettledCutoff(previous []*models.Batch, gateway string) time.Time {
+	for _, batch := range previous {
+		if batch.Gateway == gateway && (batch.Status == models.BatchSettled || batch.Status == models.BatchSettledAfterUpload) {
+			return batch.Cutoff
+		}
+	}
+
+	return time.Time{}
+}
+
+// nextBatchNumber follows the number of the last batch on gateway, whatever its
+// outcome, wrapping around after 999999.
+func nextBatchNumber(previous []*models.Batch, gateway string) string {
+	for _, batch := range previous {
+		if batch.Gateway != gateway {
+			continue
+		}
+
+		number, _ := strconv.Atoi(batch.Number)
+		return fmt.Sprintf("%06d", number%maxBatchNumber+1)
+	}
+
+	return fmt.Sprintf("%06d", 1)
+}
+
+func terminalKey(merchantID, terminalID string) string {
+	return merchantID + "/" + terminalID
+}
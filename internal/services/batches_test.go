@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+)
+
+// settlementReply answers the 0500 with codes, in order, and every other
+// message with 00.
+func settlementReply(codes ...string) func(msg *clients.Message) (*clients.Message, error) {
+	var mu sync.Mutex
+
+	return func(msg *clients.Message) (*clients.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		code := "00"
+		if msg.MTI == settlementMTI && len(codes) > 0 {
+			code, codes = codes[0], codes[1:]
+		}
+
+		return answer(msg, code), nil
+	}
+}
+
+// requests returns the MTI and processing code of every message sent.
+func (g *fakeGateway) requests() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var requests []string
+	for _, msg := range g.sent {
+		requests = append(requests, msg.MTI+"/"+msg.Get(clients.FieldProcessingCode))
+	}
+
+	return requests
+}
+
+// batchTransactions are the transactions of terminal T1, an hour old: seven are
+// settled, three debits for 3500, two credits for 600, a debit reversal of 1000
+// and a credit reversal of 300.
+func batchTransactions() []*models.Transaction {
+	createdAt := time.Now().UTC().Add(-time.Hour)
+
+	tx := func(id, operation, originalID string, status models.TransactionStatus, amount string) *models.Transaction {
+		return &models.Transaction{
+			ID:                    id,
+			Operation:             operation,
+			ProcessingCode:        "000000",
+			MerchantID:            testMerchant,
+			TerminalID:            "T1",
+			Gateway:               testGateway,
+			Amount:                amount,
+			Status:                status,
+			OriginalTransactionID: originalID,
+			CreatedAt:             createdAt,
+		}
+	}
+
+	return []*models.Transaction{
+		tx("a1", "authorization", "", models.TransactionApproved, "000000001000"),
+		tx("a2", "authorization", "", models.TransactionDeclined, "000000000500"),
+		tx("a3", "authorization", "", models.TransactionApproved, "000000001000"),
+		tx("v1", "reversal", "a3", models.TransactionApproved, "000000001000"),
+		tx("r1", "refund", "a1", models.TransactionApproved, "000000000300"),
+		tx("r2", "refund", "a1", models.TransactionApproved, "000000000300"),
+		tx("x1", "cancellation", "r2", models.TransactionApproved, "000000000300"),
+		tx("p1", "pre_authorization", "", models.TransactionApproved, "000000002000"),
+		tx("i1", "incremental_authorization", "p1", models.TransactionApproved, "000000000500"),
+		tx("c1", "confirmation", "p1", models.TransactionApproved, "000000001500"),
+		tx("p2", "pre_authorization", "", models.TransactionApproved, "000000002000"),
+		tx("e1", "reversal", "p2", models.TransactionApproved, "000000002000"),
+	}
+}
+
+func newTestBatches(t *testing.T, batches ...*models.Batch) repositories.BatchRepository {
+	t.Helper()
+
+	repo, err := repositories.NewFileBatchRepository(filepath.Join(t.TempDir(), "batches.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	for _, batch := range batches {
+		if err := repo.Create(context.Background(), batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return repo
+}
+
+func TestBatchTotals(t *testing.T) {
+	service := &batchService{transactions: newTestTransactions(t, batchTransactions()...)}
+
+	settled, totals, byOperation, err := service.totals(context.Background(), batchTransactions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, tx := range settled {
+		ids = append(ids, tx.ID)
+	}
+	if want := []string{"a1", "a3", "v1", "r1", "r2", "x1", "c1"}; !slices.Equal(ids, want) {
+		t.Errorf("settled = %v, want %v", ids, want)
+	}
+
+	want := settlementTotals{
+		credits: 2, creditReversals: 1, debits: 3, debitReversals: 1,
+		creditsAmount: 600, creditReversalsAmount: 300, debitsAmount: 3500, debitReversalsAmount: 1000,
+	}
+	if totals != want {
+		t.Errorf("totals = %+v, want %+v", totals, want)
+	}
+
+	wantByOperation := []models.BatchTotal{
+		{Operation: "authorization", Count: 2, Amount: "000000002000"},
+		{Operation: "reversal", Count: 1, Amount: "000000001000"},
+		{Operation: "refund", Count: 2, Amount: "000000000600"},
+		{Operation: "cancellation", Count: 1, Amount: "000000000300"},
+		{Operation: "confirmation", Count: 1, Amount: "000000001500"},
+	}
+	if !slices.Equal(byOperation, wantByOperation) {
+		t.Errorf("totals by operation = %+v, want %+v", byOperation, wantByOperation)
+	}
+}
+
+func TestSettlementMessage(t *testing.T) {
+	batch := &models.Batch{Number: "000042", MerchantID: testMerchant, TerminalID: "T1", Cutoff: time.Date(2026, time.October, 19, 23, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name   string
+		totals settlementTotals
+		want   map[int]string
+	}{
+		{
+			name: "owed to the merchant",
+			totals: settlementTotals{
+				credits: 2, creditReversals: 1, debits: 3, debitReversals: 1,
+				creditsAmount: 600, creditReversalsAmount: 300, debitsAmount: 3500, debitReversalsAmount: 1000,
+			},
+			want: map[int]string{
+				clients.FieldCreditsNumber:         "0000000002",
+				clients.FieldCreditsReversalNumber: "0000000001",
+				clients.FieldDebitsNumber:          "0000000003",
+				clients.FieldDebitsReversalNumber:  "0000000001",
+				clients.FieldCreditsAmount:         "0000000000000600",
+				clients.FieldCreditsReversalAmount: "0000000000000300",
+				clients.FieldDebitsAmount:          "0000000000003500",
+				clients.FieldDebitsReversalAmount:  "0000000000001000",
+				clients.FieldNetSettlementAmount:   "D0000000000002200",
+			},
+		},
+		{
+			name:   "owed by the merchant",
+			totals: settlementTotals{credits: 1, debits: 1, creditsAmount: 2000, debitsAmount: 1500},
+			want: map[int]string{
+				clients.FieldCreditsNumber:       "0000000001",
+				clients.FieldDebitsNumber:        "0000000001",
+				clients.FieldCreditsAmount:       "0000000000002000",
+				clients.FieldDebitsAmount:        "0000000000001500",
+				clients.FieldNetSettlementAmount: "C0000000000000500",
+			},
+		},
+		{
+			name:   "reversals only",
+			totals: settlementTotals{debitReversals: 1, debitReversalsAmount: 700},
+			want: map[int]string{
+				clients.FieldDebitsReversalNumber: "0000000001",
+				clients.FieldDebitsReversalAmount: "0000000000000700",
+				clients.FieldNetSettlementAmount:  "C0000000000000700",
+			},
+		},
+		{
+			name:   "nothing to settle",
+			totals: settlementTotals{},
+			want: map[int]string{
+				clients.FieldCreditsNumber:       "0000000000",
+				clients.FieldDebitsAmount:        "0000000000000000",
+				clients.FieldNetSettlementAmount: "D0000000000000000",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := newSettlementMessage(batch, tt.totals, settlementProcessingCode)
+
+			want := map[int]string{
+				clients.FieldProcessingCode:        settlementProcessingCode,
+				clients.FieldSettlementDate:        "1019",
+				clients.FieldBatchNumber:           "000042",
+				clients.FieldCreditsNumber:         "0000000000",
+				clients.FieldCreditsReversalNumber: "0000000000",
+				clients.FieldDebitsNumber:          "0000000000",
+				clients.FieldDebitsReversalNumber:  "0000000000",
+				clients.FieldCreditsAmount:         "0000000000000000",
+				clients.FieldCreditsReversalAmount: "0000000000000000",
+				clients.FieldDebitsAmount:          "0000000000000000",
+				clients.FieldDebitsReversalAmount:  "0000000000000000",
+			}
+			for field, value := range tt.want {
+				want[field] = value
+			}
+
+			if msg.MTI != settlementMTI {
+				t.Errorf("MTI = %s, want %s", msg.MTI, settlementMTI)
+			}
+			for field, value := range want {
+				if got := msg.Get(field); got != value {
+					t.Errorf("field %d = %q, want %q", field, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchClose(t *testing.T) {
+	const (
+		settlement       = settlementMTI + "/" + settlementProcessingCode
+		upload           = batchUploadMTI + "/000000"
+		settlementUpload = settlementMTI + "/" + settlementAfterUploadProcessingCode
+	)
+
+	tests := []struct {
+		name             string
+		codes            []string
+		wantStatus       models.BatchStatus
+		wantResponse     string
+		wantUploaded     int
+		wantUploadAnswer string
+		wantRequests     []string
+	}{
+		{
+			name:         "in balance",
+			codes:        []string{"00"},
+			wantStatus:   models.BatchSettled,
+			wantResponse: "00",
+			wantRequests: []string{settlement},
+		},
+		{
+			name:             "in balance after the upload",
+			codes:            []string{responseOutOfBalance, "00"},
+			wantStatus:       models.BatchSettledAfterUpload,
+			wantResponse:     responseOutOfBalance,
+			wantUploaded:     7,
+			wantUploadAnswer: "00",
+			wantRequests:     []string{settlement, upload, upload, upload, upload, upload, upload, upload, settlementUpload},
+		},
+		{
+			name:             "out of balance after the upload",
+			codes:            []string{responseOutOfBalance, "05"},
+			wantStatus:       models.BatchFailed,
+			wantResponse:     responseOutOfBalance,
+			wantUploaded:     7,
+			wantUploadAnswer: "05",
+			wantRequests:     []string{settlement, upload, upload, upload, upload, upload, upload, upload, settlementUpload},
+		},
+		{
+			name:         "declined",
+			codes:        []string{"05"},
+			wantStatus:   models.BatchFailed,
+			wantResponse: "05",
+			wantRequests: []string{settlement},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := &fakeGateway{reply: settlementReply(tt.codes...)}
+			service := NewBatchService(newTestRouter(gateway, &fakeGateway{}), newTestTransactions(t, batchTransactions()...), newTestBatches(t))
+
+			resp, err := service.Close(merchantContext(testMerchant), &models.BatchCloseRequest{MerchantID: testMerchant, TerminalID: "T1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Batches) != 1 || resp.Batches[0].Status != models.BatchOpen || resp.Batches[0].Number != "000001" {
+				t.Fatalf("batches = %+v, want batch 000001 open", resp.Batches)
+			}
+			service.Wait()
+
+			batches, err := service.List(merchantContext(testMerchant), "T1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch := batches[0]
+			if batch.Status != tt.wantStatus || batch.ResponseCode != tt.wantResponse {
+				t.Errorf("batch %s answered %q, want %s answered %q", batch.Status, batch.ResponseCode, tt.wantStatus, tt.wantResponse)
+			}
+			if batch.Uploaded != tt.wantUploaded || batch.UploadResponseCode != tt.wantUploadAnswer {
+				t.Errorf("uploaded %d answered %q, want %d answered %q", batch.Uploaded, batch.UploadResponseCode, tt.wantUploaded, tt.wantUploadAnswer)
+			}
+			if requests := gateway.requests(); !slices.Equal(requests, tt.wantRequests) {
+				t.Errorf("requests = %v, want %v", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestBatchCloseStartsAfterTheLastSettledBatch(t *testing.T) {
+	gateway := &fakeGateway{reply: settlementReply("05", "00", "00")}
+	transactions := newTestTransactions(t, batchTransactions()...)
+	service := NewBatchService(newTestRouter(gateway, &fakeGateway{}), transactions, newTestBatches(t))
+
+	closeBatch := func() *models.Batch {
+		t.Helper()
+
+		resp, err := service.Close(merchantContext(testMerchant), &models.BatchCloseRequest{MerchantID: testMerchant, TerminalID: "T1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.Wait()
+
+		if len(resp.Batches) == 0 {
+			return nil
+		}
+		return resp.Batches[0]
+	}
+
+	// A failed batch is settled again by the next one, with the following number.
+	if batch := closeBatch(); batch.Number != "000001" {
+		t.Fatalf("first batch number = %s, want 000001", batch.Number)
+	}
+	if batch := closeBatch(); batch.Number != "000002" || len(batch.Totals) != 5 {
+		t.Fatalf("second batch = %+v, want number 000002 with every operation", batch)
+	}
+
+	// Only the transactions after the cutoff of the settled batch are left.
+	if batch := closeBatch(); batch != nil {
+		t.Fatalf("third batch = %+v, want none", batch)
+	}
+
+	refund := &models.Transaction{ID: "r3", Operation: "refund", MerchantID: testMerchant, TerminalID: "T1", Gateway: testGateway, Amount: "000000000100", Status: models.TransactionApproved, CreatedAt: time.Now().UTC()}
+	if err := transactions.Create(context.Background(), refund); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := closeBatch()
+	if batch == nil || batch.Number != "000003" || !slices.Equal(batch.Totals, []models.BatchTotal{{Operation: "refund", Count: 1, Amount: "000000000100"}}) {
+		t.Fatalf("fourth batch = %+v, want number 000003 with the new refund only", batch)
+	}
+}
+
+func TestBatchRecover(t *testing.T) {
+	open := &models.Batch{
+		ID:         "b1",
+		Number:     "000007",
+		MerchantID: testMerchant,
+		TerminalID: "T1",
+		Gateway:    testGateway,
+		Cutoff:     time.Now().UTC(),
+		Status:     models.BatchOpen,
+	}
+
+	gateway := &fakeGateway{reply: settlementReply("00")}
+	service := NewBatchService(newTestRouter(gateway, &fakeGateway{}), newTestTransactions(t, batchTransactions()...), newTestBatches(t, open))
+
+	// The terminal cannot be closed again until its open batch is reconciled.
+	if _, err := service.Close(merchantContext(testMerchant), &models.BatchCloseRequest{MerchantID: testMerchant, TerminalID: "T1"}); !errors.Is(err, ErrBatchOpen) {
+		t.Fatalf("close with an open batch: err = %v, want %v", err, ErrBatchOpen)
+	}
+
+	service.Recover(context.Background())
+	service.Wait()
+
+	batches, err := service.List(merchantContext(testMerchant), "T1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch := batches[0]; batch.ID != open.ID || batch.Status != models.BatchSettled {
+		t.Fatalf("batch %s is %s, want %s settled", batch.ID, batch.Status, open.ID)
+	}
+
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+	if len(gateway.sent) != 1 {
+		t.Fatalf("sent %d messages, want the 0500 only", len(gateway.sent))
+	}
+	msg := gateway.sent[0]
+	if msg.Get(clients.FieldBatchNumber) != open.Number || msg.Get(clients.FieldNetSettlementAmount) != "D0000000000002200" {
+		t.Fatalf("0500 of batch %s settling %s, want batch %s settling D0000000000002200", msg.Get(clients.FieldBatchNumber), msg.Get(clients.FieldNetSettlementAmount), open.Number)
+	}
+}
@@ -2,21 +2,70 @@ package services
 
 import (
 	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
 )
 
 type (
 	CancellactionService interface {
-		Process(ctx context.Context, req any) (any, error)
+		Process(ctx context.Context, req *models.CancellationRequest) (*models.CancellationResponse, error)
 	}
 
 	cancellationService struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+		locks        *TransactionLocks
 	}
 )
 
-func NewCancellationService() CancellactionService {
-	return &cancellationService{}
+func NewCancellationService(gateways *clients.Router, transactions repositories.TransactionRepository, locks *TransactionLocks) CancellactionService {
+	return &cancellationService{gateways, transactions, locks}
 }
 
-func (s *cancellationService) Process(ctx context.Context, req any) (any, error) {
-	return nil, nil
+func (s *cancellationService) Process(ctx context.Context, req *models.CancellationRequest) (_ *models.CancellationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "services.CancellationService.Process", operationAttributes("cancellation", req.MerchantID, req.TerminalID)...)
+	defer tracing.End(span, &err)
+
+	if err := checkMerchant(ctx, req.MerchantID); err != nil {
+		return nil, err
+	}
+
+	unlock := s.locks.lock(req.OriginalTransactionID)
+	defer unlock()
+
+	request := financialRequest{
+		MTI:            req.Mti,
+		ProcessingCode: req.ProcessingCode,
+		Amount:         req.Amount,
+		EntryMode:      req.EntryMode,
+		Track2:         req.Track2,
+		TerminalID:     req.TerminalID,
+		MerchantID:     req.MerchantID,
+		Currency:       req.Currency,
+		STAN:           req.STAN,
+	}
+
+	gateways, err := routeFollowUp(ctx, s.gateways, s.transactions, request, req.OriginalTransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, resp, err := exchange(ctx, gateways, s.transactions, "cancellation", req.OriginalTransactionID, newFinancialMessage(ctx, request))
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+
+	responseCode := resp.Get(clients.FieldResponseCode)
+	span.SetAttributes(attribute.String("iso.response_code", responseCode))
+	metrics.ResponseCodes.WithLabelValues("cancellation", responseCode).Inc()
+	logger.Component(ctx, logger.ComponentService).WithField("response_code", responseCode).Info("cancellation processed")
+
+	return &models.CancellationResponse{TransactionID: tx.ID, ResponseCode: responseCode, STAN: tx.STAN, RRN: tx.RRN}, nil
 }
@@ -1,19 +1,71 @@
 package services
 
-import "context"
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
 
 type (
 	ConfirmationService interface {
-		Process(ctx context.Context, req any) (any, error)
+		Process(ctx context.Context, req *models.ConfirmationRequest) (*models.ConfirmationResponse, error)
 	}
 
-	confirmationService struct{}
+	confirmationService struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+		locks        *TransactionLocks
+	}
 )
 
-func NewConfirmationService() ConfirmationService {
-	return &confirmationService{}
+func NewConfirmationService(gateways *clients.Router, transactions repositories.TransactionRepository, locks *TransactionLocks) ConfirmationService {
+	return &confirmationService{gateways, transactions, locks}
 }
 
-func (s *confirmationService) Process(ctx context.Context, req any) (any, error) {
-	return nil, nil
+func (s *confirmationService) Process(ctx context.Context, req *models.ConfirmationRequest) (_ *models.ConfirmationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "services.ConfirmationService.Process", operationAttributes("confirmation", req.MerchantID, req.TerminalID)...)
+	defer tracing.End(span, &err)
+
+	if err := checkMerchant(ctx, req.MerchantID); err != nil {
+		return nil, err
+	}
+
+	unlock := s.locks.lock(req.OriginalTransactionID)
+	defer unlock()
+
+	request := financialRequest{
+		MTI:            req.Mti,
+		ProcessingCode: req.ProcessingCode,
+		Amount:         req.Amount,
+		EntryMode:      req.EntryMode,
+		Track2:         req.Track2,
+		TerminalID:     req.TerminalID,
+		MerchantID:     req.MerchantID,
+		Currency:       req.Currency,
+		STAN:           req.STAN,
+	}
+
+	gateways, err := routeFollowUp(ctx, s.gateways, s.transactions, request, req.OriginalTransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, resp, err := exchange(ctx, gateways, s.transactions, "confirmation", req.OriginalTransactionID, newFinancialMessage(ctx, request))
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+
+	responseCode := resp.Get(clients.FieldResponseCode)
+	span.SetAttributes(attribute.String("iso.response_code", responseCode))
+	metrics.ResponseCodes.WithLabelValues("confirmation", responseCode).Inc()
+	logger.Component(ctx, logger.ComponentService).WithField("response_code", responseCode).Info("confirmation processed")
+
+	return &models.ConfirmationResponse{TransactionID: tx.ID, ResponseCode: responseCode, STAN: tx.STAN, RRN: tx.RRN}, nil
 }
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+)
+
+type (
+	// DomainError is a business rule violation that the HTTP layer reports with a
+	// specific status code instead of a generic internal error. ResponseCode is
+	// the ISO 8583 response code returned to the terminal, when there is one.
+	DomainError struct {
+		StatusCode   int
+		Message      string
+		ResponseCode string
+	}
+)
+
+var (
+	ErrUnauthenticated             = &DomainError{StatusCode: http.StatusUnauthorized, Message: "request is not authenticated"}
+	ErrInvalidAPIKey               = &DomainError{StatusCode: http.StatusUnauthorized, Message: "invalid api key"}
+	ErrMerchantMismatch            = &DomainError{StatusCode: http.StatusForbidden, Message: "merchant_id does not match the authenticated merchant"}
+	ErrAPIKeyNotFound              = &DomainError{StatusCode: http.StatusNotFound, Message: "api key not found"}
+	ErrTooManyActiveKeys           = &DomainError{StatusCode: http.StatusConflict, Message: "merchant already has the maximum number of active api keys, use rotate"}
+	ErrAPIKeyAlreadyRevoked        = &DomainError{StatusCode: http.StatusConflict, Message: "api key already revoked"}
+	ErrGatewayUnavailable          = &DomainError{StatusCode: http.StatusServiceUnavailable, Message: "gateway unavailable", ResponseCode: "91"}
+	ErrOriginalTransactionRequired = &DomainError{StatusCode: http.StatusUnprocessableEntity, Message: "original_transaction_id is required", ResponseCode: "12"}
+	ErrOriginalTransactionNotFound = &DomainError{StatusCode: http.StatusNotFound, Message: "original transaction not found"}
+	ErrPaymentNotFound             = &DomainError{StatusCode: http.StatusNotFound, Message: "payment not found"}
+	ErrRefundNotAllowed            = &DomainError{StatusCode: http.StatusUnprocessableEntity, Message: "original transaction is not a captured payment", ResponseCode: "12"}
+	ErrRefundAmountExceeded        = &DomainError{StatusCode: http.StatusUnprocessableEntity, Message: "amount exceeds the captured amount left to refund", ResponseCode: "13"}
+	ErrIncrementNotAllowed         = &DomainError{StatusCode: http.StatusUnprocessableEntity, Message: "original transaction is not a pre-authorization holding its amount", ResponseCode: "12"}
+	ErrCardMismatch                = &DomainError{StatusCode: http.StatusUnprocessableEntity, Message: "card does not match the original transaction", ResponseCode: "12"}
+	ErrCurrencyMismatch            = &DomainError{StatusCode: http.StatusUnprocessableEntity, Message: "currency does not match the original transaction", ResponseCode: "12"}
+	ErrInvalidAmount               = &DomainError{StatusCode: http.StatusUnprocessableEntity, Message: "amount must be greater than zero", ResponseCode: "13"}
+	ErrBatchInFlight               = &DomainError{StatusCode: http.StatusConflict, Message: "transactions of the terminal are still waiting for the gateway, close the batch again later"}
+	ErrBatchOpen                   = &DomainError{StatusCode: http.StatusConflict, Message: "a batch of the terminal is still being reconciled, close the batch again later"}
+	ErrInvalidCursor               = &DomainError{StatusCode: http.StatusBadRequest, Message: "invalid cursor, it must come from a search with the same sort"}
+)
+
+func (e *DomainError) Error() string {
+	return e.Message
+}
+
+// HTTPStatus exposes the status code to the response builder.
+func (e *DomainError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// ErrorDetails exposes the ISO response code to the response builder.
+func (e *DomainError) ErrorDetails() any {
+	if e.ResponseCode == "" {
+		return nil
+	}
+
+	return map[string]string{"response_code": e.ResponseCode}
+}
+
+// gatewayError maps gateway client failures to domain errors.
+func gatewayError(err error) error {
+	if errors.Is(err, clients.ErrCircuitOpen) {
+		return ErrGatewayUnavailable
+	}
+
+	return err
+}
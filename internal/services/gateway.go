@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+type (
+	// financialRequest holds the fields common to every financial operation body.
+	financialRequest struct {
+		MTI            string
+		ProcessingCode string
+		Amount         string
+		EntryMode      string
+		Track2         string
+		TerminalID     string
+		MerchantID     string
+		Currency       string
+		STAN           string // Given by the terminal, generated when empty.
+		MCC            string
+	}
+)
+
+// newRRN builds the twelve character Retrieval Reference Number from the
+// julian date, the hour and the STAN.
+func newRRN(now time.Time, stan string) string {
+	return fmt.Sprintf("%d%03d%02d%s", now.Year()%10, now.YearDay(), now.Hour(), stan)
+}
+
+// operationAttributes are the span attributes shared by the financial operations.
+func operationAttributes(operation, merchantID, terminalID string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("payment.operation", operation),
+		attribute.String("merchant.id", merchantID),
+		attribute.String("terminal.id", terminalID),
+	}
+}
+
+// newFinancialMessage maps a financial operation body to its ISO 8583 message
+// and adds the terminal, STAN and RRN to the request log fields. The STAN of the
+// terminal is kept so it can look the payment up, one is generated otherwise.
+func newFinancialMessage(ctx context.Context, req financialRequest) *clients.Message {
+	now := time.Now().UTC()
+	stan := req.STAN
+	if stan == "" {
+		stan = clients.NextSTAN()
+	}
+	rrn := newRRN(now, stan)
+
+	logger.AddFields(ctx, logrus.Fields{"terminal_id": req.TerminalID, "stan": stan, "rrn": rrn})
+
+	msg := clients.NewMessage(req.MTI).
+		Set(clients.FieldProcessingCode, req.ProcessingCode).
+		Set(clients.FieldAmount, req.Amount).
+		Set(clients.FieldTransmissionDateTime, now.Format("0102150405")).
+		Set(clients.FieldSTAN, stan).
+		Set(clients.FieldLocalTime, now.Format("150405")).
+		Set(clients.FieldLocalDate, now.Format("0102")).
+		Set(clients.FieldEntryMode, req.EntryMode).
+		Set(clients.FieldTrack2, req.Track2).
+		Set(clients.FieldRRN, rrn).
+		Set(clients.FieldTerminalID, req.TerminalID).
+		Set(clients.FieldMerchantID, req.MerchantID)
+
+	if req.Currency != "" {
+		msg.Set(clients.FieldCurrency, req.Currency)
+	}
+	if req.MCC != "" {
+		msg.Set(clients.FieldMerchantType, req.MCC)
+	}
+
+	return msg
+}
+
+// route returns the gateways of an authorization or a pre-authorization: the one
+// selected by the routing rules, then, for authorizations, the failover gateway
+// when there is one.
+func route(router *clients.Router, operation string, req financialRequest) []*clients.Gateway {
+	primary, failover := router.Route(clients.Payment{
+		PAN:        clients.PANFromTrack2(req.Track2),
+		MerchantID: req.MerchantID,
+		Currency:   req.Currency,
+		Operation:  operation,
+	})
+
+	if failover == nil {
+		return []*clients.Gateway{primary}
+	}
+
+	return []*clients.Gateway{primary, failover}
+}
+
+// routeFollowUp returns the gateway of a follow-up operation: the one that handled
+// the original transaction. Follow-ups never fail over.
+func routeFollowUp(
+	ctx context.Context,
+	router *clients.Router,
+	transactions repositories.TransactionRepository,
+	req financialRequest,
+	originalID string,
+) ([]*clients.Gateway, error) {
+	if originalID == "" {
+		return nil, ErrOriginalTransactionRequired
+	}
+
+	original, err := transactions.FindByID(ctx, originalID)
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && original.MerchantID != req.MerchantID) {
+		return nil, ErrOriginalTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := router.Gateway(original.Gateway)
+	if gateway == nil {
+		logger.Component(ctx, logger.ComponentService).WithField("gateway", original.Gateway).Error("gateway of the original transaction is no longer configured")
+		return nil, ErrGatewayUnavailable
+	}
+
+	return []*clients.Gateway{gateway}, nil
+}
+
+// panLast4 returns the last four digits of the card number of track2.
+func panLast4(track2 string) string {
+	pan := clients.PANFromTrack2(track2)
+	if len(pan) < 4 {
+		return ""
+	}
+
+	return pan[len(pan)-4:]
+}
+
+// originalData builds field 90 of the messages referring to tx: its MTI, STAN
+// and transmission date and time, the acquirer and forwarding IDs left zero.
+func originalData(tx *models.Transaction) string {
+	return tx.MTI + tx.STAN + tx.TransmissionDateTime + "0000000000000000000000"
+}
+
+// newTransactionID returns a random 32 hex characters transaction ID.
+func newTransactionID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// exchange persists the transaction of msg as pending, sends msg to the first of
+// gateways and records the outcome. The next gateway, the failover one, is only
+// tried when the message never reached the previous one. An exchange that failed
+// after the message may have reached the gateway is marked unresolved so the
+// ReversalWorker reverses it. A response arriving after the transaction was marked
+// unresolved on shutdown is returned but the transaction stays unresolved, since
+// the client connection is gone by then.
+func exchange(
+	ctx context.Context,
+	gateways []*clients.Gateway,
+	transactions repositories.TransactionRepository,
+	operation string,
+	originalID string,
+	msg *clients.Message,
+) (*models.Transaction, *clients.Message, error) {
+	now := time.Now().UTC()
+	tx := &models.Transaction{
+		ID:                    newTransactionID(),
+		Operation:             operation,
+		MTI:                   msg.MTI,
+		ProcessingCode:        msg.Get(clients.FieldProcessingCode),
+		Amount:                msg.Get(clients.FieldAmount),
+		EntryMode:             msg.Get(clients.FieldEntryMode),
+		TerminalID:            msg.Get(clients.FieldTerminalID),
+		MerchantID:            msg.Get(clients.FieldMerchantID),
+		PANLast4:              panLast4(msg.Get(clients.FieldTrack2)),
+		Currency:              msg.Get(clients.FieldCurrency),
+		MCC:                   msg.Get(clients.FieldMerchantType),
+		Gateway:               gateways[0].Name,
+		OriginalTransactionID: originalID,
+		STAN:                  msg.Get(clients.FieldSTAN),
+		RRN:                   msg.Get(clients.FieldRRN),
+		TransmissionDateTime:  msg.Get(clients.FieldTransmissionDateTime),
+		Status:                models.TransactionPending,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+
+	if err := transactions.Create(ctx, tx); err != nil {
+		return nil, nil, err
+	}
+
+	logger.AddFields(ctx, logrus.Fields{"transaction_id": tx.ID, "gateway": tx.Gateway})
+
+	var (
+		resp    *clients.Message
+		sendErr error
+	)
+	for i, gateway := range gateways {
+		if i > 0 {
+			logger.Component(ctx, logger.ComponentService).WithError(sendErr).WithField("failover", gateway.Name).Warn("gateway unreachable, failing over")
+
+			// The new destination is recorded first so a crash reverses the
+			// transaction on the gateway it was sent to.
+			previous := tx.Gateway
+			tx.Gateway = gateway.Name
+			tx.UpdatedAt = time.Now().UTC()
+			if err := transactions.Update(ctx, tx, models.TransactionPending); err != nil {
+				tx.Gateway = previous
+				break
+			}
+			logger.AddFields(ctx, logrus.Fields{"gateway": tx.Gateway})
+		}
+
+		resp, sendErr = gateway.Client.Send(ctx, msg)
+		if sendErr == nil || clients.MaybeDelivered(sendErr) {
+			break
+		}
+	}
+
+	switch {
+	case clients.MaybeDelivered(sendErr):
+		tx.Status = models.TransactionUnresolved
+	case sendErr != nil:
+		tx.Status = models.TransactionFailed
+	case resp.Get(clients.FieldResponseCode) == "00":
+		tx.Status = models.TransactionApproved
+	default:
+		tx.Status = models.TransactionDeclined
+	}
+
+	if resp != nil {
+		tx.ResponseCode = resp.Get(clients.FieldResponseCode)
+		tx.AuthorizationCode = resp.Get(clients.FieldAuthorizationCode)
+	}
+	tx.UpdatedAt = time.Now().UTC()
+
+	// The request context may be canceled already, the outcome must still be recorded.
+	err := transactions.Update(context.WithoutCancel(ctx), tx, models.TransactionPending)
+	switch {
+	case errors.Is(err, repositories.ErrConflict):
+		logger.Component(ctx, logger.ComponentService).WithField("status", tx.Status).Warn("gateway answered after the transaction was marked unresolved, keeping it queued for reversal")
+	case err != nil:
+		logger.Component(ctx, logger.ComponentService).WithError(err).Error("failed to record the transaction outcome")
+	case tx.Status == models.TransactionUnresolved:
+		logger.Component(ctx, logger.ComponentService).WithError(sendErr).Warn("gateway outcome unknown, transaction queued for reversal")
+	}
+
+	return tx, resp, sendErr
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
+
+type (
+	IncrementalAuthorizationService interface {
+		Process(ctx context.Context, req *models.IncrementalAuthorizationRequest) (*models.IncrementalAuthorizationResponse, error)
+	}
+
+	incrementalAuthorizationService struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+		locks        *TransactionLocks
+	}
+)
+
+func NewIncrementalAuthorizationService(gateways *clients.Router, transactions repositories.TransactionRepository, locks *TransactionLocks) IncrementalAuthorizationService {
+	return &incrementalAuthorizationService{gateways, transactions, locks}
+}
+
+// Process adds req.Amount to the amount held by the original pre-authorization.
+// The increment is sent with the MTI and processing code of the pre-authorization,
+// which field 90 refers to, and its increments are processed one at a time.
+func (s *incrementalAuthorizationService) Process(ctx context.Context, req *models.IncrementalAuthorizationRequest) (_ *models.IncrementalAuthorizationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "services.IncrementalAuthorizationService.Process", operationAttributes("incremental_authorization", req.MerchantID, req.TerminalID)...)
+	defer tracing.End(span, &err)
+
+	if err := checkMerchant(ctx, req.MerchantID); err != nil {
+		return nil, err
+	}
+
+	unlock := s.locks.lock(req.OriginalTransactionID)
+	defer unlock()
+
+	original, err := s.transactions.FindByID(ctx, req.OriginalTransactionID)
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && original.MerchantID != req.MerchantID) {
+		return nil, ErrOriginalTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	followUps, err := s.transactions.ListFollowUps(ctx, original.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := newPayment(original, followUps)
+	if original.Operation != "pre_authorization" || payment.State != models.PaymentAuthorized {
+		return nil, ErrIncrementNotAllowed
+	}
+	if original.PANLast4 != "" && panLast4(req.Track2) != original.PANLast4 {
+		return nil, ErrCardMismatch
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = original.Currency
+	}
+	if currency != original.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	amount := parseAmount(req.Amount)
+	if amount == 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	request := financialRequest{
+		MTI:            original.MTI,
+		ProcessingCode: original.ProcessingCode,
+		Amount:         formatAmount(amount),
+		EntryMode:      req.EntryMode,
+		Track2:         req.Track2,
+		TerminalID:     req.TerminalID,
+		MerchantID:     req.MerchantID,
+		Currency:       currency,
+		STAN:           req.STAN,
+		MCC:            original.MCC,
+	}
+
+	gateways, err := routeFollowUp(ctx, s.gateways, s.transactions, request, original.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := newFinancialMessage(ctx, request).Set(clients.FieldOriginalData, originalData(original))
+
+	tx, resp, err := exchange(ctx, gateways, s.transactions, "incremental_authorization", original.ID, msg)
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+
+	held := parseAmount(payment.HeldAmount)
+	if tx.Status == models.TransactionApproved {
+		held += amount
+	}
+
+	responseCode := resp.Get(clients.FieldResponseCode)
+	span.SetAttributes(attribute.String("iso.response_code", responseCode))
+	metrics.ResponseCodes.WithLabelValues("incremental_authorization", responseCode).Inc()
+	logger.Component(ctx, logger.ComponentService).WithField("response_code", responseCode).Info("incremental authorization processed")
+
+	return &models.IncrementalAuthorizationResponse{TransactionID: tx.ID, ResponseCode: responseCode, STAN: tx.STAN, RRN: tx.RRN, HeldAmount: formatAmount(held)}, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+)
+
+func TestIncrementalAuthorizationHeldAmount(t *testing.T) {
+	preAuthorization := &models.Transaction{
+		ID:             "original",
+		Operation:      "pre_authorization",
+		MTI:            "0100",
+		ProcessingCode: "000000",
+		MerchantID:     testMerchant,
+		Gateway:        testGateway,
+		Amount:         "000000001000",
+		Currency:       "986",
+		MCC:            "7011",
+		STAN:           "000001",
+		Status:         models.TransactionApproved,
+	}
+	authorization := *preAuthorization
+	authorization.Operation = "authorization"
+	declined := *preAuthorization
+	declined.Status = models.TransactionDeclined
+	expired := *preAuthorization
+	expired.ExpiredAt = &time.Time{}
+
+	tests := []struct {
+		name         string
+		original     *models.Transaction
+		followUps    []*models.Transaction
+		amount       string
+		responseCode string
+		wantErr      error
+		wantHeld     string
+	}{
+		{
+			name:         "first increment",
+			original:     preAuthorization,
+			amount:       "500",
+			responseCode: "00",
+			wantHeld:     "000000001500",
+		},
+		{
+			name:     "increments accumulate",
+			original: preAuthorization,
+			followUps: []*models.Transaction{
+				followUp("i1", "incremental_authorization", models.TransactionApproved, "000000000200"),
+				followUp("i2", "incremental_authorization", models.TransactionApproved, "000000000300"),
+			},
+			amount:       "500",
+			responseCode: "00",
+			wantHeld:     "000000002000",
+		},
+		{
+			name:     "increments not approved are not held",
+			original: preAuthorization,
+			followUps: []*models.Transaction{
+				followUp("i1", "incremental_authorization", models.TransactionDeclined, "000000000200"),
+				followUp("i2", "incremental_authorization", models.TransactionFailed, "000000000300"),
+				followUp("i3", "incremental_authorization", models.TransactionReversed, "000000000400"),
+			},
+			amount:       "500",
+			responseCode: "00",
+			wantHeld:     "000000001500",
+		},
+		{
+			name:         "declined increment keeps the held amount",
+			original:     preAuthorization,
+			amount:       "500",
+			responseCode: "51",
+			wantHeld:     "000000001000",
+		},
+		{
+			name:     "confirmed pre-authorization",
+			original: preAuthorization,
+			followUps: []*models.Transaction{
+				followUp("c1", "confirmation", models.TransactionApproved, "000000001000"),
+			},
+			amount:  "500",
+			wantErr: ErrIncrementNotAllowed,
+		},
+		{
+			name:     "canceled pre-authorization",
+			original: preAuthorization,
+			followUps: []*models.Transaction{
+				followUp("c1", "cancellation", models.TransactionApproved, "000000001000"),
+			},
+			amount:  "500",
+			wantErr: ErrIncrementNotAllowed,
+		},
+		{
+			name:     "expired pre-authorization",
+			original: &expired,
+			amount:   "500",
+			wantErr:  ErrIncrementNotAllowed,
+		},
+		{
+			name:     "declined pre-authorization",
+			original: &declined,
+			amount:   "500",
+			wantErr:  ErrIncrementNotAllowed,
+		},
+		{
+			name:     "authorization",
+			original: &authorization,
+			amount:   "500",
+			wantErr:  ErrIncrementNotAllowed,
+		},
+		{
+			name:     "zero amount",
+			original: preAuthorization,
+			amount:   "0",
+			wantErr:  ErrInvalidAmount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := *tt.original
+			transactions := newTestTransactions(t, append([]*models.Transaction{&original}, tt.followUps...)...)
+			gateway := &fakeGateway{reply: replyWith(tt.responseCode, nil)}
+			service := NewIncrementalAuthorizationService(newTestRouter(gateway, &fakeGateway{}), transactions, NewTransactionLocks())
+
+			resp, err := service.Process(merchantContext(testMerchant), &models.IncrementalAuthorizationRequest{
+				Amount:                tt.amount,
+				EntryMode:             "051",
+				Track2:                testTrack2,
+				TerminalID:            "T1",
+				MerchantID:            testMerchant,
+				OriginalTransactionID: original.ID,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if sent := gateway.sentMTIs(); len(sent) > 0 {
+					t.Fatalf("sent %v to the gateway, want nothing", sent)
+				}
+				return
+			}
+
+			if resp.HeldAmount != tt.wantHeld {
+				t.Fatalf("held = %s, want %s", resp.HeldAmount, tt.wantHeld)
+			}
+
+			// The increment is sent as the pre-authorization it refers to.
+			msg := gateway.sent[0]
+			if msg.MTI != original.MTI || msg.Get(clients.FieldProcessingCode) != original.ProcessingCode {
+				t.Errorf("sent %s %s, want %s %s", msg.MTI, msg.Get(clients.FieldProcessingCode), original.MTI, original.ProcessingCode)
+			}
+			if data := msg.Get(clients.FieldOriginalData); data != originalData(&original) {
+				t.Errorf("original data = %s, want %s", data, originalData(&original))
+			}
+			if amount := msg.Get(clients.FieldAmount); amount != formatAmount(parseAmount(tt.amount)) {
+				t.Errorf("amount sent = %s, want %s", amount, formatAmount(parseAmount(tt.amount)))
+			}
+		})
+	}
+}
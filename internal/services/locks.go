@@ -0,0 +1,61 @@
+package services
+
+import "sync"
+
+type (
+	// keyedLocks holds one mutex per key, released once nobody holds or waits for it.
+	keyedLocks struct {
+		mu    sync.Mutex
+		locks map[string]*keyedLock
+	}
+
+	keyedLock struct {
+		sync.Mutex
+		refs int
+	}
+
+	// TransactionLocks serializes the operations on a transaction, keyed by its ID:
+	// the refunds, incremental authorizations, confirmations and cancellations
+	// referring to it and the expiry of a pre-authorization. One instance must be
+	// shared by all of them.
+	TransactionLocks struct {
+		locks *keyedLocks
+	}
+)
+
+func NewTransactionLocks() *TransactionLocks {
+	return &TransactionLocks{locks: newKeyedLocks()}
+}
+
+// lock locks the transaction originalID and returns its unlock function.
+func (l *TransactionLocks) lock(originalID string) func() {
+	return l.locks.lock(originalID)
+}
+
+func newKeyedLocks() *keyedLocks {
+	return &keyedLocks{locks: map[string]*keyedLock{}}
+}
+
+// lock locks the mutex of key and returns its unlock function.
+func (l *keyedLocks) lock(key string) func() {
+	l.mu.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		l.mu.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"context"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+)
+
+// checkMerchant ensures the merchant_id sent in the body belongs to the merchant
+// authenticated for the request.
+func checkMerchant(ctx context.Context, merchantID string) error {
+	authenticated := auth.MerchantFromContext(ctx)
+	if authenticated == "" {
+		return ErrUnauthenticated
+	}
+
+	if authenticated != merchantID {
+		return ErrMerchantMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
+
+// defaultSearchLimit is the page size of the searches not giving one.
+const defaultSearchLimit = 50
+
+type (
+	// PaymentService answers the status queries of the terminals, limited to the
+	// payments of the authenticated merchant.
+	PaymentService interface {
+		Get(ctx context.Context, id string) (*models.Payment, error)
+		// Lookup finds a payment of the authenticated merchant by terminal ID and
+		// STAN, created on the UTC date of day.
+		Lookup(ctx context.Context, terminalID, stan string, day time.Time) (*models.Payment, error)
+		// Search lists the transactions matching req, a page at a time.
+		Search(ctx context.Context, req *models.PaymentSearchRequest) (*models.PaymentPage, error)
+	}
+
+	paymentService struct {
+		transactions repositories.TransactionRepository
+	}
+)
+
+func NewPaymentService(transactions repositories.TransactionRepository) PaymentService {
+	return &paymentService{transactions}
+}
+
+func (s *paymentService) Get(ctx context.Context, id string) (_ *models.Payment, err error) {
+	ctx, span := tracing.Start(ctx, "services.PaymentService.Get", attribute.String("transaction.id", id))
+	defer tracing.End(span, &err)
+
+	if auth.MerchantFromContext(ctx) == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	tx, err := s.transactions.FindByID(ctx, id)
+
+	return s.payment(ctx, tx, err)
+}
+
+func (s *paymentService) Lookup(ctx context.Context, terminalID, stan string, day time.Time) (_ *models.Payment, err error) {
+	ctx, span := tracing.Start(ctx, "services.PaymentService.Lookup", attribute.String("terminal.id", terminalID), attribute.String("iso.stan", stan))
+	defer tracing.End(span, &err)
+
+	merchantID := auth.MerchantFromContext(ctx)
+	if merchantID == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	tx, err := s.transactions.FindBySTAN(ctx, merchantID, terminalID, stan, day)
+
+	return s.payment(ctx, tx, err)
+}
+
+func (s *paymentService) Search(ctx context.Context, req *models.PaymentSearchRequest) (_ *models.PaymentPage, err error) {
+	ctx, span := tracing.Start(ctx, "services.PaymentService.Search")
+	defer tracing.End(span, &err)
+
+	merchantID := auth.MerchantFromContext(ctx)
+	if merchantID == "" {
+		return nil, ErrUnauthenticated
+	}
+	if req.MerchantID != "" && req.MerchantID != merchantID {
+		return nil, ErrMerchantMismatch
+	}
+
+	query := repositories.TransactionQuery{
+		MerchantID:   merchantID,
+		TerminalID:   req.TerminalID,
+		Operations:   req.Operations,
+		CreatedFrom:  req.From,
+		CreatedTo:    req.To,
+		MinAmount:    parseAmount(req.MinAmount),
+		MaxAmount:    parseAmount(req.MaxAmount),
+		PANLast4:     req.PANLast4,
+		ResponseCode: req.ResponseCode,
+		SortBy:       repositories.SortByCreatedAt,
+		Descending:   true,
+		Cursor:       req.Cursor,
+		Limit:        req.Limit,
+	}
+	for _, status := range req.Statuses {
+		query.Statuses = append(query.Statuses, models.TransactionStatus(status))
+	}
+	if req.Sort != "" {
+		field, descending := strings.CutPrefix(req.Sort, "-")
+		query.SortBy, query.Descending = repositories.TransactionSortField(field), descending
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultSearchLimit
+	}
+
+	page, err := s.transactions.Search(ctx, query)
+	if errors.Is(err, repositories.ErrInvalidCursor) {
+		return nil, ErrInvalidCursor
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PaymentPage{Transactions: make([]models.PaymentSummary, 0, len(page.Transactions)), NextCursor: page.NextCursor}
+	for _, tx := range page.Transactions {
+		result.Transactions = append(result.Transactions, models.PaymentSummary{
+			TransactionID:         tx.ID,
+			Operation:             tx.Operation,
+			Status:                tx.Status,
+			ResponseCode:          tx.ResponseCode,
+			AuthorizationCode:     tx.AuthorizationCode,
+			MerchantID:            tx.MerchantID,
+			TerminalID:            tx.TerminalID,
+			STAN:                  tx.STAN,
+			RRN:                   tx.RRN,
+			PANLast4:              tx.PANLast4,
+			Amount:                tx.Amount,
+			Currency:              tx.Currency,
+			OriginalTransactionID: tx.OriginalTransactionID,
+			CreatedAt:             tx.CreatedAt,
+			UpdatedAt:             tx.UpdatedAt,
+		})
+	}
+
+	return result, nil
+}
+
+// payment builds the payment of tx, found with err. Transactions of other
+// merchants are reported as not found, so their IDs disclose nothing.
+func (s *paymentService) payment(ctx context.Context, tx *models.Transaction, err error) (*models.Payment, error) {
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && tx.MerchantID != auth.MerchantFromContext(ctx)) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	followUps, err := s.transactions.ListFollowUps(ctx, tx.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPayment(tx, followUps), nil
+}
+
+// newPayment derives the lifecycle state and the amounts of tx from its approved
+// follow-up operations. An approved cancellation or reversal voids the payment,
+// and a pre-authorization marked expired stays expired unless it was captured.
+func newPayment(tx *models.Transaction, followUps []*models.Transaction) *models.Payment {
+	payment := &models.Payment{
+		TransactionID:     tx.ID,
+		Operation:         tx.Operation,
+		State:             models.PaymentState(tx.Status),
+		ResponseCode:      tx.ResponseCode,
+		AuthorizationCode: tx.AuthorizationCode,
+		MerchantID:        tx.MerchantID,
+		TerminalID:        tx.TerminalID,
+		STAN:              tx.STAN,
+		RRN:               tx.RRN,
+		PANLast4:          tx.PANLast4,
+		Amount:            tx.Amount,
+		Currency:          tx.Currency,
+		CreatedAt:         tx.CreatedAt,
+		UpdatedAt:         tx.UpdatedAt,
+		ExpiredAt:         tx.ExpiredAt,
+		History:           []models.PaymentEvent{newPaymentEvent(tx)},
+	}
+
+	var (
+		held, captured, refunded int64
+		voided                   models.PaymentState
+	)
+
+	approved := tx.Status == models.TransactionApproved
+	switch {
+	case approved && tx.Operation == "authorization":
+		captured = parseAmount(tx.Amount)
+	case approved && tx.Operation == "pre_authorization":
+		held = parseAmount(tx.Amount)
+	}
+
+	for _, followUp := range followUps {
+		payment.History = append(payment.History, newPaymentEvent(followUp))
+
+		if !approved || followUp.Status != models.TransactionApproved {
+			continue
+		}
+
+		switch followUp.Operation {
+		case "incremental_authorization":
+			held += parseAmount(followUp.Amount)
+		case "confirmation":
+			captured += parseAmount(followUp.Amount)
+		case "refund":
+			refunded += parseAmount(followUp.Amount)
+		case "cancellation":
+			voided = models.PaymentCanceled
+		case "reversal":
+			voided = models.PaymentReversed
+		}
+	}
+
+	if approved && (tx.Operation == "authorization" || tx.Operation == "pre_authorization") {
+		switch {
+		case tx.ExpiredAt != nil && captured == 0:
+			payment.State = models.PaymentExpired
+		case voided != "":
+			payment.State = voided
+		case captured > 0 && refunded >= captured:
+			payment.State = models.PaymentRefunded
+		case refunded > 0:
+			payment.State = models.PaymentPartiallyRefunded
+		case captured > 0:
+			payment.State = models.PaymentCaptured
+		default:
+			payment.State = models.PaymentAuthorized
+		}
+	}
+
+	if held > 0 {
+		payment.HeldAmount = formatAmount(held)
+	}
+	payment.CapturedAmount = formatAmount(captured)
+	payment.RefundedAmount = formatAmount(refunded)
+
+	return payment
+}
+
+func newPaymentEvent(tx *models.Transaction) models.PaymentEvent {
+	return models.PaymentEvent{
+		TransactionID:     tx.ID,
+		Operation:         tx.Operation,
+		Status:            tx.Status,
+		ResponseCode:      tx.ResponseCode,
+		AuthorizationCode: tx.AuthorizationCode,
+		Amount:            tx.Amount,
+		CreatedAt:         tx.CreatedAt,
+		UpdatedAt:         tx.UpdatedAt,
+	}
+}
+
+// parseAmount reads an ISO 8583 amount, in minor units; malformed amounts count as zero.
+func parseAmount(amount string) int64 {
+	value, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil || value < 0 {
+		return 0
+	}
+
+	return value
+}
+
+// formatAmount writes an amount in minor units as the twelve digits of field 4.
+func formatAmount(amount int64) string {
+	return fmt.Sprintf("%012d", amount)
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+)
+
+type (
+	// PreAuthExpiryPolicy sets how long an approved pre-authorization holds its
+	// amount, counted from its last approved authorization, incremental ones included.
+	PreAuthExpiryPolicy struct {
+		Window  time.Duration            // Window of the merchant categories not in Windows.
+		Windows map[string]time.Duration // Windows by merchant category code.
+		Release bool                     // Reverse the expired pre-authorizations to release their amount.
+	}
+
+	// PreAuthExpiryWorker marks expired the approved pre-authorizations neither
+	// captured nor voided within the window of their merchant category. With Release
+	// set it then sends a 0400 for the amount held to the gateway of each one, as a
+	// reversal referring to it; a release that never reached the gateway is sent
+	// again, one that was declined is only logged.
+	PreAuthExpiryWorker struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+		locks        *TransactionLocks
+		interval     time.Duration
+		policy       PreAuthExpiryPolicy
+
+		stop     chan struct{}
+		done     chan struct{}
+		stopOnce sync.Once
+	}
+)
+
+func NewPreAuthExpiryWorker(gateways *clients.Router, transactions repositories.TransactionRepository, locks *TransactionLocks, interval time.Duration, policy PreAuthExpiryPolicy) *PreAuthExpiryWorker {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	return &PreAuthExpiryWorker{
+		gateways:     gateways,
+		transactions: transactions,
+		locks:        locks,
+		interval:     interval,
+		policy:       policy,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start checks for expired pre-authorizations right away and then every interval until Close.
+func (w *PreAuthExpiryWorker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-w.stop
+		cancel()
+	}()
+
+	go func() {
+		defer close(w.done)
+
+		for {
+			w.ExpirePreAuthorizations(ctx)
+
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(w.interval):
+			}
+		}
+	}()
+}
+
+// Close stops the worker and waits for it to return.
+func (w *PreAuthExpiryWorker) Close() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+// ExpirePreAuthorizations marks expired the pre-authorizations past their window
+// and, with Release set, sends the releases not sent yet. Pre-authorizations with
+// a follow-up operation in flight are left for the next run.
+func (w *PreAuthExpiryWorker) ExpirePreAuthorizations(ctx context.Context) {
+	approved, err := w.transactions.ListByStatus(ctx, models.TransactionApproved)
+	if err != nil {
+		logger.Component(ctx, logger.ComponentService).WithError(err).Error("failed to list approved transactions")
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, tx := range approved {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		if tx.Operation == "pre_authorization" {
+			w.check(ctx, tx, now)
+		}
+	}
+}
+
+// check expires the pre-authorization tx when past its window and sends its
+// release, holding the lock of tx so no follow-up operation changes it meanwhile.
+func (w *PreAuthExpiryWorker) check(ctx context.Context, tx *models.Transaction, now time.Time) {
+	unlock := w.locks.lock(tx.ID)
+	defer unlock()
+
+	followUps, err := w.transactions.ListFollowUps(ctx, tx.ID)
+	if err != nil {
+		transactionEntry(ctx, tx).WithError(err).Error("failed to list the follow-ups of pre-authorization")
+		return
+	}
+	if inFlight(followUps) {
+		return
+	}
+
+	payment := newPayment(tx, followUps)
+	switch {
+	case payment.State == models.PaymentAuthorized && now.Sub(lastAuthorizedAt(tx, followUps)) >= w.policy.window(tx.MCC):
+		if !w.expire(ctx, tx, now) {
+			return
+		}
+		if w.policy.Release {
+			w.release(ctx, tx, payment.HeldAmount)
+		}
+	case payment.State == models.PaymentExpired && w.policy.Release && !released(followUps):
+		w.release(ctx, tx, payment.HeldAmount)
+	}
+}
+
+// expire records tx as expired and reports whether it was.
+func (w *PreAuthExpiryWorker) expire(ctx context.Context, tx *models.Transaction, now time.Time) bool {
+	tx.ExpiredAt = &now
+	tx.UpdatedAt = now
+
+	err := w.transactions.Update(ctx, tx, models.TransactionApproved)
+	if errors.Is(err, repositories.ErrConflict) {
+		return false
+	}
+	if err != nil {
+		transactionEntry(ctx, tx).WithError(err).Error("failed to record the expiry of pre-authorization")
+		return false
+	}
+
+	transactionEntry(ctx, tx).WithField("mcc", tx.MCC).Info("pre-authorization expired")
+	return true
+}
+
+// release reverses the amount held by the expired pre-authorization tx, when the
+// session of its gateway is signed on.
+func (w *PreAuthExpiryWorker) release(ctx context.Context, tx *models.Transaction, held string) {
+	entry := transactionEntry(ctx, tx)
+
+	gateway := w.gateways.Gateway(tx.Gateway)
+	if gateway == nil {
+		entry.Error("gateway of expired pre-authorization is no longer configured, release kept queued")
+		return
+	}
+	if !gateway.Session.SignedOn() {
+		return
+	}
+
+	msg := newReversalMessage(tx).Set(clients.FieldAmount, held)
+
+	reversal, resp, err := exchange(ctx, []*clients.Gateway{gateway}, w.transactions, "reversal", tx.ID, msg)
+	if err != nil {
+		entry.WithError(err).Warn("release of expired pre-authorization failed, will retry")
+		return
+	}
+
+	metrics.Reversals.WithLabelValues("expired").Inc()
+
+	responseCode := resp.Get(clients.FieldResponseCode)
+	if reversal.Status != models.TransactionApproved {
+		entry.WithField("release_response_code", responseCode).Warn("release of expired pre-authorization declined")
+		return
+	}
+
+	entry.WithField("release_response_code", responseCode).Info("expired pre-authorization released")
+}
+
+// window returns the expiry window of the merchant category mcc.
+func (p PreAuthExpiryPolicy) window(mcc string) time.Duration {
+	if window, ok := p.Windows[mcc]; ok {
+		return window
+	}
+
+	return p.Window
+}
+
+// lastAuthorizedAt returns when tx, or its last approved incremental authorization, was approved.
+func lastAuthorizedAt(tx *models.Transaction, followUps []*models.Transaction) time.Time {
+	last := tx.CreatedAt
+	for _, followUp := range followUps {
+		if followUp.Operation == "incremental_authorization" && followUp.Status == models.TransactionApproved && followUp.CreatedAt.After(last) {
+			last = followUp.CreatedAt
+		}
+	}
+
+	return last
+}
+
+// inFlight reports whether a follow-up operation is waiting for its outcome.
+func inFlight(followUps []*models.Transaction) bool {
+	for _, followUp := range followUps {
+		if followUp.Status == models.TransactionPending || followUp.Status == models.TransactionUnresolved {
+			return true
+		}
+	}
+
+	return false
+}
+
+// released reports whether the release of an expired pre-authorization reached
+// its gateway, whatever the answer.
+func released(followUps []*models.Transaction) bool {
+	for _, followUp := range followUps {
+		if followUp.Operation == "reversal" && followUp.Status != models.TransactionFailed {
+			return true
+		}
+	}
+
+	return false
+}
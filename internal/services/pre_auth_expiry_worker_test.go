@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+// newSignedOnRouter routes every operation to gateway, once its session signed on.
+func newSignedOnRouter(t *testing.T, gateway *fakeGateway) *clients.Router {
+	t.Helper()
+
+	session := clients.NewSession(testGateway, gateway, time.Hour)
+	session.Start()
+	t.Cleanup(func() { session.Close(context.Background()) })
+
+	deadline := time.Now().Add(time.Second)
+	for !session.SignedOn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("gateway did not sign on: %v", session.Err())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return clients.NewRouter(configs.RoutingConfig{Default: testGateway}, []*clients.Gateway{{Name: testGateway, Client: gateway, Session: session}})
+}
+
+func TestPreAuthExpiryWorker(t *testing.T) {
+	policy := PreAuthExpiryPolicy{
+		Window:  time.Hour,
+		Windows: map[string]time.Duration{"7011": 72 * time.Hour},
+	}
+
+	// increment returns an incremental authorization approved age ago.
+	increment := func(status models.TransactionStatus, age time.Duration) *models.Transaction {
+		tx := followUp("i1", "incremental_authorization", status, "000000000200")
+		tx.CreatedAt = time.Now().UTC().Add(-age)
+		return tx
+	}
+
+	tests := []struct {
+		name        string
+		mcc         string
+		age         time.Duration
+		expired     bool
+		followUps   []*models.Transaction
+		release     bool
+		wantExpired bool
+		wantRelease string // Amount of the release sent, none when empty.
+	}{
+		{name: "within the default window", mcc: "5411", age: 59 * time.Minute},
+		{name: "past the default window", mcc: "5411", age: 61 * time.Minute, wantExpired: true},
+		{name: "within the window of its category", mcc: "7011", age: 71 * time.Hour},
+		{name: "past the window of its category", mcc: "7011", age: 73 * time.Hour, wantExpired: true},
+		{
+			name:      "an approved increment restarts the window",
+			mcc:       "5411",
+			age:       2 * time.Hour,
+			followUps: []*models.Transaction{increment(models.TransactionApproved, 30*time.Minute)},
+		},
+		{
+			name:        "a declined increment does not restart the window",
+			mcc:         "5411",
+			age:         2 * time.Hour,
+			followUps:   []*models.Transaction{increment(models.TransactionDeclined, 30*time.Minute)},
+			wantExpired: true,
+		},
+		{
+			name:      "left for the next run while a follow-up is in flight",
+			mcc:       "5411",
+			age:       2 * time.Hour,
+			followUps: []*models.Transaction{increment(models.TransactionPending, 30*time.Minute)},
+		},
+		{
+			name:      "confirmed",
+			mcc:       "5411",
+			age:       2 * time.Hour,
+			followUps: []*models.Transaction{followUp("c1", "confirmation", models.TransactionApproved, "000000001000")},
+		},
+		{
+			name:        "released with the increments held",
+			mcc:         "5411",
+			age:         3 * time.Hour,
+			followUps:   []*models.Transaction{increment(models.TransactionApproved, 2*time.Hour)},
+			release:     true,
+			wantExpired: true,
+			wantRelease: "000000001200",
+		},
+		{
+			name:        "release sent again when it never reached the gateway",
+			mcc:         "5411",
+			age:         3 * time.Hour,
+			expired:     true,
+			followUps:   []*models.Transaction{followUp("r1", "reversal", models.TransactionFailed, "000000001000")},
+			release:     true,
+			wantExpired: true,
+			wantRelease: "000000001000",
+		},
+		{
+			name:        "release declined by the gateway is not sent again",
+			mcc:         "5411",
+			age:         3 * time.Hour,
+			expired:     true,
+			followUps:   []*models.Transaction{followUp("r1", "reversal", models.TransactionDeclined, "000000001000")},
+			release:     true,
+			wantExpired: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preAuthorization := &models.Transaction{
+				ID:             "original",
+				Operation:      "pre_authorization",
+				MTI:            "0100",
+				ProcessingCode: "000000",
+				MerchantID:     testMerchant,
+				Gateway:        testGateway,
+				Amount:         "000000001000",
+				MCC:            tt.mcc,
+				Status:         models.TransactionApproved,
+				CreatedAt:      time.Now().UTC().Add(-tt.age),
+			}
+			if tt.expired {
+				expiredAt := time.Now().UTC()
+				preAuthorization.ExpiredAt = &expiredAt
+			}
+
+			transactions := newTestTransactions(t, append([]*models.Transaction{preAuthorization}, tt.followUps...)...)
+			gateway := &fakeGateway{reply: replyWith("00", nil)}
+			router := newSignedOnRouter(t, gateway)
+
+			policy := policy
+			policy.Release = tt.release
+			NewPreAuthExpiryWorker(router, transactions, NewTransactionLocks(), time.Minute, policy).ExpirePreAuthorizations(context.Background())
+
+			got, err := transactions.FindByID(context.Background(), preAuthorization.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expired := got.ExpiredAt != nil; expired != tt.wantExpired {
+				t.Fatalf("expired = %t, want %t", expired, tt.wantExpired)
+			}
+
+			var releases []*clients.Message
+			for _, msg := range gateway.sent {
+				if msg.MTI == "0400" {
+					releases = append(releases, msg)
+				}
+			}
+			switch {
+			case tt.wantRelease == "" && len(releases) > 0:
+				t.Fatalf("sent %d releases, want none", len(releases))
+			case tt.wantRelease != "" && len(releases) != 1:
+				t.Fatalf("sent %d releases, want one", len(releases))
+			case tt.wantRelease != "" && releases[0].Get(clients.FieldAmount) != tt.wantRelease:
+				t.Fatalf("release amount = %s, want %s", releases[0].Get(clients.FieldAmount), tt.wantRelease)
+			}
+		})
+	}
+}
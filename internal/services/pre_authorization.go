@@ -1,19 +1,63 @@
 package services
 
-import "context"
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
 
 type (
 	PreAuthorizationService interface {
-		Process(ctx context.Context, req any) (any, error)
+		Process(ctx context.Context, req *models.PreAuthorizationRequest) (*models.PreAuthorizationResponse, error)
 	}
 
-	preAuthorizationService struct{}
+	preAuthorizationService struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+	}
 )
 
-func NewPreAuthorizationService() PreAuthorizationService {
-	return &preAuthorizationService{}
+func NewPreAuthorizationService(gateways *clients.Router, transactions repositories.TransactionRepository) PreAuthorizationService {
+	return &preAuthorizationService{gateways, transactions}
 }
 
-func (s *preAuthorizationService) Process(ctx context.Context, req any) (any, error) {
-	return nil, nil
+func (s *preAuthorizationService) Process(ctx context.Context, req *models.PreAuthorizationRequest) (_ *models.PreAuthorizationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "services.PreAuthorizationService.Process", operationAttributes("pre_authorization", req.MerchantID, req.TerminalID)...)
+	defer tracing.End(span, &err)
+
+	if err := checkMerchant(ctx, req.MerchantID); err != nil {
+		return nil, err
+	}
+
+	request := financialRequest{
+		MTI:            req.Mti,
+		ProcessingCode: req.ProcessingCode,
+		Amount:         req.Amount,
+		EntryMode:      req.EntryMode,
+		Track2:         req.Track2,
+		TerminalID:     req.TerminalID,
+		MerchantID:     req.MerchantID,
+		Currency:       req.Currency,
+		STAN:           req.STAN,
+		MCC:            req.MCC,
+	}
+
+	tx, resp, err := exchange(ctx, route(s.gateways, "pre_authorization", request), s.transactions, "pre_authorization", "", newFinancialMessage(ctx, request))
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+
+	responseCode := resp.Get(clients.FieldResponseCode)
+	span.SetAttributes(attribute.String("iso.response_code", responseCode))
+	metrics.ResponseCodes.WithLabelValues("pre_authorization", responseCode).Inc()
+	logger.Component(ctx, logger.ComponentService).WithField("response_code", responseCode).Info("pre-authorization processed")
+
+	return &models.PreAuthorizationResponse{TransactionID: tx.ID, ResponseCode: responseCode, STAN: tx.STAN, RRN: tx.RRN}, nil
 }
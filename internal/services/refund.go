@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
+
+const (
+	refundMTI = "0200"
+	// refundProcessingCode is the refund transaction type, 20, from the default
+	// accounts.
+	refundProcessingCode = "200000"
+)
+
+type (
+	RefundService interface {
+		Process(ctx context.Context, req *models.RefundRequest) (*models.RefundResponse, error)
+	}
+
+	refundService struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+		locks        *TransactionLocks
+	}
+)
+
+func NewRefundService(gateways *clients.Router, transactions repositories.TransactionRepository, locks *TransactionLocks) RefundService {
+	return &refundService{gateways, transactions, locks}
+}
+
+// Process refunds req.Amount from the original payment. The refunds of a payment
+// are processed one at a time, so the amount refunded, pending refunds included,
+// never exceeds the captured amount.
+func (s *refundService) Process(ctx context.Context, req *models.RefundRequest) (_ *models.RefundResponse, err error) {
+	ctx, span := tracing.Start(ctx, "services.RefundService.Process", operationAttributes("refund", req.MerchantID, req.TerminalID)...)
+	defer tracing.End(span, &err)
+
+	if err := checkMerchant(ctx, req.MerchantID); err != nil {
+		return nil, err
+	}
+
+	unlock := s.locks.lock(req.OriginalTransactionID)
+	defer unlock()
+
+	original, followUps, err := s.original(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := newPayment(original, followUps)
+	if payment.State != models.PaymentCaptured && payment.State != models.PaymentPartiallyRefunded {
+		return nil, ErrRefundNotAllowed
+	}
+	if original.PANLast4 != "" && panLast4(req.Track2) != original.PANLast4 {
+		return nil, ErrCardMismatch
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = original.Currency
+	}
+	if currency != original.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	amount := parseAmount(req.Amount)
+	captured, refunded := parseAmount(payment.CapturedAmount), parseAmount(payment.RefundedAmount)
+	if amount == 0 {
+		return nil, ErrInvalidAmount
+	}
+	if refunded+inFlightRefunds(followUps)+amount > captured {
+		return nil, ErrRefundAmountExceeded
+	}
+
+	request := financialRequest{
+		MTI:            refundMTI,
+		ProcessingCode: refundProcessingCode,
+		Amount:         formatAmount(amount),
+		EntryMode:      req.EntryMode,
+		Track2:         req.Track2,
+		TerminalID:     req.TerminalID,
+		MerchantID:     req.MerchantID,
+		Currency:       currency,
+		STAN:           req.STAN,
+	}
+
+	gateways, err := routeFollowUp(ctx, s.gateways, s.transactions, request, original.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, resp, err := exchange(ctx, gateways, s.transactions, "refund", original.ID, newFinancialMessage(ctx, request))
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+
+	if tx.Status == models.TransactionApproved {
+		refunded += amount
+	}
+
+	responseCode := resp.Get(clients.FieldResponseCode)
+	span.SetAttributes(attribute.String("iso.response_code", responseCode))
+	metrics.ResponseCodes.WithLabelValues("refund", responseCode).Inc()
+	logger.Component(ctx, logger.ComponentService).WithField("response_code", responseCode).Info("refund processed")
+
+	return &models.RefundResponse{
+		TransactionID:    tx.ID,
+		ResponseCode:     responseCode,
+		STAN:             tx.STAN,
+		RRN:              tx.RRN,
+		RefundedAmount:   formatAmount(refunded),
+		RefundableAmount: formatAmount(captured - refunded),
+	}, nil
+}
+
+// original returns the payment refunded by req with its follow-up operations.
+// Transactions of other merchants are reported as not found.
+func (s *refundService) original(ctx context.Context, req *models.RefundRequest) (*models.Transaction, []*models.Transaction, error) {
+	original, err := s.transactions.FindByID(ctx, req.OriginalTransactionID)
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && original.MerchantID != req.MerchantID) {
+		return nil, nil, ErrOriginalTransactionNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	followUps, err := s.transactions.ListFollowUps(ctx, original.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return original, followUps, nil
+}
+
+// inFlightRefunds sums the refunds whose outcome is not known yet: pending ones,
+// and unresolved ones until their reversal is confirmed.
+func inFlightRefunds(followUps []*models.Transaction) int64 {
+	var amount int64
+	for _, followUp := range followUps {
+		if followUp.Operation == "refund" && (followUp.Status == models.TransactionPending || followUp.Status == models.TransactionUnresolved) {
+			amount += parseAmount(followUp.Amount)
+		}
+	}
+
+	return amount
+}
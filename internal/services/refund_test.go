@@ -0,0 +1,254 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+)
+
+const (
+	testGateway  = "primary"
+	testMerchant = "M1"
+	testTrack2   = "4111111111111111=30122010000000000000"
+)
+
+// followUp returns an operation on the original transaction.
+func followUp(id, operation string, status models.TransactionStatus, amount string) *models.Transaction {
+	return &models.Transaction{
+		ID:                    id,
+		Operation:             operation,
+		MerchantID:            testMerchant,
+		Gateway:               testGateway,
+		Amount:                amount,
+		Status:                status,
+		OriginalTransactionID: "original",
+	}
+}
+
+func TestRefundNeverExceedsTheCapturedAmount(t *testing.T) {
+	authorization := &models.Transaction{
+		ID:         "original",
+		Operation:  "authorization",
+		MerchantID: testMerchant,
+		Gateway:    testGateway,
+		Amount:     "000000001000",
+		Currency:   "986",
+		Status:     models.TransactionApproved,
+	}
+	preAuthorization := *authorization
+	preAuthorization.Operation = "pre_authorization"
+
+	tests := []struct {
+		name           string
+		original       *models.Transaction
+		followUps      []*models.Transaction
+		amount         string
+		responseCode   string
+		wantErr        error
+		wantRefunded   string
+		wantRefundable string
+	}{
+		{
+			name:           "full refund",
+			original:       authorization,
+			amount:         "1000",
+			responseCode:   "00",
+			wantRefunded:   "000000001000",
+			wantRefundable: "000000000000",
+		},
+		{
+			name:     "partial refund up to the captured amount",
+			original: authorization,
+			followUps: []*models.Transaction{
+				followUp("r1", "refund", models.TransactionApproved, "000000000600"),
+			},
+			amount:         "400",
+			responseCode:   "00",
+			wantRefunded:   "000000001000",
+			wantRefundable: "000000000000",
+		},
+		{
+			name:     "refunded plus amount above the captured amount",
+			original: authorization,
+			followUps: []*models.Transaction{
+				followUp("r1", "refund", models.TransactionApproved, "000000000600"),
+			},
+			amount:  "401",
+			wantErr: ErrRefundAmountExceeded,
+		},
+		{
+			name:     "pending refunds count as refunded",
+			original: authorization,
+			followUps: []*models.Transaction{
+				followUp("r1", "refund", models.TransactionApproved, "000000000500"),
+				followUp("r2", "refund", models.TransactionPending, "000000000300"),
+			},
+			amount:  "201",
+			wantErr: ErrRefundAmountExceeded,
+		},
+		{
+			name:     "unresolved refunds count as refunded",
+			original: authorization,
+			followUps: []*models.Transaction{
+				followUp("r1", "refund", models.TransactionUnresolved, "000000000800"),
+			},
+			amount:  "201",
+			wantErr: ErrRefundAmountExceeded,
+		},
+		{
+			name:     "in-flight refunds leave the rest refundable",
+			original: authorization,
+			followUps: []*models.Transaction{
+				followUp("r1", "refund", models.TransactionApproved, "000000000500"),
+				followUp("r2", "refund", models.TransactionUnresolved, "000000000300"),
+			},
+			amount:         "200",
+			responseCode:   "00",
+			wantRefunded:   "000000000700",
+			wantRefundable: "000000000300",
+		},
+		{
+			name:     "declined, failed and reversed refunds do not count",
+			original: authorization,
+			followUps: []*models.Transaction{
+				followUp("r1", "refund", models.TransactionDeclined, "000000001000"),
+				followUp("r2", "refund", models.TransactionFailed, "000000001000"),
+				followUp("r3", "refund", models.TransactionReversed, "000000001000"),
+			},
+			amount:         "1000",
+			responseCode:   "00",
+			wantRefunded:   "000000001000",
+			wantRefundable: "000000000000",
+		},
+		{
+			name:           "declined refund leaves the amounts unchanged",
+			original:       authorization,
+			amount:         "300",
+			responseCode:   "51",
+			wantRefunded:   "000000000000",
+			wantRefundable: "000000001000",
+		},
+		{
+			name:     "confirmed pre-authorization refunds the confirmed amount",
+			original: &preAuthorization,
+			followUps: []*models.Transaction{
+				followUp("c1", "confirmation", models.TransactionApproved, "000000000700"),
+			},
+			amount:         "700",
+			responseCode:   "00",
+			wantRefunded:   "000000000700",
+			wantRefundable: "000000000000",
+		},
+		{
+			name:     "confirmed pre-authorization above the confirmed amount",
+			original: &preAuthorization,
+			followUps: []*models.Transaction{
+				followUp("c1", "confirmation", models.TransactionApproved, "000000000700"),
+			},
+			amount:  "701",
+			wantErr: ErrRefundAmountExceeded,
+		},
+		{
+			name:     "fully refunded payment",
+			original: authorization,
+			followUps: []*models.Transaction{
+				followUp("r1", "refund", models.TransactionApproved, "000000001000"),
+			},
+			amount:  "1",
+			wantErr: ErrRefundNotAllowed,
+		},
+		{
+			name:     "unconfirmed pre-authorization",
+			original: &preAuthorization,
+			amount:   "1",
+			wantErr:  ErrRefundNotAllowed,
+		},
+		{
+			name:     "zero amount",
+			original: authorization,
+			amount:   "0",
+			wantErr:  ErrInvalidAmount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := *tt.original
+			transactions := newTestTransactions(t, append([]*models.Transaction{&original}, tt.followUps...)...)
+			gateway := &fakeGateway{reply: replyWith(tt.responseCode, nil)}
+			service := NewRefundService(newTestRouter(gateway, &fakeGateway{}), transactions, NewTransactionLocks())
+
+			resp, err := service.Process(merchantContext(testMerchant), &models.RefundRequest{
+				Amount:                tt.amount,
+				EntryMode:             "051",
+				Track2:                testTrack2,
+				TerminalID:            "T1",
+				MerchantID:            testMerchant,
+				OriginalTransactionID: original.ID,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if sent := gateway.sentMTIs(); len(sent) > 0 {
+					t.Fatalf("sent %d messages to the gateway, want none", len(sent))
+				}
+				return
+			}
+
+			if resp.RefundedAmount != tt.wantRefunded || resp.RefundableAmount != tt.wantRefundable {
+				t.Fatalf("refunded = %s, refundable = %s, want %s and %s", resp.RefundedAmount, resp.RefundableAmount, tt.wantRefunded, tt.wantRefundable)
+			}
+			if amount := gateway.sent[0].Get(clients.FieldAmount); amount != formatAmount(parseAmount(tt.amount)) {
+				t.Fatalf("amount sent = %s, want %s", amount, formatAmount(parseAmount(tt.amount)))
+			}
+		})
+	}
+}
+
+func TestConcurrentRefundsNeverExceedTheCapturedAmount(t *testing.T) {
+	transactions := newTestTransactions(t, &models.Transaction{
+		ID:         "original",
+		Operation:  "authorization",
+		MerchantID: testMerchant,
+		Gateway:    testGateway,
+		Amount:     "000000001000",
+		Status:     models.TransactionApproved,
+	})
+	gateway := &fakeGateway{reply: replyWith("00", nil)}
+	service := NewRefundService(newTestRouter(gateway, &fakeGateway{}), transactions, NewTransactionLocks())
+
+	var (
+		wg       sync.WaitGroup
+		exceeded atomic.Int32
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := service.Process(merchantContext(testMerchant), &models.RefundRequest{
+				Amount:                "300",
+				EntryMode:             "051",
+				Track2:                testTrack2,
+				TerminalID:            "T1",
+				MerchantID:            testMerchant,
+				OriginalTransactionID: "original",
+			})
+			if errors.Is(err, ErrRefundAmountExceeded) {
+				exceeded.Add(1)
+			} else if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sent := len(gateway.sentMTIs()); sent != 3 || exceeded.Load() != 7 {
+		t.Fatalf("sent %d refunds and refused %d, want 3 and 7", sent, exceeded.Load())
+	}
+}
@@ -1,19 +1,68 @@
 package services
 
-import "context"
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
 
 type (
 	ReversalService interface {
-		Process(ctx context.Context, req any) (any, error)
+		Process(ctx context.Context, req *models.ReversalRequest) (*models.ReversalResponse, error)
 	}
 
-	reversalService struct{}
+	reversalService struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+	}
 )
 
-func NewReversalService() ReversalService {
-	return &reversalService{}
+func NewReversalService(gateways *clients.Router, transactions repositories.TransactionRepository) ReversalService {
+	return &reversalService{gateways, transactions}
 }
 
-func (s *reversalService) Process(ctx context.Context, req any) (any, error) {
-	return nil, nil
+func (s *reversalService) Process(ctx context.Context, req *models.ReversalRequest) (_ *models.ReversalResponse, err error) {
+	ctx, span := tracing.Start(ctx, "services.ReversalService.Process", operationAttributes("reversal", req.MerchantID, req.TerminalID)...)
+	defer tracing.End(span, &err)
+
+	if err := checkMerchant(ctx, req.MerchantID); err != nil {
+		return nil, err
+	}
+
+	request := financialRequest{
+		MTI:            req.Mti,
+		ProcessingCode: req.ProcessingCode,
+		Amount:         req.Amount,
+		EntryMode:      req.EntryMode,
+		Track2:         req.Track2,
+		TerminalID:     req.TerminalID,
+		MerchantID:     req.MerchantID,
+		Currency:       req.Currency,
+		STAN:           req.STAN,
+	}
+
+	gateways, err := routeFollowUp(ctx, s.gateways, s.transactions, request, req.OriginalTransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, resp, err := exchange(ctx, gateways, s.transactions, "reversal", req.OriginalTransactionID, newFinancialMessage(ctx, request))
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+
+	responseCode := resp.Get(clients.FieldResponseCode)
+	span.SetAttributes(attribute.String("iso.response_code", responseCode))
+	metrics.ResponseCodes.WithLabelValues("reversal", responseCode).Inc()
+	logger.Component(ctx, logger.ComponentService).WithField("response_code", responseCode).Info("reversal processed")
+	metrics.Reversals.WithLabelValues("terminal").Inc()
+
+	return &models.ReversalResponse{TransactionID: tx.ID, ResponseCode: responseCode, STAN: tx.STAN, RRN: tx.RRN}, nil
 }
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+)
+
+type (
+	// ReversalWorker sends the reversals of the unresolved transactions: those
+	// whose exchange failed after the message may have reached the gateway, and
+	// those still pending when the server stopped. Authorizations, pre-authorizations,
+	// confirmations and refunds are reversed with a 0400; cancellations and reversals,
+	// which are reversals already, are repeated (MTI ending in 1). Each reversal goes to
+	// the gateway the transaction was sent to, and stays queued until that gateway
+	// answers. Nothing is sent to a gateway whose session is not signed on.
+	ReversalWorker struct {
+		gateways     *clients.Router
+		transactions repositories.TransactionRepository
+		interval     time.Duration
+
+		stop     chan struct{}
+		done     chan struct{}
+		stopOnce sync.Once
+	}
+)
+
+func NewReversalWorker(gateways *clients.Router, transactions repositories.TransactionRepository, interval time.Duration) *ReversalWorker {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	return &ReversalWorker{
+		gateways:     gateways,
+		transactions: transactions,
+		interval:     interval,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start sends the queued reversals right away and then every interval until Close.
+func (w *ReversalWorker) Start() {
+	// Closing stop cancels the reversal being sent; it stays queued.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-w.stop
+		cancel()
+	}()
+
+	go func() {
+		defer close(w.done)
+
+		for {
+			w.ReverseUnresolved(ctx)
+
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(w.interval):
+			}
+		}
+	}()
+}
+
+// Close stops the worker and waits for it to return.
+func (w *ReversalWorker) Close() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+// QueuePending marks every pending transaction as unresolved and returns how many
+// were queued. It is called on shutdown once the drain deadline expired, and on
+// startup for the transactions left pending by a crash.
+func (w *ReversalWorker) QueuePending(ctx context.Context) (int, error) {
+	pending, err := w.transactions.ListByStatus(ctx, models.TransactionPending)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, tx := range pending {
+		tx.Status = models.TransactionUnresolved
+		tx.UpdatedAt = time.Now().UTC()
+
+		err := w.transactions.Update(ctx, tx, models.TransactionPending)
+		if errors.Is(err, repositories.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return queued, err
+		}
+
+		queued++
+		transactionEntry(ctx, tx).Error("transaction unresolved, reversal queued")
+	}
+
+	return queued, nil
+}
+
+// ReverseUnresolved sends the reversal of every unresolved transaction once, when
+// the session of its gateway is signed on.
+func (w *ReversalWorker) ReverseUnresolved(ctx context.Context) {
+	unresolved, err := w.transactions.ListByStatus(ctx, models.TransactionUnresolved)
+	if err != nil {
+		logger.Component(ctx, logger.ComponentService).WithError(err).Error("failed to list unresolved transactions")
+		return
+	}
+
+	for _, tx := range unresolved {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		gateway := w.gateways.Gateway(tx.Gateway)
+		if gateway == nil {
+			transactionEntry(ctx, tx).Error("gateway of unresolved transaction is no longer configured, reversal kept queued")
+			continue
+		}
+
+		if gateway.Session.SignedOn() {
+			w.reverse(ctx, gateway, tx)
+		}
+	}
+}
+
+func (w *ReversalWorker) reverse(ctx context.Context, gateway *clients.Gateway, tx *models.Transaction) {
+	entry := transactionEntry(ctx, tx)
+
+	resp, err := gateway.Client.Send(ctx, newReversalMessage(tx))
+	if err != nil {
+		entry.WithError(err).Warn("reversal of unresolved transaction failed, will retry")
+		return
+	}
+
+	tx.Status = models.TransactionReversed
+	tx.ReversalResponseCode = resp.Get(clients.FieldResponseCode)
+	tx.UpdatedAt = time.Now().UTC()
+
+	if err := w.transactions.Update(ctx, tx, models.TransactionUnresolved); err != nil {
+		entry.WithError(err).Error("failed to record the reversal of unresolved transaction")
+		return
+	}
+
+	metrics.Reversals.WithLabelValues("unresolved").Inc()
+	entry.WithField("reversal_response_code", tx.ReversalResponseCode).Info("unresolved transaction reversed")
+}
+
+// newReversalMessage builds the reversal, or the repeat, of tx. Field 90 carries
+// the original MTI, STAN and transmission date and time.
+func newReversalMessage(tx *models.Transaction) *clients.Message {
+	mti := "0400"
+	if (tx.Operation == "cancellation" || tx.Operation == "reversal") && len(tx.MTI) == 4 {
+		mti = tx.MTI[:3] + "1"
+	}
+
+	now := time.Now().UTC()
+
+	msg := clients.NewMessage(mti).
+		Set(clients.FieldProcessingCode, tx.ProcessingCode).
+		Set(clients.FieldAmount, tx.Amount).
+		Set(clients.FieldTransmissionDateTime, now.Format("0102150405")).
+		Set(clients.FieldSTAN, clients.NextSTAN()).
+		Set(clients.FieldLocalTime, now.Format("150405")).
+		Set(clients.FieldLocalDate, now.Format("0102")).
+		Set(clients.FieldEntryMode, tx.EntryMode).
+		Set(clients.FieldRRN, tx.RRN).
+		Set(clients.FieldTerminalID, tx.TerminalID).
+		Set(clients.FieldMerchantID, tx.MerchantID).
+		Set(clients.FieldOriginalData, originalData(tx))
+
+	if tx.Currency != "" {
+		msg.Set(clients.FieldCurrency, tx.Currency)
+	}
+
+	return msg
+}
+
+func transactionEntry(ctx context.Context, tx *models.Transaction) *logrus.Entry {
+	return logger.Component(ctx, logger.ComponentService).WithFields(logrus.Fields{
+		"transaction_id": tx.ID,
+		"operation":      tx.Operation,
+		"terminal_id":    tx.TerminalID,
+		"merchant_id":    tx.MerchantID,
+		"gateway":        tx.Gateway,
+		"stan":           tx.STAN,
+		"rrn":            tx.RRN,
+	})
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+var errGatewayDown = errors.New("gateway down")
+
+// fakeGateway signs on and answers echo tests, and answers the other messages
+// with reply. It records every message sent besides the network management ones.
+// Its circuit never opens.
+type fakeGateway struct {
+	mu    sync.Mutex
+	sent  []*clients.Message
+	reply func(msg *clients.Message) (*clients.Message, error)
+}
+
+func (g *fakeGateway) Send(ctx context.Context, msg *clients.Message) (*clients.Message, error) {
+	if msg.MTI == "0800" {
+		return clients.NewMessage("0810").Set(clients.FieldResponseCode, "00"), nil
+	}
+
+	g.mu.Lock()
+	g.sent = append(g.sent, msg)
+	reply := g.reply
+	g.mu.Unlock()
+
+	return reply(msg)
+}
+
+func (g *fakeGateway) Close() error { return nil }
+
+func (g *fakeGateway) State() clients.CircuitState { return clients.CircuitClosed }
+
+func (g *fakeGateway) OnStateChange(clients.StateChangeFunc) {}
+
+func (g *fakeGateway) sentMTIs() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	mtis := []string{}
+	for _, msg := range g.sent {
+		mtis = append(mtis, msg.MTI)
+	}
+	slices.Sort(mtis)
+	return mtis
+}
+
+// answer replies to msg with the response MTI and responseCode.
+func answer(msg *clients.Message, responseCode string) *clients.Message {
+	return clients.NewMessage(msg.MTI[:2]+string(msg.MTI[2]+1)+"0").Set(clients.FieldResponseCode, responseCode)
+}
+
+// newTestTransactions opens a transaction repository in a temporary directory
+// holding txs.
+func newTestTransactions(t *testing.T, txs ...*models.Transaction) repositories.TransactionRepository {
+	t.Helper()
+
+	transactions, err := repositories.NewFileTransactionRepository(filepath.Join(t.TempDir(), "transactions.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { transactions.Close() })
+
+	for _, tx := range txs {
+		if err := transactions.Create(context.Background(), tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return transactions
+}
+
+func storeTransaction(t *testing.T, transactions repositories.TransactionRepository, tx *models.Transaction) {
+	t.Helper()
+
+	tx.CreatedAt = time.Now().UTC()
+	tx.UpdatedAt = tx.CreatedAt
+	if err := transactions.Create(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func transactionIDs(t *testing.T, transactions repositories.TransactionRepository, status models.TransactionStatus) []string {
+	t.Helper()
+
+	listed, err := transactions.ListByStatus(context.Background(), status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, tx := range listed {
+		ids = append(ids, tx.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestReversalWorkerQueuePending(t *testing.T) {
+	transactions := newTestTransactions(t)
+
+	// One left pending by a crash, one answered before the shutdown.
+	storeTransaction(t, transactions, &models.Transaction{ID: "crashed", Operation: "authorization", MTI: "0200", Status: models.TransactionPending})
+	storeTransaction(t, transactions, &models.Transaction{ID: "answered", Operation: "authorization", MTI: "0200", Status: models.TransactionApproved})
+
+	// And one sent to the gateway but still waiting for its answer.
+	release := make(chan struct{})
+	gateway := &fakeGateway{reply: func(msg *clients.Message) (*clients.Message, error) {
+		<-release
+		return answer(msg, "00"), nil
+	}}
+
+	type result struct {
+		resp *clients.Message
+		err  error
+	}
+	exchanged := make(chan result)
+	go func() {
+		gateways := []*clients.Gateway{{Name: "primary", Client: gateway}}
+		_, resp, err := exchange(context.Background(), gateways, transactions, "authorization", "", clients.NewMessage("0200").Set(clients.FieldSTAN, "000042"))
+		exchanged <- result{resp, err}
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(transactionIDs(t, transactions, models.TransactionPending)) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	worker := NewReversalWorker(clients.NewRouter(configs.RoutingConfig{Default: "primary"}, nil), transactions, time.Minute)
+	queued, err := worker.QueuePending(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued != 2 {
+		t.Fatalf("%d transactions queued, want the crashed and the unanswered ones", queued)
+	}
+
+	// The gateway answers once the transaction is queued: the caller gets the
+	// response but the transaction stays queued for reversal.
+	close(release)
+	got := <-exchanged
+	if got.err != nil || got.resp.Get(clients.FieldResponseCode) != "00" {
+		t.Fatalf("late answer: response %v, err %v", got.resp, got.err)
+	}
+
+	if pending := transactionIDs(t, transactions, models.TransactionPending); len(pending) != 0 {
+		t.Fatalf("transactions %v still pending", pending)
+	}
+	unresolved := transactionIDs(t, transactions, models.TransactionUnresolved)
+	if len(unresolved) != 2 || !slices.Contains(unresolved, "crashed") {
+		t.Fatalf("unresolved transactions %v, want crashed and the unanswered one", unresolved)
+	}
+	if approved := transactionIDs(t, transactions, models.TransactionApproved); !slices.Equal(approved, []string{"answered"}) {
+		t.Fatalf("approved transactions %v, want only answered", approved)
+	}
+}
+
+func TestReversalWorkerReverseUnresolved(t *testing.T) {
+	transactions := newTestTransactions(t)
+	storeTransaction(t, transactions, &models.Transaction{
+		ID: "sale", Operation: "authorization", MTI: "0200", STAN: "000011", TransmissionDateTime: "1019103000",
+		Amount: "000000001000", Status: models.TransactionUnresolved,
+	})
+	storeTransaction(t, transactions, &models.Transaction{
+		ID: "cancel", Operation: "cancellation", MTI: "0420", STAN: "000012", TransmissionDateTime: "1019103100",
+		Amount: "000000001000", Status: models.TransactionUnresolved,
+	})
+
+	var fail bool
+	gateway := &fakeGateway{reply: func(msg *clients.Message) (*clients.Message, error) {
+		if fail {
+			return nil, errGatewayDown
+		}
+		return answer(msg, "00"), nil
+	}}
+
+	session := clients.NewSession("primary", gateway, time.Minute)
+	router := clients.NewRouter(configs.RoutingConfig{Default: "primary"}, []*clients.Gateway{{Name: "primary", Client: gateway, Session: session}})
+	worker := NewReversalWorker(router, transactions, time.Minute)
+
+	// Nothing is sent before the session signs on.
+	worker.ReverseUnresolved(context.Background())
+	if sent := gateway.sentMTIs(); len(sent) != 0 {
+		t.Fatalf("sent %v before signing on, want nothing", sent)
+	}
+
+	session.Start()
+	t.Cleanup(func() { session.Close(context.Background()) })
+
+	deadline := time.Now().Add(time.Second)
+	for !session.SignedOn() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// A failed reversal stays queued for the next run.
+	fail = true
+	worker.ReverseUnresolved(context.Background())
+	if unresolved := transactionIDs(t, transactions, models.TransactionUnresolved); len(unresolved) != 2 {
+		t.Fatalf("unresolved transactions %v after failed reversals, want both", unresolved)
+	}
+
+	fail = false
+	worker.ReverseUnresolved(context.Background())
+	if unresolved := transactionIDs(t, transactions, models.TransactionUnresolved); len(unresolved) != 0 {
+		t.Fatalf("transactions %v still unresolved", unresolved)
+	}
+
+	for _, id := range []string{"sale", "cancel"} {
+		tx, err := transactions.FindByID(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Status != models.TransactionReversed || tx.ReversalResponseCode != "00" {
+			t.Fatalf("%s: status %s with reversal response code %q, want reversed with 00", id, tx.Status, tx.ReversalResponseCode)
+		}
+	}
+
+	// Authorizations are reversed with a 0400, cancellations are repeated.
+	if sent := gateway.sentMTIs(); !slices.Equal(sent, []string{"0400", "0400", "0421", "0421"}) {
+		t.Fatalf("sent %v, want a 0400 and a 0421 on each run", sent)
+	}
+	for _, msg := range gateway.sent {
+		original := msg.Get(clients.FieldOriginalData)
+		if !strings.HasPrefix(original, "02000000111019103000") && !strings.HasPrefix(original, "04200000121019103100") {
+			t.Fatalf("original data %q does not identify the original message", original)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+// newTestRouter routes every payment to primary, failing authorizations over to backup.
+func newTestRouter(primary, backup *fakeGateway) *clients.Router {
+	return clients.NewRouter(configs.RoutingConfig{Default: "primary", Failover: "backup"}, []*clients.Gateway{
+		{Name: "primary", Client: primary},
+		{Name: "backup", Client: backup},
+	})
+}
+
+// replyWith answers every message with responseCode, or fails with err when set.
+func replyWith(responseCode string, err error) func(msg *clients.Message) (*clients.Message, error) {
+	return func(msg *clients.Message) (*clients.Message, error) {
+		if err != nil {
+			return nil, err
+		}
+		return answer(msg, responseCode), nil
+	}
+}
+
+func merchantContext(merchantID string) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{MerchantID: merchantID})
+}
+
+func TestAuthorizationFailover(t *testing.T) {
+	tests := []struct {
+		name        string
+		primaryErr  error
+		wantErr     error
+		wantGateway string
+		wantStatus  models.TransactionStatus
+		wantSent    map[string]int // Messages received by each gateway.
+	}{
+		{
+			name:        "primary answers",
+			wantGateway: "primary",
+			wantStatus:  models.TransactionApproved,
+			wantSent:    map[string]int{"primary": 1, "backup": 0},
+		},
+		{
+			name:        "primary unreachable",
+			primaryErr:  fmt.Errorf("%w: connection refused", clients.ErrNotSent),
+			wantGateway: "backup",
+			wantStatus:  models.TransactionApproved,
+			wantSent:    map[string]int{"primary": 1, "backup": 1},
+		},
+		{
+			name:        "primary circuit open",
+			primaryErr:  clients.ErrCircuitOpen,
+			wantGateway: "backup",
+			wantStatus:  models.TransactionApproved,
+			wantSent:    map[string]int{"primary": 1, "backup": 1},
+		},
+		{
+			// The primary may hold the transaction, it is reversed there instead.
+			name:        "message may have reached the primary",
+			primaryErr:  errGatewayDown,
+			wantErr:     errGatewayDown,
+			wantGateway: "primary",
+			wantStatus:  models.TransactionUnresolved,
+			wantSent:    map[string]int{"primary": 1, "backup": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactions := newTestTransactions(t)
+			gateways := map[string]*fakeGateway{
+				"primary": {reply: replyWith("00", tt.primaryErr)},
+				"backup":  {reply: replyWith("00", nil)},
+			}
+			service := NewAuthorizationService(newTestRouter(gateways["primary"], gateways["backup"]), transactions)
+
+			_, err := service.Process(merchantContext("M1"), &models.AuthorizationRequest{
+				Mti: "0200", ProcessingCode: "003000", Amount: "000000001000", EntryMode: "051",
+				Track2: "4111111111111111=2512", TerminalID: "T1", MerchantID: "M1",
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := transactions.ListByStatus(context.Background(), tt.wantStatus)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 1 || stored[0].Gateway != tt.wantGateway {
+				t.Fatalf("%s transactions %+v, want one on %s", tt.wantStatus, stored, tt.wantGateway)
+			}
+
+			for name, gateway := range gateways {
+				if sent := len(gateway.sentMTIs()); sent != tt.wantSent[name] {
+					t.Errorf("%s received %d messages, want %d", name, sent, tt.wantSent[name])
+				}
+			}
+		})
+	}
+}
+
+func TestFollowUpsGoToTheOriginalGateway(t *testing.T) {
+	followUps := map[string]func(router *clients.Router, transactions repositories.TransactionRepository, originalID string) error{
+		"confirmation": func(router *clients.Router, transactions repositories.TransactionRepository, originalID string) error {
+			_, err := NewConfirmationService(router, transactions, NewTransactionLocks()).Process(merchantContext("M1"), &models.ConfirmationRequest{
+				Mti: "0202", ProcessingCode: "003000", Amount: "000000001000", EntryMode: "051",
+				Track2: "4111111111111111=2512", TerminalID: "T1", MerchantID: "M1", OriginalTransactionID: originalID,
+			})
+			return err
+		},
+		"cancellation": func(router *clients.Router, transactions repositories.TransactionRepository, originalID string) error {
+			_, err := NewCancellationService(router, transactions, NewTransactionLocks()).Process(merchantContext("M1"), &models.CancellationRequest{
+				Mti: "0420", ProcessingCode: "003000", Amount: "000000001000", EntryMode: "051",
+				Track2: "4111111111111111=2512", TerminalID: "T1", MerchantID: "M1", OriginalTransactionID: originalID,
+			})
+			return err
+		},
+		"reversal": func(router *clients.Router, transactions repositories.TransactionRepository, originalID string) error {
+			_, err := NewReversalService(router, transactions).Process(merchantContext("M1"), &models.ReversalRequest{
+				Mti: "0400", ProcessingCode: "003000", Amount: "000000001000", EntryMode: "051",
+				Track2: "4111111111111111=2512", TerminalID: "T1", MerchantID: "M1", OriginalTransactionID: originalID,
+			})
+			return err
+		},
+	}
+
+	tests := []struct {
+		name       string
+		originalID string
+		backupErr  error
+		wantErr    error
+		wantBackup int // Messages received by backup; primary must receive none.
+	}{
+		{name: "sent to the gateway of the original", originalID: "original", wantBackup: 1},
+		{name: "no failover when that gateway is down", originalID: "original", backupErr: clients.ErrCircuitOpen, wantErr: ErrGatewayUnavailable, wantBackup: 1},
+		{name: "original transaction required", wantErr: ErrOriginalTransactionRequired},
+		{name: "unknown original transaction", originalID: "unknown", wantErr: ErrOriginalTransactionNotFound},
+		{name: "original transaction of another merchant", originalID: "other-merchant", wantErr: ErrOriginalTransactionNotFound},
+	}
+
+	for operation, process := range followUps {
+		for _, tt := range tests {
+			t.Run(operation+"/"+tt.name, func(t *testing.T) {
+				transactions := newTestTransactions(t)
+				// Authorized on backup after a failover, while the rules route to primary.
+				storeTransaction(t, transactions, &models.Transaction{ID: "original", Operation: "authorization", MTI: "0200", MerchantID: "M1", Gateway: "backup", Status: models.TransactionApproved})
+				storeTransaction(t, transactions, &models.Transaction{ID: "other-merchant", Operation: "authorization", MTI: "0200", MerchantID: "M2", Gateway: "backup", Status: models.TransactionApproved})
+
+				primary := &fakeGateway{reply: replyWith("00", nil)}
+				backup := &fakeGateway{reply: replyWith("00", tt.backupErr)}
+
+				err := process(newTestRouter(primary, backup), transactions, tt.originalID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+
+				if sent := primary.sentMTIs(); len(sent) != 0 {
+					t.Fatalf("primary received %v, want nothing", sent)
+				}
+				if sent := backup.sentMTIs(); len(sent) != tt.wantBackup {
+					t.Fatalf("backup received %v, want %d messages", sent, tt.wantBackup)
+				}
+			})
+		}
+	}
+}
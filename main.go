@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,60 +16,318 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"github.com/sirupsen/logrus"
 
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
 	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/certs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/commands"
 	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers/admin"
 	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers/financial"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/health"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/middlewares"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/ratelimit"
 	"githib.com/ralvescosta/go-simple-http-server/pkg/routes"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
+
+// Exit codes of the server, besides 1 for startup failures.
+const (
+	exitOK = 0
+	// exitForced means the shutdown deadline expired before the requests or the
+	// gateway exchanges finished.
+	exitForced = 2
+	// exitUnresolved means transactions are left without a gateway response;
+	// their reversals are queued for the next start.
+	exitUnresolved = 3
 )
 
 func main() {
-	cfgs, err := configs.NewConfigs()
+	opts, args, err := configs.ParseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		// The flag package already reported the error with the usage.
+		os.Exit(2)
+	}
+
+	if len(args) > 0 {
+		os.Exit(commands.Run(opts, args))
+	}
+
+	os.Exit(serve(opts))
+}
+
+func serve(opts configs.Options) int {
+	cfgs, err := configs.NewConfigs(opts)
+	if err != nil {
+		// The logger is configured from the configuration, report every problem as is.
+		fmt.Fprintf(os.Stderr, "configuration error:\n%v\n", err)
+		return 1
+	}
+
+	flushLogs, err := logger.SetupLogger(context.Background(), cfgs)
 	if err != nil {
-		logrus.Fatal(err)
+		logrus.WithError(err).Fatal("Failed to setup log sink")
 	}
 
-	logrus.Info("Go HTTP Simple Server")
+	logrus.WithField("config", configs.RedactedSettings()).Infof("Go HTTP Simple Server (%s, %s)", cfgs.AppName, cfgs.Env)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfgs)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to setup tracing")
+	}
 
 	logrus.Info("creating router...")
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(middlewares.RequestLogger)
+	r.Use(middlewares.RealIP(cfgs.Server.TrustedProxies))
+	r.Use(middlewares.Tracing)
+	r.Use(middlewares.Metrics)
+	r.Use(middlewares.AccessLog(cfgs.AccessLog))
 	r.Use(middleware.Recoverer)
+	r.Use(middlewares.MaxInFlight(cfgs.Server.MaxInFlight))
+	r.Use(middlewares.MaxBodySize(cfgs.Server.MaxBodyBytes))
 
 	addr := fmt.Sprintf("%s:%v", cfgs.Host, cfgs.Port)
 	server := &http.Server{
-		Addr:         addr,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  10 * time.Second,
-		Handler:      r,
+		Addr:              addr,
+		ReadTimeout:       cfgs.Server.ReadTimeout,
+		ReadHeaderTimeout: cfgs.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfgs.Server.WriteTimeout,
+		IdleTimeout:       cfgs.Server.IdleTimeout,
+		MaxHeaderBytes:    cfgs.Server.MaxHeaderBytes,
+		Handler:           r,
+	}
+
+	if cfgs.TLS.Enabled {
+		listenerCerts, err := certs.NewReloader("listener", cfgs.TLS.CertFile, cfgs.TLS.KeyFile, cfgs.TLS.ClientCAFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to load listener TLS material")
+		}
+		defer listenerCerts.Close()
+
+		server.TLSConfig, err = certs.NewServerTLSConfig(cfgs.TLS, listenerCerts)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid listener TLS configuration")
+		}
+	}
+
+	logrus.Info("connecting gateway clients...")
+
+	gateways := make([]*clients.Gateway, 0, len(cfgs.Gateways))
+	for _, gatewayCfg := range cfgs.Gateways {
+		var gatewayTLSConfig *tls.Config
+		if gatewayCfg.TLS.Enabled {
+			gatewayCerts, err := certs.NewReloader("gateway "+gatewayCfg.Name, gatewayCfg.TLS.CertFile, gatewayCfg.TLS.KeyFile, gatewayCfg.TLS.CAFile)
+			if err != nil {
+				logrus.WithError(err).WithField("gateway", gatewayCfg.Name).Fatal("Failed to load gateway TLS material")
+			}
+			defer gatewayCerts.Close()
+
+			gatewayTLSConfig = certs.NewGatewayTLSConfig(gatewayCfg.TLS, gatewayCerts)
+		}
+
+		gateway := clients.NewGateway(cfgs, gatewayCfg, gatewayTLSConfig)
+		defer gateway.Client.Close()
+
+		gateway.Session.Start()
+		gateways = append(gateways, gateway)
+	}
+
+	gatewayRouter := clients.NewRouter(cfgs.Routing, gateways)
+
+	logrus.Info("instantiating repositories, services, controllers and routers...")
+
+	apiKeyRepository, err := repositories.NewFileAPIKeyRepository(cfgs.APIKeys.File)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load api keys")
+	}
+
+	transactionRepository, err := repositories.NewFileTransactionRepository(cfgs.Transactions.File)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load transactions")
+	}
+	defer transactionRepository.Close()
+
+	// The STAN counter restarts with the process; resuming after the latest
+	// transaction keeps the lookups by terminal and STAN unambiguous.
+	if stan, err := transactionRepository.LatestSTAN(context.Background()); err == nil {
+		clients.SeedSTAN(stan)
+	}
+
+	batchRepository, err := repositories.NewFileBatchRepository(cfgs.Settlement.File)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load batches")
+	}
+	defer batchRepository.Close()
+
+	reversalWorker := services.NewReversalWorker(gatewayRouter, transactionRepository, cfgs.Transactions.ReversalInterval)
+
+	// Transactions still pending were left by a crash: their outcome is unknown.
+	if queued, err := reversalWorker.QueuePending(context.Background()); err != nil {
+		logrus.WithError(err).Fatal("Failed to queue the reversals of pending transactions")
+	} else if queued > 0 {
+		logrus.Warnf("%d transactions left pending by the previous run queued for reversal", queued)
 	}
 
-	logrus.Info("instantiating services, controllers and routers...")
+	reversalWorker.Start()
 
-	authorizationService := services.NewAuthorizationService()
-	preAuthService := services.NewPreAuthorizationService()
-	confirmationService := services.NewConfirmationService()
-	cancellationService := services.NewCancellationService()
-	reversalService := services.NewReversalService()
+	// Refunds, increments, confirmations, cancellations and expiries of the same
+	// transaction are serialized by one set of locks.
+	transactionLocks := services.NewTransactionLocks()
+
+	expiryWorker := services.NewPreAuthExpiryWorker(gatewayRouter, transactionRepository, transactionLocks, cfgs.Transactions.PreAuthExpiry.Interval, preAuthExpiryPolicy(cfgs.Transactions.PreAuthExpiry))
+	expiryWorker.Start()
+
+	apiKeyService := services.NewAPIKeyService(apiKeyRepository, cfgs.APIKeys.Pepper.Value())
+	authorizationService := services.NewAuthorizationService(gatewayRouter, transactionRepository)
+	preAuthService := services.NewPreAuthorizationService(gatewayRouter, transactionRepository)
+	incrementalService := services.NewIncrementalAuthorizationService(gatewayRouter, transactionRepository, transactionLocks)
+	confirmationService := services.NewConfirmationService(gatewayRouter, transactionRepository, transactionLocks)
+	cancellationService := services.NewCancellationService(gatewayRouter, transactionRepository, transactionLocks)
+	reversalService := services.NewReversalService(gatewayRouter, transactionRepository)
+	refundService := services.NewRefundService(gatewayRouter, transactionRepository, transactionLocks)
+	paymentService := services.NewPaymentService(transactionRepository)
+	batchService := services.NewBatchService(gatewayRouter, transactionRepository, batchRepository)
+	batchService.Recover(context.Background())
+
+	location, err := time.LoadLocation(cfgs.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	batchScheduler := services.NewBatchScheduler(batchService, batchCloseTimes(cfgs.Settlement.CloseAt), location)
+	batchScheduler.Start()
 
 	authorizationController := financial.NewAuthorizationController(authorizationService)
 	preAuthController := financial.NewPreAuthorizationController(preAuthService)
+	incrementalController := financial.NewIncrementalAuthorizationController(incrementalService)
 	confirmationController := financial.NewConfirmationController(confirmationService)
 	cancellationController := financial.NewCancellationController(cancellationService)
 	reversalController := financial.NewReversalController(reversalService)
+	refundController := financial.NewRefundController(refundService)
+	paymentsController := financial.NewPaymentsController(paymentService)
+	batchesController := financial.NewBatchesController(batchService)
+	apiKeysController := admin.NewAPIKeysController(apiKeyService)
+	logLevelController := admin.NewLogLevelController()
+
+	readiness := health.NewChecker()
+	readiness.Add("gateway_route", defaultRouteCheck(gatewayRouter))
+	// A single gateway being down does not stop the server from taking payments,
+	// so the per gateway checks are only reported.
+	for _, gateway := range gateways {
+		readiness.AddInformational("gateway_session."+gateway.Name, func(context.Context) error { return gateway.Session.Err() })
+		readiness.AddInformational("gateway_circuit_breaker."+gateway.Name, func(context.Context) error {
+			if gateway.Client.State() == clients.CircuitOpen {
+				return clients.ErrCircuitOpen
+			}
+			return nil
+		})
+		readiness.AddInformational("gateway_pool."+gateway.Name, func(context.Context) error { return gateway.Pool.Stats().LastDialError })
+	}
+	readiness.Add("repository", apiKeyRepository.Ping)
+	readiness.Add("transactions", transactionRepository.Ping)
+	readiness.Add("batches", batchRepository.Ping)
+	readiness.Add("config", func(context.Context) error { return configs.Validate(configs.Current()) })
+	operationsController := admin.NewOperationsController(readiness)
+
+	rateLimitStore := ratelimit.NewMemoryStore(10 * time.Minute)
+
+	paymentGuards := chi.Chain(
+		middlewares.IPRateLimit(cfgs.RateLimit, rateLimitStore),
+		middlewares.ClientCertAuth(cfgs.TLS.ClientCertMerchants),
+		middlewares.APIKeyAuth(apiKeyService),
+		middlewares.RequestSigning(cfgs.RequestSigning, cfgs.APIKeys.Pepper.Value(), auth.NewMemoryNonceStore(), "/v1/payments/export"),
+		middlewares.RateLimit(cfgs.RateLimit, rateLimitStore),
+	)
+
+	routes.RegisterFinancialRoutes(r, paymentGuards, authorizationController, preAuthController, incrementalController, confirmationController, cancellationController, reversalController, refundController, paymentsController, batchesController)
+
+	logrus.Info("creating admin router...")
+	adminRouter := chi.NewRouter()
+
+	adminRouter.Use(middleware.RequestID)
+	adminRouter.Use(middlewares.RequestLogger)
+	adminRouter.Use(middleware.RealIP)
+	adminRouter.Use(middleware.Recoverer)
+	adminRouter.Use(middlewares.MaxBodySize(cfgs.Server.MaxBodyBytes))
 
-	routes.RegisterFinancialRoutes(r, authorizationController, preAuthController, confirmationController, cancellationController, reversalController)
+	routes.RegisterOperationsRoutes(adminRouter, cfgs.AdminToken, operationsController)
+	routes.RegisterMetricsRoutes(adminRouter)
+	adminRouter.Group(func(r chi.Router) {
+		// Probes are left out of the access log, admin actions are kept in it.
+		r.Use(middlewares.AccessLog(cfgs.AccessLog))
+		routes.RegisterAdminRoutes(r, cfgs.AdminToken, apiKeysController, logLevelController)
+	})
+
+	adminAddr := fmt.Sprintf("%s:%v", cfgs.AdminServer.Host, cfgs.AdminServer.Port)
+	adminServer := &http.Server{
+		Addr:              adminAddr,
+		ReadTimeout:       cfgs.Server.ReadTimeout,
+		ReadHeaderTimeout: cfgs.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfgs.AdminServer.WriteTimeout,
+		IdleTimeout:       cfgs.Server.IdleTimeout,
+		MaxHeaderBytes:    cfgs.Server.MaxHeaderBytes,
+		Handler:           adminRouter,
+	}
+
+	go func() {
+		logrus.Infof("Starting admin HTTP server: %s", adminAddr)
+
+		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Fatalf("Could not listen on %s: %v", adminAddr, err)
+		}
+	}()
 
 	go func() {
-		logrus.Infof("Starting HTTP server: %s", addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logrus.Infof("Starting HTTP server: %s (tls: %v)", addr, cfgs.TLS.Enabled)
+
+		var err error
+		if cfgs.TLS.Enabled {
+			// Certificates come from TLSConfig.GetCertificate, so no files are passed here.
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+
+		if err != nil && err != http.ErrServerClosed {
 			logrus.Fatalf("Could not listen on %s: %v", addr, err)
 		}
 	}()
 
+	//=================================
+	//===== Config Reload =============
+	//=================================
+	// Every subscriber is registered by now, reloads can start.
+	configs.Watch()
+
+	//=================================
+	//===== Log Reload ================
+	//=================================
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP, syscall.SIGUSR1)
+
+	go func() {
+		for sig := range reloadChan {
+			switch sig {
+			case syscall.SIGHUP:
+				if err := logger.ReloadLevel(); err != nil {
+					logrus.WithError(err).Error("Failed to reload log level")
+				}
+			case syscall.SIGUSR1:
+				if err := logger.ReopenFile(); err != nil {
+					logrus.WithError(err).Error("Failed to reopen log file")
+				}
+			}
+		}
+	}()
+
 	//=================================
 	//===== Gracefully Shutdown =======
 	//=================================
@@ -77,14 +338,150 @@ func main() {
 	<-signalChan
 	logrus.Info("Shutting down server...")
 
-	// Create a context with a timeout for the shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Report not ready first so the load balancers stop sending requests.
+	readiness.SetDraining()
+	time.Sleep(cfgs.Server.DrainDelay)
+
+	// Create a context with a timeout for draining the requests and gateway exchanges
+	ctx, cancel := context.WithTimeout(context.Background(), cfgs.Server.ShutdownTimeout)
 	defer cancel()
 
-	// Shutdown the server gracefully
+	exitCode := drain(ctx, server, func() {
+		batchScheduler.Close()
+		batchService.Wait()
+		expiryWorker.Close()
+		reversalWorker.Close()
+	}, gateways...)
+
+	// The remaining steps get their own deadline, the drain one may be spent.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), cfgs.GatewayTimeout)
+	defer cancelFlush()
+
+	if code := queueUnresolved(flushCtx, reversalWorker, transactionRepository); code != exitOK {
+		exitCode = code
+	}
+
+	for _, gateway := range gateways {
+		if err := gateway.Session.Close(flushCtx); err != nil {
+			logrus.WithError(err).WithField("gateway", gateway.Name).Warn("Failed to sign off from the gateway")
+		}
+	}
+
+	if err := adminServer.Shutdown(flushCtx); err != nil {
+		logrus.WithError(err).Warn("Admin server forced to shutdown")
+	}
+
+	if err := transactionRepository.Close(); err != nil {
+		logrus.WithError(err).Error("Failed to close the transaction store")
+	}
+
+	if err := shutdownTracing(flushCtx); err != nil {
+		logrus.WithError(err).Warn("Failed to flush traces")
+	}
+
+	logrus.WithField("exit_code", exitCode).Info("Server exiting")
+
+	if err := flushLogs(flushCtx); err != nil {
+		logrus.WithError(err).Warn("Failed to flush log sink")
+	}
+
+	return exitCode
+}
+
+// drain stops server from accepting requests and waits for the in-flight ones,
+// then calls stop and waits for the gateway exchanges of gateways. Past the deadline
+// of ctx the remaining connections are closed, so no client gets an answer for a
+// transaction about to be reversed, and exitForced is returned.
+func drain(ctx context.Context, server *http.Server, stop func(), gateways ...*clients.Gateway) int {
+	exitCode := exitOK
+
 	if err := server.Shutdown(ctx); err != nil {
-		logrus.Fatalf("Server forced to shutdown: %v", err)
+		logrus.WithError(err).Error("Shutdown deadline expired with requests in flight, closing their connections")
+		server.Close()
+		exitCode = exitForced
+	}
+
+	stop()
+
+	for _, gateway := range gateways {
+		if err := gateway.Pool.Drain(ctx); err != nil {
+			logrus.WithError(err).WithField("gateway", gateway.Name).Error("Shutdown deadline expired with gateway exchanges in flight")
+			exitCode = exitForced
+		}
+	}
+
+	return exitCode
+}
+
+// defaultRouteCheck fails when neither the default gateway nor its failover can
+// take requests, leaving the payments matching no rule without a route.
+func defaultRouteCheck(router *clients.Router) health.Check {
+	return func(context.Context) error {
+		primary, failover := router.DefaultRoute()
+
+		var errs []error
+		for _, gateway := range []*clients.Gateway{primary, failover} {
+			if gateway == nil {
+				continue
+			}
+
+			err := gateway.Err()
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", gateway.Name, err))
+		}
+
+		if len(errs) == 0 {
+			return errors.New("no gateway configured")
+		}
+
+		return errors.Join(errs...)
+	}
+}
+
+// queueUnresolved marks the transactions left pending by the drain as unresolved
+// and returns exitUnresolved when any transaction is waiting for its reversal.
+func queueUnresolved(ctx context.Context, worker *services.ReversalWorker, transactions repositories.TransactionRepository) int {
+	if _, err := worker.QueuePending(ctx); err != nil {
+		logrus.WithError(err).Error("Failed to queue the reversals of pending transactions")
+		return exitUnresolved
+	}
+
+	unresolved, err := transactions.ListByStatus(ctx, models.TransactionUnresolved)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list the unresolved transactions")
+		return exitUnresolved
+	}
+
+	if len(unresolved) > 0 {
+		logrus.Errorf("%d transactions without a gateway response, reversals queued for the next start", len(unresolved))
+		return exitUnresolved
+	}
+
+	return exitOK
+}
+
+// preAuthExpiryPolicy indexes the expiry windows of cfg by merchant category code.
+func preAuthExpiryPolicy(cfg configs.PreAuthExpiryConfig) services.PreAuthExpiryPolicy {
+	policy := services.PreAuthExpiryPolicy{Window: cfg.Window, Windows: map[string]time.Duration{}, Release: cfg.Release}
+	for _, window := range cfg.Windows {
+		for _, mcc := range window.MCCs {
+			policy.Windows[mcc] = window.Window
+		}
+	}
+
+	return policy
+}
+
+// batchCloseTimes converts the HH:MM close times, checked with the configuration,
+// to offsets from midnight.
+func batchCloseTimes(closeAt []string) []time.Duration {
+	times := make([]time.Duration, 0, len(closeAt))
+	for _, value := range closeAt {
+		at, _ := time.Parse("15:04", value)
+		times = append(times, time.Duration(at.Hour())*time.Hour+time.Duration(at.Minute())*time.Minute)
 	}
 
-	logrus.Info("Server exiting")
+	return times
 }
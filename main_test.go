@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/clients"
+)
+
+// fakePool is a gateway pool whose Drain waits for ctx when busy.
+type fakePool struct {
+	clients.PooledGatewayClient
+	busy bool
+}
+
+func (p *fakePool) Drain(ctx context.Context) error {
+	if !p.busy {
+		return nil
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestDrainExitCodes(t *testing.T) {
+	tests := []struct {
+		name            string
+		requestInFlight bool
+		gatewayBusy     bool
+		want            int
+	}{
+		{name: "nothing in flight", want: exitOK},
+		{name: "request outliving the deadline", requestInFlight: true, want: exitForced},
+		{name: "gateway exchange outliving the deadline", gatewayBusy: true, want: exitForced},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entered := make(chan struct{})
+			release := make(chan struct{})
+			defer close(release)
+
+			server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(entered)
+				<-release
+			})}
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			go server.Serve(listener)
+
+			requestErr := make(chan error, 1)
+			if tt.requestInFlight {
+				go func() {
+					resp, err := http.Get("http://" + listener.Addr().String())
+					if err == nil {
+						resp.Body.Close()
+					}
+					requestErr <- err
+				}()
+				<-entered
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			stopped := false
+			code := drain(ctx, server, func() { stopped = true }, &clients.Gateway{Name: "primary", Pool: &fakePool{busy: tt.gatewayBusy}})
+
+			if code != tt.want {
+				t.Fatalf("exit code %d, want %d", code, tt.want)
+			}
+			if !stopped {
+				t.Fatal("workers not stopped")
+			}
+
+			// The connection of a request past the deadline is closed, it gets no answer.
+			if tt.requestInFlight {
+				select {
+				case err := <-requestErr:
+					if err == nil {
+						t.Fatal("request past the deadline got an answer")
+					}
+				case <-time.After(time.Second):
+					t.Fatal("connection of the request past the deadline left open")
+				}
+			}
+		})
+	}
+}
+
+func TestQueueUnresolvedExitCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored []models.TransactionStatus
+		want   int
+	}{
+		{name: "no transactions", want: exitOK},
+		{name: "every transaction answered", stored: []models.TransactionStatus{models.TransactionApproved, models.TransactionDeclined, models.TransactionReversed}, want: exitOK},
+		{name: "transaction left pending", stored: []models.TransactionStatus{models.TransactionApproved, models.TransactionPending}, want: exitUnresolved},
+		{name: "transaction unresolved before the shutdown", stored: []models.TransactionStatus{models.TransactionUnresolved}, want: exitUnresolved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactions, err := repositories.NewFileTransactionRepository(filepath.Join(t.TempDir(), "transactions.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer transactions.Close()
+
+			ctx := context.Background()
+			for i, status := range tt.stored {
+				tx := &models.Transaction{ID: string(rune('a' + i)), Operation: "authorization", MTI: "0200", Status: status, CreatedAt: time.Now()}
+				if err := transactions.Create(ctx, tx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			worker := services.NewReversalWorker(nil, transactions, time.Minute)
+			if code := queueUnresolved(ctx, worker, transactions); code != tt.want {
+				t.Fatalf("exit code %d, want %d", code, tt.want)
+			}
+
+			if pending, err := transactions.ListByStatus(ctx, models.TransactionPending); err != nil || len(pending) != 0 {
+				t.Fatalf("%d transactions still pending (err %v), want them queued for reversal", len(pending), err)
+			}
+		})
+	}
+}
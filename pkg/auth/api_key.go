@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const apiKeyIDBytes = 8
+
+// ErrMalformedAPIKey is returned when a raw key does not follow the "<id>.<secret>" format.
+var ErrMalformedAPIKey = errors.New("malformed api key")
+
+// GenerateAPIKey creates a new random key. The returned raw value is the only
+// place where the secret appears in plaintext; callers persist the id and the
+// hash produced by HashAPIKeySecret.
+func GenerateAPIKey() (id, secret, raw string, err error) {
+	idBytes := make([]byte, apiKeyIDBytes)
+	if _, err = rand.Read(idBytes); err != nil {
+		return "", "", "", err
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err = rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+
+	id = hex.EncodeToString(idBytes)
+	secret = base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	return id, secret, id + "." + secret, nil
+}
+
+// ParseAPIKey splits a raw key into its id and secret parts.
+func ParseAPIKey(raw string) (id, secret string, err error) {
+	id, secret, ok := strings.Cut(raw, ".")
+	if !ok || len(id) != apiKeyIDBytes*2 || secret == "" {
+		return "", "", ErrMalformedAPIKey
+	}
+
+	return id, secret, nil
+}
+
+// HashAPIKeySecret returns the value stored at rest for a key secret: an
+// HMAC-SHA256 keyed with the server-side pepper, hex encoded.
+func HashAPIKeySecret(pepper, secret string) string {
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CompareAPIKeyHash compares a stored hash against the hash of the presented secret in constant time.
+func CompareAPIKeyHash(pepper, secret, storedHash string) bool {
+	return hmac.Equal([]byte(HashAPIKeySecret(pepper, secret)), []byte(storedHash))
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		wantID     string
+		wantSecret string
+		wantErr    bool
+	}{
+		{name: "valid", raw: "0123456789abcdef.c2VjcmV0", wantID: "0123456789abcdef", wantSecret: "c2VjcmV0"},
+		{name: "secret holding a dot", raw: "0123456789abcdef.a.b", wantID: "0123456789abcdef", wantSecret: "a.b"},
+		{name: "no separator", raw: "0123456789abcdefc2VjcmV0", wantErr: true},
+		{name: "short id", raw: "0123.c2VjcmV0", wantErr: true},
+		{name: "empty secret", raw: "0123456789abcdef.", wantErr: true},
+		{name: "empty", raw: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, secret, err := ParseAPIKey(tt.raw)
+			if tt.wantErr {
+				if !errors.Is(err, ErrMalformedAPIKey) {
+					t.Fatalf("err = %v, want %v", err, ErrMalformedAPIKey)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != tt.wantID || secret != tt.wantSecret {
+				t.Fatalf("parsed %q and %q, want %q and %q", id, secret, tt.wantID, tt.wantSecret)
+			}
+		})
+	}
+}
+
+func TestGenerateAPIKeyParsesBack(t *testing.T) {
+	id, secret, raw, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedID, parsedSecret, err := ParseAPIKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedID != id || parsedSecret != secret {
+		t.Fatalf("parsed %q and %q, want %q and %q", parsedID, parsedSecret, id, secret)
+	}
+}
+
+func TestHashAPIKeySecret(t *testing.T) {
+	hash := HashAPIKeySecret("pepper", "secret")
+
+	if strings.Contains(hash, "secret") || len(hash) != 64 {
+		t.Fatalf("hash = %q, want 64 hex characters not holding the secret", hash)
+	}
+	if HashAPIKeySecret("pepper", "secret") != hash {
+		t.Fatal("hashing the same secret twice gave different hashes")
+	}
+	if HashAPIKeySecret("other pepper", "secret") == hash {
+		t.Fatal("hash does not depend on the pepper")
+	}
+
+	tests := []struct {
+		name   string
+		pepper string
+		secret string
+		want   bool
+	}{
+		{name: "same pepper and secret", pepper: "pepper", secret: "secret", want: true},
+		{name: "other secret", pepper: "pepper", secret: "secreT"},
+		{name: "other pepper", pepper: "other pepper", secret: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareAPIKeyHash(tt.pepper, tt.secret, hash); got != tt.want {
+				t.Fatalf("CompareAPIKeyHash = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
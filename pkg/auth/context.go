@@ -0,0 +1,37 @@
+// Package auth holds the primitives shared by the authentication middlewares and
+// the services: the authenticated principal bound to the request context and the
+// API key format and hashing.
+package auth
+
+import "context"
+
+type (
+	// Principal identifies the caller authenticated for the current request.
+	Principal struct {
+		MerchantID string
+		KeyID      string
+	}
+
+	principalCtxKey struct{}
+)
+
+// WithPrincipal returns a copy of ctx carrying the authenticated principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal bound to ctx, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalCtxKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// MerchantFromContext returns the authenticated merchant ID or an empty string
+// when the request is not authenticated.
+func MerchantFromContext(ctx context.Context) string {
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return principal.MerchantID
+	}
+
+	return ""
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// NonceStore remembers the nonces already seen so replayed requests can be rejected.
+	NonceStore interface {
+		// Use records the nonce for ttl and reports whether it was unused.
+		Use(nonce string, ttl time.Duration) bool
+	}
+
+	memoryNonceStore struct {
+		mu        sync.Mutex
+		seen      map[string]time.Time
+		nextSweep time.Time
+	}
+)
+
+// NewMemoryNonceStore creates a process local NonceStore. Expired nonces are
+// swept lazily while new ones are recorded.
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{seen: map[string]time.Time{}}
+}
+
+func (s *memoryNonceStore) Use(nonce string, ttl time.Duration) bool {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.After(s.nextSweep) {
+		for n, expiresAt := range s.seen {
+			if now.After(expiresAt) {
+				delete(s.seen, n)
+			}
+		}
+		s.nextSweep = now.Add(ttl)
+	}
+
+	if expiresAt, ok := s.seen[nonce]; ok && now.Before(expiresAt) {
+		return false
+	}
+
+	s.seen[nonce] = now.Add(ttl)
+	return true
+}
@@ -0,0 +1,105 @@
+package certs
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+var (
+	ErrPinMismatch       = errors.New("gateway certificate does not match any pinned public key")
+	ErrNoPeerCertificate = errors.New("gateway presented no certificate")
+)
+
+// clientAuthTypes maps the configured client authentication mode to crypto/tls.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":        tls.NoClientCert,
+	"none":    tls.NoClientCert,
+	"request": tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAndVerifyClientCert,
+}
+
+// NewServerTLSConfig builds the listener configuration. The certificate and the
+// client CA bundle are read from the reloader on every handshake so renewed
+// files are used by new connections right away.
+func NewServerTLSConfig(cfg configs.TLSConfig, reloader *Reloader) (*tls.Config, error) {
+	clientAuth, ok := clientAuthTypes[cfg.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unknown tls.clientAuth %q, use none, request or require", cfg.ClientAuth)
+	}
+
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		ClientAuth:     clientAuth,
+	}
+
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.ClientCAs = reloader.CAPool()
+		return config, nil
+	}
+
+	return base, nil
+}
+
+// NewGatewayTLSConfig builds the configuration used to dial the gateway. The
+// chain is verified against the configured CA bundle (or the system roots) and,
+// when pins are configured, one certificate of the chain must carry a pinned
+// public key (base64 SHA-256 of the SubjectPublicKeyInfo).
+func NewGatewayTLSConfig(cfg configs.GatewayTLSConfig, reloader *Reloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		ServerName:           cfg.ServerName,
+		GetClientCertificate: reloader.GetClientCertificate,
+		// Verification is done in VerifyConnection so the CA bundle can be reloaded.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return ErrNoPeerCertificate
+			}
+
+			intermediates := x509.NewCertPool()
+			for _, cert := range state.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+
+			chains, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+				DNSName:       state.ServerName,
+				Roots:         reloader.CAPool(),
+				Intermediates: intermediates,
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(cfg.PinnedSHA256) == 0 {
+				return nil
+			}
+
+			for _, chain := range chains {
+				for _, cert := range chain {
+					if slices.Contains(cfg.PinnedSHA256, SPKIFingerprint(cert)) {
+						return nil
+					}
+				}
+			}
+
+			return ErrPinMismatch
+		},
+	}
+}
+
+// SPKIFingerprint returns the base64 SHA-256 of the certificate public key, the
+// format expected in gatewayTLS.pinnedSHA256.
+func SPKIFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,115 @@
+// Package certs loads the TLS material used by the HTTP listener and the gateway
+// client, and reloads it when the files change on disk so certificates can be
+// renewed without a restart.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/filewatch"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrNoCertificates = errors.New("no certificates found in CA file")
+
+type (
+	// Reloader holds a certificate/key pair and an optional CA bundle, swapping
+	// them atomically whenever one of the files is rewritten.
+	Reloader struct {
+		name     string
+		certFile string
+		keyFile  string
+		caFile   string
+
+		cert    atomic.Pointer[tls.Certificate]
+		caPool  atomic.Pointer[x509.CertPool]
+		watcher *filewatch.Watcher
+	}
+)
+
+// NewReloader loads the given files and starts watching them. Any of the paths
+// may be empty: a reloader with only a CA file is used to verify peers, one with
+// only a certificate and key is used to present an identity.
+func NewReloader(name, certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{name: name, certFile: certFile, keyFile: keyFile, caFile: caFile}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := filewatch.New([]string{certFile, keyFile, caFile}, r.reload, func(err error) {
+		logrus.WithError(err).Warnf("%s TLS file watcher error", r.name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.watcher = watcher
+
+	return r, nil
+}
+
+// GetCertificate is meant for tls.Config.GetCertificate on servers.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// GetClientCertificate is meant for tls.Config.GetClientCertificate on clients.
+func (r *Reloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if cert := r.cert.Load(); cert != nil {
+		return cert, nil
+	}
+
+	return &tls.Certificate{}, nil
+}
+
+// CAPool returns the current CA bundle, or nil when no CA file is configured.
+func (r *Reloader) CAPool() *x509.CertPool {
+	return r.caPool.Load()
+}
+
+// Close stops watching the files.
+func (r *Reloader) Close() error {
+	return r.watcher.Close()
+}
+
+func (r *Reloader) load() error {
+	if r.certFile != "" || r.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("loading %s certificate: %w", r.name, err)
+		}
+		r.cert.Store(&cert)
+	}
+
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("loading %s CA: %w", r.name, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("loading %s CA: %w", r.name, ErrNoCertificates)
+		}
+		r.caPool.Store(pool)
+	}
+
+	return nil
+}
+
+func (r *Reloader) reload() {
+	// A failed reload keeps serving the previous material; a half written pair is
+	// picked up on the next event.
+	if err := r.load(); err != nil {
+		logrus.WithError(err).Warnf("Failed to reload %s TLS material, keeping the previous one", r.name)
+		return
+	}
+
+	logrus.Infof("%s TLS material reloaded", r.name)
+}
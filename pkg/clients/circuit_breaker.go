@@ -0,0 +1,230 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+)
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitHalfOpen
+	CircuitOpen
+)
+
+var ErrCircuitOpen = errors.New("gateway circuit breaker is open")
+
+type (
+	CircuitState int
+
+	// StateChangeFunc is notified of every circuit breaker transition.
+	StateChangeFunc func(from, to CircuitState)
+
+	// CircuitBreakerClient is a GatewayClient that stops calling the gateway once
+	// too many exchanges fail, answering with ErrCircuitOpen instead of waiting
+	// for the full timeout.
+	CircuitBreakerClient interface {
+		GatewayClient
+		State() CircuitState
+		OnStateChange(fn StateChangeFunc)
+	}
+
+	circuitBreakerClient struct {
+		GatewayClient
+		name string
+		cfg  configs.CircuitBreakerConfig
+
+		mu          sync.Mutex
+		state       CircuitState
+		windowStart time.Time
+		requests    int
+		failures    int
+		openedAt    time.Time
+		probes      int    // probes in flight while half-open
+		probeOKs    int    // successful probes while half-open
+		generation  uint64 // incremented on every transition, ties probe results to their half-open period
+		listeners   []StateChangeFunc
+	}
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// NewCircuitBreakerClient wraps inner, the client of the gateway name, with a
+// circuit breaker.
+//
+// While closed, failures and timeouts are counted over a fixed window and the
+// circuit opens once at least MinRequests were made and the failure ratio
+// reaches FailureRatio. After OpenTimeout the circuit half-opens and lets up to
+// HalfOpenProbes requests through: if they all succeed it closes, the first
+// failure opens it again.
+func NewCircuitBreakerClient(name string, inner GatewayClient, cfg configs.CircuitBreakerConfig) CircuitBreakerClient {
+	return &circuitBreakerClient{GatewayClient: inner, name: name, cfg: cfg, windowStart: time.Now()}
+}
+
+func (c *circuitBreakerClient) Send(ctx context.Context, msg *Message) (*Message, error) {
+	if !c.cfg.Enabled {
+		return c.GatewayClient.Send(ctx, msg)
+	}
+
+	probe, generation, err := c.before()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.GatewayClient.Send(ctx, msg)
+
+	// A caller giving up says nothing about the gateway health.
+	if errors.Is(err, context.Canceled) {
+		c.abandon(probe, generation)
+		return resp, err
+	}
+
+	c.after(probe, generation, err == nil)
+	return resp, err
+}
+
+func (c *circuitBreakerClient) State() CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state
+}
+
+func (c *circuitBreakerClient) OnStateChange(fn StateChangeFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.listeners = append(c.listeners, fn)
+}
+
+// before decides whether a request may go through and whether it is a probe.
+func (c *circuitBreakerClient) before() (probe bool, generation uint64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	switch c.state {
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < c.cfg.OpenTimeout {
+			return false, c.generation, ErrCircuitOpen
+		}
+		c.transition(CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= max(c.cfg.HalfOpenProbes, 1) {
+			return false, c.generation, ErrCircuitOpen
+		}
+		c.probes++
+		return true, c.generation, nil
+	}
+
+	if now.Sub(c.windowStart) >= c.cfg.Window {
+		c.resetWindow(now)
+	}
+
+	return false, c.generation, nil
+}
+
+func (c *circuitBreakerClient) after(probe bool, generation uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Results of requests started before the last transition are stale.
+	if generation != c.generation {
+		return
+	}
+
+	if probe {
+		c.probes--
+
+		if !ok {
+			c.open()
+			return
+		}
+
+		c.probeOKs++
+		if c.probeOKs >= max(c.cfg.HalfOpenProbes, 1) {
+			c.resetWindow(time.Now())
+			c.transition(CircuitClosed)
+		}
+		return
+	}
+
+	c.requests++
+	if !ok {
+		c.failures++
+	}
+
+	if c.requests >= c.cfg.MinRequests && float64(c.failures)/float64(c.requests) >= c.cfg.FailureRatio {
+		c.open()
+	}
+}
+
+func (c *circuitBreakerClient) abandon(probe bool, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if probe && generation == c.generation {
+		c.probes--
+	}
+}
+
+func (c *circuitBreakerClient) open() {
+	c.openedAt = time.Now()
+	c.transition(CircuitOpen)
+}
+
+func (c *circuitBreakerClient) resetWindow(now time.Time) {
+	c.windowStart = now
+	c.requests = 0
+	c.failures = 0
+}
+
+// transition must be called with the lock held; listeners run synchronously and
+// must not call back into the breaker.
+func (c *circuitBreakerClient) transition(to CircuitState) {
+	from := c.state
+	if from == to {
+		return
+	}
+
+	c.state = to
+	c.generation++
+	metrics.GatewayCircuitState.WithLabelValues(c.name).Set(float64(to))
+	c.probes = 0
+	c.probeOKs = 0
+
+	entry := logger.Component(context.Background(), logger.ComponentGateway).WithFields(logrus.Fields{
+		"gateway": c.name,
+		"from":    from.String(),
+		"to":      to.String(),
+	})
+	if to == CircuitOpen {
+		entry.WithField("requests", c.requests).WithField("failures", c.failures).Warn("gateway circuit breaker opened")
+	} else {
+		entry.Info("gateway circuit breaker state changed")
+	}
+
+	for _, fn := range c.listeners {
+		fn(from, to)
+	}
+}
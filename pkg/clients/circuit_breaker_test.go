@@ -0,0 +1,241 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+var errGateway = errors.New("gateway failed")
+
+// scriptedClient answers every Send with err, blocking while block is set.
+type scriptedClient struct {
+	mu    sync.Mutex
+	err   error
+	calls int
+	block chan struct{}
+}
+
+func (c *scriptedClient) Send(ctx context.Context, msg *Message) (*Message, error) {
+	c.mu.Lock()
+	c.calls++
+	err, block := c.err, c.block
+	c.mu.Unlock()
+
+	if block != nil {
+		<-block
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMessage("0810"), nil
+}
+
+func (c *scriptedClient) Close() error { return nil }
+
+func (c *scriptedClient) set(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	cfg := configs.CircuitBreakerConfig{
+		Enabled:        true,
+		FailureRatio:   0.5,
+		MinRequests:    4,
+		Window:         time.Minute,
+		OpenTimeout:    20 * time.Millisecond,
+		HalfOpenProbes: 2,
+	}
+
+	type step struct {
+		gatewayErr error         // Answer of the gateway.
+		wait       time.Duration // Sleep before sending.
+		wantErr    error
+		wantState  CircuitState
+		wantCalled bool // Whether the gateway was called.
+	}
+
+	tests := []struct {
+		name  string
+		cfg   func(configs.CircuitBreakerConfig) configs.CircuitBreakerConfig
+		steps []step
+	}{
+		{
+			name: "stays closed below the minimum requests",
+			steps: []step{
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+			},
+		},
+		{
+			name: "opens once the failure ratio is reached",
+			steps: []step{
+				{wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+				{wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitOpen, wantCalled: true},
+				{wantErr: ErrCircuitOpen, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "stays closed under the failure ratio",
+			steps: []step{
+				{wantState: CircuitClosed, wantCalled: true},
+				{wantState: CircuitClosed, wantCalled: true},
+				{wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+			},
+		},
+		{
+			name: "counts failures over a fixed window",
+			cfg: func(cfg configs.CircuitBreakerConfig) configs.CircuitBreakerConfig {
+				cfg.Window = 20 * time.Millisecond
+				return cfg
+			},
+			steps: []step{
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+				{wait: 30 * time.Millisecond, wantState: CircuitClosed, wantCalled: true},
+				{wantState: CircuitClosed, wantCalled: true},
+				{wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+			},
+		},
+		{
+			name: "ignores the requests canceled by the caller",
+			steps: []step{
+				{gatewayErr: context.Canceled, wantErr: context.Canceled, wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: context.Canceled, wantErr: context.Canceled, wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: context.Canceled, wantErr: context.Canceled, wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: context.Canceled, wantErr: context.Canceled, wantState: CircuitClosed, wantCalled: true},
+			},
+		},
+		{
+			name: "closes after the half-open probes succeed",
+			cfg: func(cfg configs.CircuitBreakerConfig) configs.CircuitBreakerConfig {
+				cfg.MinRequests = 1
+				return cfg
+			},
+			steps: []step{
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitOpen, wantCalled: true},
+				{wantErr: ErrCircuitOpen, wantState: CircuitOpen},
+				{wait: 30 * time.Millisecond, wantState: CircuitHalfOpen, wantCalled: true},
+				{wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitOpen, wantCalled: true},
+			},
+		},
+		{
+			name: "opens again on a failed probe",
+			cfg: func(cfg configs.CircuitBreakerConfig) configs.CircuitBreakerConfig {
+				cfg.MinRequests = 1
+				return cfg
+			},
+			steps: []step{
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitOpen, wantCalled: true},
+				{wait: 30 * time.Millisecond, wantState: CircuitHalfOpen, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitOpen, wantCalled: true},
+				{wantErr: ErrCircuitOpen, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "passes through when disabled",
+			cfg: func(cfg configs.CircuitBreakerConfig) configs.CircuitBreakerConfig {
+				cfg.Enabled = false
+				cfg.MinRequests = 1
+				return cfg
+			},
+			steps: []step{
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+				{gatewayErr: errGateway, wantErr: errGateway, wantState: CircuitClosed, wantCalled: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			if tt.cfg != nil {
+				cfg = tt.cfg(cfg)
+			}
+
+			inner := &scriptedClient{}
+			breaker := NewCircuitBreakerClient("test", inner, cfg)
+
+			for i, step := range tt.steps {
+				time.Sleep(step.wait)
+				inner.set(step.gatewayErr)
+				calls := inner.calls
+
+				_, err := breaker.Send(context.Background(), NewMessage("0800"))
+				if !errors.Is(err, step.wantErr) {
+					t.Fatalf("step %d: err = %v, want %v", i, err, step.wantErr)
+				}
+				if called := inner.calls > calls; called != step.wantCalled {
+					t.Fatalf("step %d: gateway called = %t, want %t", i, called, step.wantCalled)
+				}
+				if state := breaker.State(); state != step.wantState {
+					t.Fatalf("step %d: state = %s, want %s", i, state, step.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerLimitsHalfOpenProbes(t *testing.T) {
+	cfg := configs.CircuitBreakerConfig{
+		Enabled:        true,
+		FailureRatio:   1,
+		MinRequests:    1,
+		Window:         time.Minute,
+		OpenTimeout:    10 * time.Millisecond,
+		HalfOpenProbes: 1,
+	}
+
+	inner := &scriptedClient{err: errGateway}
+	breaker := NewCircuitBreakerClient("test", inner, cfg)
+
+	breaker.Send(context.Background(), NewMessage("0800"))
+	if state := breaker.State(); state != CircuitOpen {
+		t.Fatalf("state = %s, want open", state)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	// The probe blocks in the gateway while a second request arrives.
+	block := make(chan struct{})
+	inner.mu.Lock()
+	inner.err, inner.block = nil, block
+	inner.mu.Unlock()
+
+	probed := make(chan error)
+	go func() {
+		_, err := breaker.Send(context.Background(), NewMessage("0800"))
+		probed <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for breaker.State() != CircuitHalfOpen && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := breaker.Send(context.Background(), NewMessage("0800")); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request while probing: err = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	close(block)
+	if err := <-probed; err != nil {
+		t.Fatalf("probe: err = %v", err)
+	}
+	if state := breaker.State(); state != CircuitClosed {
+		t.Fatalf("state = %s, want closed", state)
+	}
+}
@@ -0,0 +1,379 @@
+// Package clients implements the connection to the acquirer gateway.
+package clients
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
+)
+
+// maxPoolSize bounds gatewayPoolSize, see its validation, so the pool can be
+// resized at runtime without reallocating its channels.
+const maxPoolSize = 256
+
+var (
+	ErrClientClosed = errors.New("gateway client closed")
+	// ErrNotSent wraps the failures that happened before the message was written,
+	// so the gateway never saw it.
+	ErrNotSent = errors.New("message not sent to the gateway")
+)
+
+type (
+	GatewayClient interface {
+		// Send writes msg to the gateway and waits for its response, bounded by
+		// the gateway timeout and the context deadline.
+		Send(ctx context.Context, msg *Message) (*Message, error)
+		Close() error
+	}
+
+	// PooledGatewayClient is a GatewayClient keeping a pool of connections.
+	PooledGatewayClient interface {
+		GatewayClient
+		Stats() PoolStats
+		// Drain waits until no exchange is in flight or ctx is done.
+		Drain(ctx context.Context) error
+	}
+
+	// PoolStats describes the connection pool. LastDialError is the error of the
+	// last dial, nil once a dial succeeds again.
+	PoolStats struct {
+		Size          int
+		Open          int
+		Idle          int
+		InFlight      int
+		LastDialError error
+	}
+
+	gatewayClient struct {
+		name      string
+		addr      string
+		timeout   atomic.Int64
+		tlsConfig *tls.Config
+		dialer    *net.Dialer
+
+		// slots bounds the number of open connections, idle keeps the connections
+		// available for reuse. The pool holds reserved slots itself so that only
+		// size connections can be open.
+		slots  chan struct{}
+		idle   chan net.Conn
+		closed chan struct{}
+
+		sizeMu   sync.Mutex
+		size     int
+		reserved int
+
+		lastDialErr atomic.Pointer[error]
+		inFlight    atomic.Int64
+	}
+)
+
+// NewGatewayClient creates a pooled client for gateway, sized and timed out by the
+// shared gateway settings of cfgs. A nil tlsConfig dials in plaintext. Reloads of
+// gatewayTimeout and gatewayPoolSize are applied to the client.
+func NewGatewayClient(cfgs *configs.EnvVars, gateway configs.GatewayConfig, tlsConfig *tls.Config) PooledGatewayClient {
+	if tlsConfig != nil && tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = gateway.Host
+	}
+
+	c := &gatewayClient{
+		name:      gateway.Name,
+		addr:      net.JoinHostPort(gateway.Host, fmt.Sprint(gateway.Port)),
+		tlsConfig: tlsConfig,
+		dialer:    &net.Dialer{KeepAlive: 30 * time.Second},
+		slots:     make(chan struct{}, maxPoolSize),
+		idle:      make(chan net.Conn, maxPoolSize),
+		closed:    make(chan struct{}),
+		size:      min(max(cfgs.GatewayPoolSize, 1), maxPoolSize),
+	}
+	c.timeout.Store(int64(cfgs.GatewayTimeout))
+
+	for c.reserved < maxPoolSize-c.size {
+		c.slots <- struct{}{}
+		c.reserved++
+	}
+
+	configs.Subscribe(func(change configs.Change) {
+		if change.Changed("gatewayTimeout") {
+			c.timeout.Store(int64(change.Current.GatewayTimeout))
+		}
+		if change.Changed("gatewayPoolSize") {
+			c.resize(change.Current.GatewayPoolSize)
+		}
+	})
+
+	return c
+}
+
+func (c *gatewayClient) Send(ctx context.Context, msg *Message) (_ *Message, err error) {
+	ctx, span := tracing.StartClient(ctx, "gateway "+msg.MTI,
+		attribute.String("iso.mti", msg.MTI),
+		attribute.String("iso.stan", msg.Get(FieldSTAN)),
+		attribute.String("gateway.name", c.name),
+		attribute.String("server.address", c.addr),
+	)
+	defer tracing.End(span, &err)
+
+	c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+
+	if timeout := time.Duration(c.timeout.Load()); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+
+	resp, err := c.send(ctx, msg)
+
+	metrics.GatewayDuration.WithLabelValues(c.name, msg.MTI, outcome(err)).Observe(time.Since(start).Seconds())
+
+	entry := logger.Component(ctx, logger.ComponentGateway).WithField("gateway", c.name).WithField("mti", msg.MTI).WithField("duration", time.Since(start))
+	if err != nil {
+		entry.WithError(err).Warn("gateway exchange failed")
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("iso.response_code", resp.Get(FieldResponseCode)))
+	entry.WithField("response_code", resp.Get(FieldResponseCode)).Debug("gateway exchange completed")
+
+	return resp, err
+}
+
+func (c *gatewayClient) Stats() PoolStats {
+	c.sizeMu.Lock()
+	size, open := c.size, len(c.slots)-c.reserved
+	c.sizeMu.Unlock()
+
+	stats := PoolStats{Size: size, Open: open, Idle: len(c.idle), InFlight: int(c.inFlight.Load())}
+	if err := c.lastDialErr.Load(); err != nil {
+		stats.LastDialError = *err
+	}
+
+	return stats
+}
+
+func (c *gatewayClient) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(25 * time.Millisecond)
+	defer ticker.Stop()
+
+	for c.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d gateway exchanges still in flight: %w", c.inFlight.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func (c *gatewayClient) Close() error {
+	select {
+	case <-c.closed:
+		return nil
+	default:
+		close(c.closed)
+	}
+
+	for {
+		select {
+		case conn := <-c.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// resize changes the maximum number of open connections. Growing takes effect
+// right away; shrinking reserves slots as idle connections are closed and busy
+// ones are released.
+func (c *gatewayClient) resize(size int) {
+	size = min(max(size, 1), maxPoolSize)
+
+	c.sizeMu.Lock()
+	c.size = size
+	for c.reserved > maxPoolSize-size {
+		<-c.slots
+		c.reserved--
+	}
+	shrink := c.reserved < maxPoolSize-size
+	c.sizeMu.Unlock()
+
+	if shrink {
+		go c.reserve()
+	}
+	c.reportPool()
+}
+
+// reserve takes slots until only size of them are usable, closing idle
+// connections to free theirs.
+func (c *gatewayClient) reserve() {
+	for {
+		c.sizeMu.Lock()
+		needed := maxPoolSize - c.size - c.reserved
+		c.sizeMu.Unlock()
+		if needed <= 0 {
+			return
+		}
+
+		select {
+		case <-c.closed:
+			return
+		case c.slots <- struct{}{}:
+		case conn := <-c.idle:
+			// The slot of the closed connection becomes reserved.
+			conn.Close()
+		}
+
+		c.sizeMu.Lock()
+		c.reserved++
+		// The pool may have grown again meanwhile.
+		if c.reserved > maxPoolSize-c.size {
+			<-c.slots
+			c.reserved--
+		}
+		c.sizeMu.Unlock()
+		c.reportPool()
+	}
+}
+
+func (c *gatewayClient) send(ctx context.Context, msg *Message) (*Message, error) {
+	conn, err := c.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotSent, err)
+	}
+
+	resp, err := c.exchange(ctx, conn, msg)
+	if err != nil {
+		c.discard(conn)
+		return nil, err
+	}
+
+	c.release(conn)
+	return resp, nil
+}
+
+func (c *gatewayClient) exchange(ctx context.Context, conn net.Conn, msg *Message) (*Message, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// Unblock the read if the caller gives up before the deadline.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if err := writeMessage(conn, msg); err != nil {
+		return nil, err
+	}
+
+	resp, err := readMessage(conn)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// acquire returns an idle connection or dials a new one when the pool has room.
+func (c *gatewayClient) acquire(ctx context.Context) (net.Conn, error) {
+	select {
+	case <-c.closed:
+		return nil, ErrClientClosed
+	case conn := <-c.idle:
+		c.reportPool()
+		return conn, nil
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		c.lastDialErr.Store(&err)
+		<-c.slots
+		return nil, err
+	}
+	c.lastDialErr.Store(nil)
+
+	c.reportPool()
+	return conn, nil
+}
+
+func (c *gatewayClient) dial(ctx context.Context) (net.Conn, error) {
+	if c.tlsConfig == nil {
+		return c.dialer.DialContext(ctx, "tcp", c.addr)
+	}
+
+	tlsDialer := &tls.Dialer{NetDialer: c.dialer, Config: c.tlsConfig}
+	conn, err := tlsDialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		logger.Component(ctx, logger.ComponentGateway).WithField("gateway", c.name).WithError(err).Warn("gateway TLS dial failed")
+	}
+
+	return conn, err
+}
+
+func (c *gatewayClient) release(conn net.Conn) {
+	conn.SetDeadline(time.Time{})
+
+	select {
+	case <-c.closed:
+		c.discard(conn)
+	case c.idle <- conn:
+		c.reportPool()
+	default:
+		c.discard(conn)
+	}
+}
+
+func (c *gatewayClient) discard(conn net.Conn) {
+	conn.Close()
+	<-c.slots
+	c.reportPool()
+}
+
+func (c *gatewayClient) reportPool() {
+	c.sizeMu.Lock()
+	open := len(c.slots) - c.reserved
+	c.sizeMu.Unlock()
+
+	metrics.GatewayConnectionsOpen.WithLabelValues(c.name).Set(float64(open))
+	metrics.GatewayConnectionsIdle.WithLabelValues(c.name).Set(float64(len(c.idle)))
+}
+
+// MaybeDelivered reports whether a failed exchange may have reached the gateway,
+// in which case its outcome is unknown and the transaction must be reversed.
+func MaybeDelivered(err error) bool {
+	return err != nil && !errors.Is(err, ErrNotSent) && !errors.Is(err, ErrCircuitOpen)
+}
+
+// outcome classifies an exchange result for the round trip metric.
+func outcome(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,274 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformedMessage is returned when a message cannot be encoded for the
+// gateway or a gateway message cannot be decoded.
+var ErrMalformedMessage = errors.New("malformed iso 8583 message")
+
+type (
+	// fieldKind is the character set of a data element.
+	fieldKind int
+
+	// fieldSpec describes how a data element is encoded.
+	fieldSpec struct {
+		kind   fieldKind
+		length int // Length of a fixed field, maximum length of a variable one.
+		prefix int // Digits of the length prefix of a variable field, 0 for a fixed one.
+	}
+)
+
+const (
+	kindNumeric fieldKind = iota // n: digits, fixed fields padded with leading zeros.
+	kindText                     // a, an, ans: printable characters, fixed fields padded with trailing spaces.
+	kindTrack                    // z: track data, digits and the "=" or "D" separator.
+	kindSigned                   // x+n: "C" for credit or "D" for debit followed by digits.
+	kindBinary                   // b: raw bytes.
+)
+
+func fixed(kind fieldKind, length int) fieldSpec     { return fieldSpec{kind, length, 0} }
+func llvar(kind fieldKind, maxLength int) fieldSpec  { return fieldSpec{kind, maxLength, 2} }
+func lllvar(kind fieldKind, maxLength int) fieldSpec { return fieldSpec{kind, maxLength, 3} }
+
+// fields is the ISO 8583:1987 data element dictionary, with data elements in
+// ASCII and the bitmaps in binary. Field 1, the secondary bitmap, is handled by
+// packMessage and unpackMessage themselves.
+var fields = [129]fieldSpec{
+	2: llvar(kindNumeric, 19), 3: fixed(kindNumeric, 6), 4: fixed(kindNumeric, 12),
+	5: fixed(kindNumeric, 12), 6: fixed(kindNumeric, 12), 7: fixed(kindNumeric, 10),
+	8: fixed(kindNumeric, 8), 9: fixed(kindNumeric, 8), 10: fixed(kindNumeric, 8),
+	11: fixed(kindNumeric, 6), 12: fixed(kindNumeric, 6), 13: fixed(kindNumeric, 4),
+	14: fixed(kindNumeric, 4), 15: fixed(kindNumeric, 4), 16: fixed(kindNumeric, 4),
+	17: fixed(kindNumeric, 4), 18: fixed(kindNumeric, 4), 19: fixed(kindNumeric, 3),
+	20: fixed(kindNumeric, 3), 21: fixed(kindNumeric, 3), 22: fixed(kindNumeric, 3),
+	23: fixed(kindNumeric, 3), 24: fixed(kindNumeric, 3), 25: fixed(kindNumeric, 2),
+	26: fixed(kindNumeric, 2), 27: fixed(kindNumeric, 1), 28: fixed(kindSigned, 9),
+	29: fixed(kindSigned, 9), 30: fixed(kindSigned, 9), 31: fixed(kindSigned, 9),
+	32: llvar(kindNumeric, 11), 33: llvar(kindNumeric, 11), 34: llvar(kindText, 28),
+	35: llvar(kindTrack, 37), 36: lllvar(kindTrack, 104), 37: fixed(kindText, 12),
+	38: fixed(kindText, 6), 39: fixed(kindText, 2), 40: fixed(kindText, 3),
+	41: fixed(kindText, 8), 42: fixed(kindText, 15), 43: fixed(kindText, 40),
+	44: llvar(kindText, 25), 45: llvar(kindText, 76), 46: lllvar(kindText, 999),
+	47: lllvar(kindText, 999), 48: lllvar(kindText, 999), 49: fixed(kindText, 3),
+	50: fixed(kindText, 3), 51: fixed(kindText, 3), 52: fixed(kindBinary, 8),
+	53: fixed(kindNumeric, 16), 54: lllvar(kindText, 120), 55: lllvar(kindText, 999),
+	56: lllvar(kindText, 999), 57: lllvar(kindText, 999), 58: lllvar(kindText, 999),
+	59: lllvar(kindText, 999), 60: lllvar(kindText, 999), 61: lllvar(kindText, 999),
+	62: lllvar(kindText, 999), 63: lllvar(kindText, 999), 64: fixed(kindBinary, 8),
+	65: fixed(kindBinary, 1), 66: fixed(kindNumeric, 1), 67: fixed(kindNumeric, 2),
+	68: fixed(kindNumeric, 3), 69: fixed(kindNumeric, 3), 70: fixed(kindNumeric, 3),
+	71: fixed(kindNumeric, 4), 72: fixed(kindNumeric, 4), 73: fixed(kindNumeric, 6),
+	74: fixed(kindNumeric, 10), 75: fixed(kindNumeric, 10), 76: fixed(kindNumeric, 10),
+	77: fixed(kindNumeric, 10), 78: fixed(kindNumeric, 10), 79: fixed(kindNumeric, 10),
+	80: fixed(kindNumeric, 10), 81: fixed(kindNumeric, 10), 82: fixed(kindNumeric, 12),
+	83: fixed(kindNumeric, 12), 84: fixed(kindNumeric, 12), 85: fixed(kindNumeric, 12),
+	86: fixed(kindNumeric, 16), 87: fixed(kindNumeric, 16), 88: fixed(kindNumeric, 16),
+	89: fixed(kindNumeric, 16), 90: fixed(kindNumeric, 42), 91: fixed(kindText, 1),
+	92: fixed(kindText, 2), 93: fixed(kindText, 5), 94: fixed(kindText, 7),
+	95: fixed(kindText, 42), 96: fixed(kindBinary, 8), 97: fixed(kindSigned, 17),
+	98: fixed(kindText, 25), 99: llvar(kindNumeric, 11), 100: llvar(kindNumeric, 11),
+	101: llvar(kindText, 17), 102: llvar(kindText, 28), 103: llvar(kindText, 28),
+	104: lllvar(kindText, 100), 105: lllvar(kindText, 999), 106: lllvar(kindText, 999),
+	107: lllvar(kindText, 999), 108: lllvar(kindText, 999), 109: lllvar(kindText, 999),
+	110: lllvar(kindText, 999), 111: lllvar(kindText, 999), 112: lllvar(kindText, 999),
+	113: lllvar(kindText, 999), 114: lllvar(kindText, 999), 115: lllvar(kindText, 999),
+	116: lllvar(kindText, 999), 117: lllvar(kindText, 999), 118: lllvar(kindText, 999),
+	119: lllvar(kindText, 999), 120: lllvar(kindText, 999), 121: lllvar(kindText, 999),
+	122: lllvar(kindText, 999), 123: lllvar(kindText, 999), 124: lllvar(kindText, 999),
+	125: lllvar(kindText, 999), 126: lllvar(kindText, 999), 127: lllvar(kindText, 999),
+	128: fixed(kindBinary, 8),
+}
+
+// packMessage encodes msg: the MTI, the primary bitmap, the secondary bitmap
+// when a field above 64 is present, then the data elements in field order.
+// Fields set to an empty value are left out.
+func packMessage(msg *Message) ([]byte, error) {
+	if !isDigits(msg.MTI) || len(msg.MTI) != 4 {
+		return nil, fmt.Errorf("%w: invalid mti %q", ErrMalformedMessage, msg.MTI)
+	}
+
+	var bitmap [16]byte
+	secondary := false
+	for field, value := range msg.Fields {
+		if field < 2 || field >= len(fields) {
+			return nil, fmt.Errorf("%w: unknown field %d", ErrMalformedMessage, field)
+		}
+		if value == "" {
+			continue
+		}
+
+		setBit(bitmap[:], field)
+		secondary = secondary || field > 64
+	}
+
+	bitmapSize := 8
+	if secondary {
+		setBit(bitmap[:], 1)
+		bitmapSize = 16
+	}
+
+	data := append([]byte(msg.MTI), bitmap[:bitmapSize]...)
+	for field := 2; field <= bitmapSize*8; field++ {
+		if !hasBit(bitmap[:], field) {
+			continue
+		}
+
+		encoded, err := packField(fields[field], msg.Fields[field])
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %d: %v", ErrMalformedMessage, field, err)
+		}
+		data = append(data, encoded...)
+	}
+
+	return data, nil
+}
+
+// unpackMessage decodes a message encoded by packMessage. Trailing spaces of
+// fixed text fields are removed.
+func unpackMessage(data []byte) (*Message, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("%w: truncated header", ErrMalformedMessage)
+	}
+
+	msg := NewMessage(string(data[:4]))
+	if !isDigits(msg.MTI) {
+		return nil, fmt.Errorf("%w: invalid mti %q", ErrMalformedMessage, msg.MTI)
+	}
+
+	bitmapSize := 8
+	if hasBit(data[4:], 1) {
+		bitmapSize = 16
+	}
+	if len(data) < 4+bitmapSize {
+		return nil, fmt.Errorf("%w: truncated bitmap", ErrMalformedMessage)
+	}
+
+	bitmap := data[4 : 4+bitmapSize]
+	rest := data[4+bitmapSize:]
+	for field := 2; field <= bitmapSize*8; field++ {
+		if !hasBit(bitmap, field) {
+			continue
+		}
+
+		value, n, err := unpackField(fields[field], rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %d: %v", ErrMalformedMessage, field, err)
+		}
+		msg.Set(field, value)
+		rest = rest[n:]
+	}
+
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: %d bytes after the last field", ErrMalformedMessage, len(rest))
+	}
+
+	return msg, nil
+}
+
+func packField(spec fieldSpec, value string) ([]byte, error) {
+	if len(value) > spec.length {
+		return nil, fmt.Errorf("%d characters, at most %d allowed", len(value), spec.length)
+	}
+	if err := spec.validate(value); err != nil {
+		return nil, err
+	}
+
+	if spec.prefix > 0 {
+		return fmt.Appendf(nil, "%0*d%s", spec.prefix, len(value), value), nil
+	}
+
+	return []byte(spec.pad(value)), nil
+}
+
+// unpackField decodes the field at the start of data and returns its value and
+// the number of bytes it took.
+func unpackField(spec fieldSpec, data []byte) (string, int, error) {
+	length, start := spec.length, 0
+	if spec.prefix > 0 {
+		if len(data) < spec.prefix {
+			return "", 0, errors.New("truncated length prefix")
+		}
+
+		prefix := string(data[:spec.prefix])
+		n, err := strconv.Atoi(prefix)
+		if err != nil || !isDigits(prefix) {
+			return "", 0, fmt.Errorf("invalid length prefix %q", prefix)
+		}
+		if n > spec.length {
+			return "", 0, fmt.Errorf("%d characters, at most %d allowed", n, spec.length)
+		}
+		length, start = n, spec.prefix
+	}
+
+	if len(data) < start+length {
+		return "", 0, errors.New("truncated value")
+	}
+
+	value := string(data[start : start+length])
+	if err := spec.validate(value); err != nil {
+		return "", 0, err
+	}
+	if spec.prefix == 0 && spec.kind == kindText {
+		value = strings.TrimRight(value, " ")
+	}
+
+	return value, start + length, nil
+}
+
+// pad fills a fixed field up to its length: numeric digits with leading zeros
+// and text with trailing spaces.
+func (s fieldSpec) pad(value string) string {
+	missing := s.length - len(value)
+	if s.prefix > 0 || missing <= 0 {
+		return value
+	}
+
+	switch s.kind {
+	case kindNumeric:
+		return strings.Repeat("0", missing) + value
+	case kindSigned:
+		return value[:1] + strings.Repeat("0", missing) + value[1:]
+	default:
+		return value + strings.Repeat(" ", missing)
+	}
+}
+
+func (s fieldSpec) validate(value string) error {
+	valid := true
+	switch s.kind {
+	case kindNumeric:
+		valid = isDigits(value)
+	case kindText:
+		valid = strings.IndexFunc(value, func(r rune) bool { return r < ' ' || r > '~' }) < 0
+	case kindTrack:
+		valid = strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '=' && r != 'D' }) < 0
+	case kindSigned:
+		valid = len(value) > 1 && (value[0] == 'C' || value[0] == 'D') && isDigits(value[1:])
+	case kindBinary:
+		valid = len(value) == s.length
+	}
+
+	if !valid {
+		return fmt.Errorf("invalid value %q", value)
+	}
+	return nil
+}
+
+func isDigits(value string) bool {
+	return strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' }) < 0
+}
+
+// setBit sets the bit of field in bitmap, field 1 being the most significant
+// bit of the first byte.
+func setBit(bitmap []byte, field int) {
+	bitmap[(field-1)/8] |= 0x80 >> ((field - 1) % 8)
+}
+
+func hasBit(bitmap []byte, field int) bool {
+	return bitmap[(field-1)/8]&(0x80>>((field-1)%8)) != 0
+}
@@ -0,0 +1,164 @@
+package clients
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestPackMessageGolden(t *testing.T) {
+	msg := NewMessage("0800").
+		Set(FieldTransmissionDateTime, "1019103000").
+		Set(FieldSTAN, "123").
+		Set(FieldNetworkCode, "001")
+
+	got, err := packMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte("0800")
+	want = append(want, 0x82, 0x20, 0, 0, 0, 0, 0, 0) // Fields 1, 7 and 11.
+	want = append(want, 0x04, 0, 0, 0, 0, 0, 0, 0)    // Field 70.
+	want = append(want, "1019103000000123001"...)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("packed\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestPackMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *Message
+		want map[int]string // Fields decoded, the ones of msg when nil.
+	}{
+		{
+			name: "financial request",
+			msg: NewMessage("0200").
+				Set(FieldProcessingCode, "003000").
+				Set(FieldAmount, "000000001000").
+				Set(FieldSTAN, "000042").
+				Set(FieldEntryMode, "051").
+				Set(FieldTrack2, "4111111111111111=30122010000000000000").
+				Set(FieldRRN, "629910000042").
+				Set(FieldTerminalID, "T1000001").
+				Set(FieldMerchantID, "M1000000000001X").
+				Set(FieldCurrency, "986"),
+		},
+		{
+			name: "fixed fields padded",
+			msg: NewMessage("0210").
+				Set(FieldAmount, "1000").
+				Set(FieldAuthorizationCode, "A1").
+				Set(FieldTerminalID, "T1"),
+			want: map[int]string{FieldAmount: "000000001000", FieldAuthorizationCode: "A1", FieldTerminalID: "T1"},
+		},
+		{
+			name: "variable fields",
+			msg: NewMessage("0200").
+				Set(FieldPAN, "4111111111111111").
+				Set(60, strings.Repeat("b", 999)),
+		},
+		{
+			name: "secondary bitmap",
+			msg: NewMessage("0500").
+				Set(74, "0000000002").
+				Set(88, "0000000000003500").
+				Set(FieldOriginalData, "020000004210191030000000000000000000000000").
+				Set(97, "D0000000000002200"),
+		},
+		{
+			name: "empty fields left out",
+			msg:  NewMessage("0800").Set(FieldSTAN, "000001").Set(FieldTerminalID, ""),
+			want: map[int]string{FieldSTAN: "000001"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var frame bytes.Buffer
+			if err := writeMessage(&frame, tt.msg); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readMessage(&frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := tt.want
+			if want == nil {
+				want = tt.msg.Fields
+			}
+			if got.MTI != tt.msg.MTI || !maps.Equal(got.Fields, want) {
+				t.Fatalf("decoded %s %v, want %s %v", got.MTI, got.Fields, tt.msg.MTI, want)
+			}
+			if frame.Len() > 0 {
+				t.Fatalf("%d bytes left after the frame", frame.Len())
+			}
+		})
+	}
+}
+
+func TestPackMessageRejectsInvalidMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *Message
+	}{
+		{name: "short mti", msg: NewMessage("080")},
+		{name: "non numeric mti", msg: NewMessage("08A0")},
+		{name: "field 1", msg: NewMessage("0800").Set(1, "x")},
+		{name: "field above 128", msg: NewMessage("0800").Set(129, "x")},
+		{name: "fixed field too long", msg: NewMessage("0200").Set(FieldSTAN, "1234567")},
+		{name: "variable field too long", msg: NewMessage("0200").Set(FieldPAN, strings.Repeat("4", 20))},
+		{name: "letters in a numeric field", msg: NewMessage("0200").Set(FieldAmount, "10.00")},
+		{name: "control character in a text field", msg: NewMessage("0200").Set(FieldTerminalID, "T1\n")},
+		{name: "letters in track data", msg: NewMessage("0200").Set(FieldTrack2, "4111=30X2")},
+		{name: "signed amount without sign", msg: NewMessage("0500").Set(97, "00000000000002200")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := packMessage(tt.msg); !errors.Is(err, ErrMalformedMessage) {
+				t.Fatalf("err = %v, want %v", err, ErrMalformedMessage)
+			}
+		})
+	}
+}
+
+func TestUnpackMessageRejectsMalformedData(t *testing.T) {
+	// header returns an MTI and a primary bitmap with the fields set.
+	header := func(fields ...int) []byte {
+		bitmap := make([]byte, 8)
+		for _, field := range fields {
+			setBit(bitmap, field)
+		}
+		return append([]byte("0210"), bitmap...)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated header", data: []byte("0210")},
+		{name: "non numeric mti", data: append([]byte("02X0"), make([]byte, 8)...)},
+		{name: "truncated secondary bitmap", data: header(1)},
+		{name: "truncated fixed field", data: append(header(FieldSTAN), "0001"...)},
+		{name: "truncated length prefix", data: append(header(FieldPAN), "1"...)},
+		{name: "invalid length prefix", data: append(header(FieldPAN), "1x4111"...)},
+		{name: "length prefix above the maximum", data: append(header(FieldPAN), "20"+strings.Repeat("4", 20)...)},
+		{name: "truncated variable field", data: append(header(FieldPAN), "164111"...)},
+		{name: "letters in a numeric field", data: append(header(FieldSTAN), "00000A"...)},
+		{name: "bytes after the last field", data: append(header(FieldResponseCode), "00extra"...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := unpackMessage(tt.data); !errors.Is(err, ErrMalformedMessage) {
+				t.Fatalf("err = %v, want %v", err, ErrMalformedMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package clients
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+)
+
+// ISO 8583 data elements used by the financial operations.
+const (
+	FieldPAN                   = 2
+	FieldProcessingCode        = 3
+	FieldAmount                = 4
+	FieldTransmissionDateTime  = 7
+	FieldSTAN                  = 11
+	FieldLocalTime             = 12
+	FieldLocalDate             = 13
+	FieldSettlementDate        = 15
+	FieldMerchantType          = 18
+	FieldEntryMode             = 22
+	FieldTrack2                = 35
+	FieldRRN                   = 37
+	FieldAuthorizationCode     = 38
+	FieldResponseCode          = 39
+	FieldTerminalID            = 41
+	FieldMerchantID            = 42
+	FieldCurrency              = 49
+	FieldBatchNumber           = 60 // Private use, the batch of the settlement and batch upload messages.
+	FieldNetworkCode           = 70
+	FieldCreditsNumber         = 74
+	FieldCreditsReversalNumber = 75
+	FieldDebitsNumber          = 76
+	FieldDebitsReversalNumber  = 77
+	FieldCreditsAmount         = 86
+	FieldCreditsReversalAmount = 87
+	FieldDebitsAmount          = 88
+	FieldDebitsReversalAmount  = 89
+	FieldOriginalData          = 90
+	FieldNetSettlementAmount   = 97
+)
+
+// Network management codes (field 70) of the 0800 messages.
+const (
+	NetworkSignOn   = "001"
+	NetworkSignOff  = "002"
+	NetworkEchoTest = "301"
+)
+
+// maxFrameSize is the largest payload representable by the 2 byte length header.
+const maxFrameSize = 1<<16 - 1
+
+var ErrFrameTooLarge = errors.New("gateway message exceeds the maximum frame size")
+
+type (
+	// Message is an ISO 8583 message: the message type indicator and its data
+	// elements indexed by field number.
+	Message struct {
+		MTI    string         `json:"mti"`
+		Fields map[int]string `json:"fields"`
+	}
+)
+
+func NewMessage(mti string) *Message {
+	return &Message{MTI: mti, Fields: map[int]string{}}
+}
+
+// Set assigns a data element and returns the message for chaining.
+func (m *Message) Set(field int, value string) *Message {
+	m.Fields[field] = value
+	return m
+}
+
+// Get returns a data element or an empty string when it is absent.
+func (m *Message) Get(field int) string {
+	return m.Fields[field]
+}
+
+// stanCounter generates the System Trace Audit Number of outgoing messages.
+var stanCounter atomic.Uint32
+
+// NextSTAN returns the next six digit STAN, wrapping from 999999 back to 000001.
+func NextSTAN() string {
+	return fmt.Sprintf("%06d", (stanCounter.Add(1)-1)%999999+1)
+}
+
+// SeedSTAN resumes the STAN sequence after last, the latest STAN sent before a
+// restart, so the STANs of a day are not issued twice. Invalid values are ignored.
+func SeedSTAN(last string) {
+	n, err := strconv.Atoi(last)
+	if err != nil || n < 1 || n > 999999 {
+		return
+	}
+
+	stanCounter.Store(uint32(n))
+}
+
+// writeMessage frames msg as a 2 byte big-endian length followed by the
+// encoded message.
+func writeMessage(w io.Writer, msg *Message) error {
+	payload, err := packMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxFrameSize {
+		return ErrFrameTooLarge
+	}
+
+	frame := make([]byte, 2+len(payload))
+	binary.BigEndian.PutUint16(frame, uint16(len(payload)))
+	copy(frame[2:], payload)
+
+	_, err = w.Write(frame)
+	return err
+}
+
+// readMessage reads one length prefixed frame and decodes it.
+func readMessage(r io.Reader) (*Message, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	msg, err := unpackMessage(payload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding gateway message: %w", err)
+	}
+
+	return msg, nil
+}
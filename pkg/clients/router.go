@@ -0,0 +1,173 @@
+package clients
+
+import (
+	"crypto/tls"
+	"slices"
+	"strings"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+// failoverOperations are the operations sent to the failover gateway when their
+// gateway cannot be reached. Pre-authorizations and follow-up operations never
+// fail over: the gateway holding the held amount or the original transaction is
+// the only one able to process what follows them.
+var failoverOperations = []string{"authorization"}
+
+type (
+	// Gateway is the connection to one configured gateway: its connection pool,
+	// wrapped by its circuit breaker, and its session.
+	Gateway struct {
+		Name    string
+		Pool    PooledGatewayClient
+		Client  CircuitBreakerClient
+		Session *Session
+	}
+
+	// Payment holds the attributes of a payment matched by the routing rules.
+	Payment struct {
+		PAN        string
+		MerchantID string
+		Currency   string
+		Operation  string
+	}
+
+	// Router selects the gateway of each payment from the routing rules.
+	Router struct {
+		cfg      configs.RoutingConfig
+		gateways []*Gateway
+	}
+)
+
+// NewGateway creates the client and the session of gateway; call Start on the
+// session to sign on. A nil tlsConfig dials in plaintext.
+func NewGateway(cfgs *configs.EnvVars, gateway configs.GatewayConfig, tlsConfig *tls.Config) *Gateway {
+	pool := NewGatewayClient(cfgs, gateway, tlsConfig)
+	client := NewCircuitBreakerClient(gateway.Name, pool, cfgs.GatewayCircuitBreaker)
+
+	return &Gateway{
+		Name:    gateway.Name,
+		Pool:    pool,
+		Client:  client,
+		Session: NewSession(gateway.Name, client, cfgs.GatewayEchoInterval),
+	}
+}
+
+// Err reports why the gateway cannot take requests: its session is not signed
+// on, its circuit breaker is open or its last dial failed. It is nil when the
+// gateway is usable.
+func (g *Gateway) Err() error {
+	if err := g.Session.Err(); err != nil {
+		return err
+	}
+	if g.Client.State() == CircuitOpen {
+		return ErrCircuitOpen
+	}
+
+	return g.Pool.Stats().LastDialError
+}
+
+// NewRouter creates a router over gateways, given in configuration order. cfg
+// must only reference these gateways, which the configuration validation ensures.
+func NewRouter(cfg configs.RoutingConfig, gateways []*Gateway) *Router {
+	return &Router{cfg: cfg, gateways: gateways}
+}
+
+// Route returns the gateway of payment and, for the operations allowed to fail
+// over, the gateway to send it to when the first one cannot be reached, nil when
+// there is none.
+func (r *Router) Route(payment Payment) (primary, failover *Gateway) {
+	gateway, failoverName := r.cfg.Default, r.cfg.Failover
+
+	for _, rule := range r.cfg.Rules {
+		if matches(rule, payment) {
+			gateway, failoverName = rule.Gateway, rule.Failover
+			break
+		}
+	}
+
+	primary = r.Gateway(gateway)
+	if slices.Contains(failoverOperations, payment.Operation) && failoverName != "" {
+		failover = r.Gateway(failoverName)
+	}
+
+	return primary, failover
+}
+
+// DefaultRoute returns the default gateway and its failover, nil when there is
+// none: the route of the payments matching no rule.
+func (r *Router) DefaultRoute() (primary, failover *Gateway) {
+	primary = r.Gateway(r.cfg.Default)
+	if r.cfg.Failover != "" {
+		failover = r.Gateway(r.cfg.Failover)
+	}
+
+	return primary, failover
+}
+
+// Gateway returns the gateway called name, or the default gateway when name is
+// empty, as for the transactions recorded before routing existed. It returns nil
+// when the gateway is no longer configured.
+func (r *Router) Gateway(name string) *Gateway {
+	if name == "" {
+		name = r.cfg.Default
+	}
+	if name == "" && len(r.gateways) > 0 {
+		return r.gateways[0]
+	}
+
+	for _, gateway := range r.gateways {
+		if gateway.Name == name {
+			return gateway
+		}
+	}
+
+	return nil
+}
+
+// Gateways returns every gateway in configuration order.
+func (r *Router) Gateways() []*Gateway {
+	return r.gateways
+}
+
+// matches reports whether every condition set in rule holds for payment.
+func matches(rule configs.RoutingRule, payment Payment) bool {
+	if len(rule.Merchants) > 0 && !slices.Contains(rule.Merchants, payment.MerchantID) {
+		return false
+	}
+	if len(rule.Currencies) > 0 && !slices.Contains(rule.Currencies, payment.Currency) {
+		return false
+	}
+	if len(rule.Operations) > 0 && !slices.Contains(rule.Operations, payment.Operation) {
+		return false
+	}
+	if len(rule.BINRanges) > 0 && !slices.ContainsFunc(rule.BINRanges, func(binRange configs.BINRange) bool {
+		return inRange(binRange, payment.PAN)
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// inRange compares the leading digits of pan, as many as in the bounds of binRange.
+func inRange(binRange configs.BINRange, pan string) bool {
+	if len(pan) < len(binRange.From) {
+		return false
+	}
+
+	bin := pan[:len(binRange.From)]
+	return bin >= binRange.From && bin <= binRange.To
+}
+
+// PANFromTrack2 returns the card number of the track 2 data, the digits before
+// the field separator, "=" or "D".
+func PANFromTrack2(track2 string) string {
+	track2 = strings.TrimPrefix(track2, ";")
+
+	if i := strings.IndexAny(track2, "=D"); i >= 0 {
+		return track2[:i]
+	}
+
+	return track2
+}
@@ -0,0 +1,109 @@
+package clients
+
+import (
+	"testing"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+func TestRouterRoute(t *testing.T) {
+	visa := configs.BINRange{From: "400000", To: "499999"}
+	router := NewRouter(configs.RoutingConfig{
+		Default:  "primary",
+		Failover: "backup",
+		Rules: []configs.RoutingRule{
+			{Merchants: []string{"M-VIP"}, Gateway: "vip", Failover: "backup"},
+			{BINRanges: []configs.BINRange{visa}, Currencies: []string{"840"}, Gateway: "visa-usd"},
+			{BINRanges: []configs.BINRange{visa}, Operations: []string{"authorization", "pre_authorization"}, Gateway: "visa", Failover: "visa-usd"},
+		},
+	}, []*Gateway{{Name: "primary"}, {Name: "backup"}, {Name: "vip"}, {Name: "visa"}, {Name: "visa-usd"}})
+
+	tests := []struct {
+		name         string
+		payment      Payment
+		wantPrimary  string
+		wantFailover string
+	}{
+		{
+			name:         "first matching rule wins",
+			payment:      Payment{PAN: "4111111111111111", MerchantID: "M-VIP", Currency: "840", Operation: "authorization"},
+			wantPrimary:  "vip",
+			wantFailover: "backup",
+		},
+		{
+			name:        "every condition of a rule must hold",
+			payment:     Payment{PAN: "4111111111111111", MerchantID: "M1", Currency: "840", Operation: "authorization"},
+			wantPrimary: "visa-usd",
+		},
+		{
+			name:         "next rule when a condition fails",
+			payment:      Payment{PAN: "4111111111111111", MerchantID: "M1", Currency: "986", Operation: "authorization"},
+			wantPrimary:  "visa",
+			wantFailover: "visa-usd",
+		},
+		{
+			name:        "pre-authorizations do not fail over",
+			payment:     Payment{PAN: "4111111111111111", MerchantID: "M1", Currency: "986", Operation: "pre_authorization"},
+			wantPrimary: "visa",
+		},
+		{
+			name:         "BIN outside every range takes the default route",
+			payment:      Payment{PAN: "5111111111111111", MerchantID: "M1", Currency: "986", Operation: "authorization"},
+			wantPrimary:  "primary",
+			wantFailover: "backup",
+		},
+		{
+			name:        "follow-ups do not fail over",
+			payment:     Payment{PAN: "4111111111111111", MerchantID: "M-VIP", Operation: "cancellation"},
+			wantPrimary: "vip",
+		},
+		{
+			name:         "card number shorter than the range",
+			payment:      Payment{PAN: "41111", MerchantID: "M1", Currency: "986", Operation: "authorization"},
+			wantPrimary:  "primary",
+			wantFailover: "backup",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, failover := router.Route(tt.payment)
+
+			if primary == nil || primary.Name != tt.wantPrimary {
+				t.Fatalf("primary = %v, want %s", primary, tt.wantPrimary)
+			}
+
+			failoverName := ""
+			if failover != nil {
+				failoverName = failover.Name
+			}
+			if failoverName != tt.wantFailover {
+				t.Fatalf("failover = %q, want %q", failoverName, tt.wantFailover)
+			}
+		})
+	}
+}
+
+func TestRouterGateway(t *testing.T) {
+	router := NewRouter(configs.RoutingConfig{Default: "backup"}, []*Gateway{{Name: "primary"}, {Name: "backup"}})
+
+	// Transactions recorded before routing existed have no gateway.
+	if gateway := router.Gateway(""); gateway == nil || gateway.Name != "backup" {
+		t.Fatalf("gateway of an unnamed transaction = %v, want the default one", gateway)
+	}
+	if gateway := router.Gateway("removed"); gateway != nil {
+		t.Fatalf("gateway no longer configured = %v, want nil", gateway)
+	}
+}
+
+func TestPANFromTrack2(t *testing.T) {
+	for track2, want := range map[string]string{
+		";4111111111111111=25121010000012300000?": "4111111111111111",
+		"4111111111111111D2512101":                "4111111111111111",
+		"4111111111111111":                        "4111111111111111",
+	} {
+		if got := PANFromTrack2(track2); got != want {
+			t.Errorf("PANFromTrack2(%q) = %q, want %q", track2, got, want)
+		}
+	}
+}
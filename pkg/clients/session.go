@@ -0,0 +1,148 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+var (
+	ErrNotSignedOn       = errors.New("gateway session is not signed on")
+	ErrNetworkMgmtFailed = errors.New("gateway declined network management message")
+)
+
+type (
+	// Session keeps the acquirer session signed on: it signs on (0800/001) when
+	// started, sends an echo test (0800/301) every interval while signed on and
+	// signs on again as soon as one fails.
+	Session struct {
+		name     string
+		client   GatewayClient
+		interval time.Duration
+
+		signedOn atomic.Bool
+		lastErr  atomic.Pointer[error]
+
+		stop     chan struct{}
+		done     chan struct{}
+		stopOnce sync.Once
+	}
+)
+
+// NewSession creates a session over client, the client of the gateway name; call
+// Start to sign on.
+func NewSession(name string, client GatewayClient, interval time.Duration) *Session {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	return &Session{
+		name:     name,
+		client:   client,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start signs on in the background and keeps the session alive until Close.
+func (s *Session) Start() {
+	s.lastErr.Store(&ErrNotSignedOn)
+	go s.run()
+}
+
+// SignedOn reports whether the last sign-on or echo test succeeded.
+func (s *Session) SignedOn() bool {
+	return s.signedOn.Load()
+}
+
+// Err returns why the session is not signed on, nil when it is.
+func (s *Session) Err() error {
+	if err := s.lastErr.Load(); err != nil {
+		return *err
+	}
+
+	return nil
+}
+
+// Close stops the echo tests and signs off when the session is signed on.
+func (s *Session) Close(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+
+	if !s.signedOn.Swap(false) {
+		return nil
+	}
+
+	err := s.exchange(ctx, NetworkSignOff)
+	if err == nil {
+		logger.Component(ctx, logger.ComponentGateway).WithField("gateway", s.name).Info("gateway session signed off")
+	}
+
+	return err
+}
+
+func (s *Session) run() {
+	defer close(s.done)
+
+	for {
+		code := NetworkSignOn
+		if s.signedOn.Load() {
+			code = NetworkEchoTest
+		}
+
+		s.update(code, s.exchange(context.Background(), code))
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// update records the outcome of a network management exchange and logs the
+// session state transitions.
+func (s *Session) update(code string, err error) {
+	entry := logger.Component(context.Background(), logger.ComponentGateway).WithField("gateway", s.name).WithField("network_code", code)
+
+	if err != nil {
+		s.lastErr.Store(&err)
+		if s.signedOn.Swap(false) {
+			entry.WithError(err).Error("gateway echo test failed, signing on again")
+		} else {
+			entry.WithError(err).Warn("gateway sign-on failed")
+		}
+		return
+	}
+
+	s.lastErr.Store(nil)
+	if !s.signedOn.Swap(true) {
+		entry.Info("gateway session signed on")
+	}
+}
+
+func (s *Session) exchange(ctx context.Context, code string) error {
+	now := time.Now().UTC()
+
+	msg := NewMessage("0800").
+		Set(FieldTransmissionDateTime, now.Format("0102150405")).
+		Set(FieldSTAN, NextSTAN()).
+		Set(FieldNetworkCode, code)
+
+	resp, err := s.client.Send(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	if resp.MTI != "0810" || resp.Get(FieldResponseCode) != "00" {
+		return fmt.Errorf("%w: mti %s, response code %q", ErrNetworkMgmtFailed, resp.MTI, resp.Get(FieldResponseCode))
+	}
+
+	return nil
+}
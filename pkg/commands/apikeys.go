@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+var errMissingAction = errors.New("missing action, use one of create, rotate, list or revoke")
+
+// runAPIKeys manages merchant API keys directly in the configured key store.
+func runAPIKeys(opts configs.Options, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errMissingAction
+	}
+
+	action := args[0]
+	flags := flag.NewFlagSet("apikeys "+action, flag.ContinueOnError)
+	merchantID := flags.String("merchant", "", "merchant ID")
+	keyID := flags.String("id", "", "API key ID")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfgs, err := configs.NewConfigs(opts)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repositories.NewFileAPIKeyRepository(cfgs.APIKeys.File)
+	if err != nil {
+		return err
+	}
+
+	service := services.NewAPIKeyService(repo, cfgs.APIKeys.Pepper.Value())
+	ctx := context.Background()
+
+	var result any
+	switch action {
+	case "create", "rotate":
+		if *merchantID == "" {
+			return errors.New("-merchant is required")
+		}
+		result, err = service.Issue(ctx, &models.CreateAPIKeyRequest{MerchantID: *merchantID, Rotate: action == "rotate"})
+	case "list":
+		if *merchantID == "" {
+			return errors.New("-merchant is required")
+		}
+		result, err = service.List(ctx, *merchantID)
+	case "revoke":
+		if *keyID == "" {
+			return errors.New("-id is required")
+		}
+		err = service.Revoke(ctx, *keyID)
+	default:
+		return errMissingAction
+	}
+
+	if err != nil || result == nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
@@ -0,0 +1,49 @@
+// Package commands implements the command-line subcommands of the server binary.
+//
+// Running the binary without arguments starts the HTTP server; any argument is
+// treated as a subcommand dispatched by Run.
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+// command runs a subcommand with its arguments and the configuration options of
+// the global flags.
+type command func(opts configs.Options, args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"apikeys": runAPIKeys,
+	"config":  runConfig,
+}
+
+// Run executes the subcommand named by args[0] and returns the process exit code.
+func Run(opts configs.Options, args []string) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		usage(os.Stderr)
+		return 2
+	}
+
+	if err := cmd(opts, args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: [-config path] [-env environment] [-set key=value]... [command]")
+	fmt.Fprintln(w, "commands:")
+	fmt.Fprintln(w, "  apikeys create|rotate -merchant <id>")
+	fmt.Fprintln(w, "  apikeys list -merchant <id>")
+	fmt.Fprintln(w, "  apikeys revoke -id <key id>")
+	fmt.Fprintln(w, "  config validate [properties file...]")
+	fmt.Fprintln(w, "  config print")
+}
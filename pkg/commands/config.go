@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+var errMissingConfigAction = errors.New("missing action, use validate or print")
+
+// runConfig inspects properties files without starting the server.
+func runConfig(opts configs.Options, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errMissingConfigAction
+	}
+
+	switch args[0] {
+	case "validate":
+		return validateConfigs(args[1:], stdout)
+	case "print":
+		return printConfig(opts, stdout)
+	default:
+		return errMissingConfigAction
+	}
+}
+
+// validateConfigs validates the given properties files, every properties.*.json
+// of the working directory when none is given, and reports all the problems of
+// each file. It fails when any file is invalid so it can gate CI.
+func validateConfigs(files []string, stdout io.Writer) error {
+	if len(files) == 0 {
+		matches, err := filepath.Glob("properties.*.json")
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return errors.New("no properties.*.json file found")
+		}
+		files = matches
+	}
+
+	invalid := 0
+	for _, file := range files {
+		if _, err := configs.LoadFile(file); err != nil {
+			invalid++
+			fmt.Fprintf(stdout, "%s: invalid\n", file)
+			for _, problem := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintf(stdout, "  - %s\n", problem)
+			}
+			continue
+		}
+
+		fmt.Fprintf(stdout, "%s: ok\n", file)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d files invalid", invalid, len(files))
+	}
+
+	return nil
+}
+
+// printConfig writes the effective value of every setting, secrets redacted, and
+// the layer it comes from.
+func printConfig(opts configs.Options, stdout io.Writer) error {
+	settings, err := configs.Sources(opts)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, setting := range settings {
+		fmt.Fprintf(w, "%s\t%v\t%s\n", setting.Key, setting.Value, setting.Source)
+	}
+
+	return w.Flush()
+}
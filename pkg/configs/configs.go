@@ -1,13 +1,12 @@
 package configs
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
-	"slices"
-	"strings"
+	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
@@ -16,17 +15,237 @@ type (
 	// It includes settings for the environment, application name, logging level,
 	// timezone, and network configurations such as host and port.
 	EnvVars struct {
-		Env                string `mapstructure:"environment"`     // The environment in which the application is running (e.g., "local", "dev").
-		AppName            string `mapstructure:"appName"`         // The name of the application.
-		LogLevel           string `mapstructure:"logLevel"`        // The logging level (e.g., "info", "debug").
-		UseLogLevelHook    bool   `mapstructure:"logLevelHook"`    // Indicates whether to use the log level hook.
-		UseTimezoneLogHook bool   `mapstructure:"logTimezoneHook"` // Indicates whether to use the timezone log hook.
-		Timezone           string `mapstructure:"timezone"`        // The timezone to be used for logging.
-
-		Host        string `mapstructure:"host"`        // The host address for the application.
-		Port        int    `mapstructure:"port"`        // The port number for the application.
-		GatewayHost string `mapstructure:"gatewayHost"` // The host address for the gateway.
-		GatewayPort int    `mapstructure:"gatewayPort"` // The port number for the gateway.
+		Env                string        `mapstructure:"environment" validate:"environment"` // The environment in which the application is running (e.g., "local", "dev").
+		AppName            string        `mapstructure:"appName" validate:"required"`        // The name of the application.
+		LogLevel           string        `mapstructure:"logLevel" validate:"loglevel"`       // The logging level (e.g., "info", "debug").
+		UseLogLevelHook    bool          `mapstructure:"logLevelHook"`                       // Indicates whether to use the log level hook.
+		UseTimezoneLogHook bool          `mapstructure:"logTimezoneHook"`                    // Indicates whether to use the timezone log hook.
+		Timezone           string        `mapstructure:"timezone" validate:"timezone"`       // The timezone to be used for logging.
+		LogHook            LogHookConfig `mapstructure:"logHook"`                            // Sink receiving the entries filtered by the log level hook.
+		LogFile            LogFileConfig `mapstructure:"logFile"`                            // Rotating file the logs are written to.
+
+		Host        string            `mapstructure:"host" validate:"host"`            // The host address for the application.
+		Port        int               `mapstructure:"port" validate:"min=1,max=65535"` // The port number for the application.
+		Server      ServerConfig      `mapstructure:"server"`                          // Timeouts and limits of the HTTP listeners.
+		AdminServer AdminServerConfig `mapstructure:"adminServer"`                     // Listener of the health, metrics, pprof and admin routes.
+		TLS         TLSConfig         `mapstructure:"tls"`                             // TLS settings of the HTTP listener.
+
+		Gateways            []GatewayConfig `mapstructure:"gateways" validate:"required,min=1,dive"`  // Acquirer gateways the payments are routed to.
+		Routing             RoutingConfig   `mapstructure:"routing"`                                  // Selection of the gateway of each payment.
+		GatewayTimeout      time.Duration   `mapstructure:"gatewayTimeout" validate:"gt=0"`           // Maximum time to wait for a gateway response.
+		GatewayPoolSize     int             `mapstructure:"gatewayPoolSize" validate:"min=1,max=256"` // Maximum number of open connections to each gateway.
+		GatewayEchoInterval time.Duration   `mapstructure:"gatewayEchoInterval" validate:"gt=0"`      // Interval between echo tests, and sign-on retries, of the gateway sessions.
+
+		GatewayCircuitBreaker CircuitBreakerConfig `mapstructure:"gatewayCircuitBreaker"` // Fast-fail settings used when a gateway is down, applied to each gateway.
+
+		Transactions TransactionsConfig `mapstructure:"transactions"` // Transaction store and reversal of unresolved transactions.
+		Settlement   SettlementConfig   `mapstructure:"settlement"`   // Batch store and scheduled batch closes.
+
+		AdminToken     Secret               `mapstructure:"adminToken"`     // Bearer token required by the /admin routes.
+		APIKeys        APIKeysConfig        `mapstructure:"apiKeys"`        // Merchant API key storage settings.
+		RequestSigning RequestSigningConfig `mapstructure:"requestSigning"` // HMAC request signing settings.
+		RateLimit      RateLimitConfig      `mapstructure:"rateLimit"`      // Payment route rate limits.
+
+		Tracing   TracingConfig   `mapstructure:"tracing"`   // OpenTelemetry tracing settings.
+		AccessLog AccessLogConfig `mapstructure:"accessLog"` // HTTP access log settings.
+	}
+
+	// LogHookConfig selects where the log level hook forwards entries to.
+	LogHookConfig struct {
+		Level         string `mapstructure:"level" validate:"loglevel"`                                        // Least severe level forwarded.
+		Sink          string `mapstructure:"sink" validate:"oneof=file syslog otlp"`                           // "file", "syslog" or "otlp".
+		File          string `mapstructure:"file" validate:"required_if=Sink file"`                            // Output path of the file sink.
+		SyslogNetwork string `mapstructure:"syslogNetwork"`                                                    // "udp", "tcp", "unix"..., empty for the local daemon.
+		SyslogAddress string `mapstructure:"syslogAddress"`                                                    // Address of the syslog daemon, empty for the local one.
+		OTLPEndpoint  string `mapstructure:"otlpEndpoint" validate:"required_if=Sink otlp,omitempty,http_url"` // OTLP/HTTP logs URL, e.g. http://collector:4318/v1/logs.
+	}
+
+	// LogFileConfig configures writing the logs to a rotating file.
+	LogFileConfig struct {
+		Enabled     bool          `mapstructure:"enabled"`                                  // Write the logs to Path.
+		Path        string        `mapstructure:"path" validate:"required_if=Enabled true"` // Path of the current log file.
+		Console     bool          `mapstructure:"console"`                                  // Keep writing to stderr as well.
+		MaxSizeMB   int           `mapstructure:"maxSizeMB" validate:"gte=0"`               // Size that triggers a rotation, 0 for 100MB.
+		RotateEvery time.Duration `mapstructure:"rotateEvery" validate:"gte=0"`             // Interval between time based rotations, 0 to disable.
+		MaxAgeDays  int           `mapstructure:"maxAgeDays" validate:"gte=0"`              // Age after which rotated files are removed, 0 to keep them.
+		MaxBackups  int           `mapstructure:"maxBackups" validate:"gte=0"`              // Number of rotated files kept, 0 to keep them all.
+		Compress    bool          `mapstructure:"compress"`                                 // Gzip rotated files.
+	}
+
+	// ServerConfig holds the timeouts, limits and trusted proxies of the payment
+	// listener. The admin listener shares the timeouts, except for its write timeout.
+	ServerConfig struct {
+		ReadTimeout       time.Duration `mapstructure:"readTimeout" validate:"gt=0"`         // Maximum time to read a whole request.
+		ReadHeaderTimeout time.Duration `mapstructure:"readHeaderTimeout" validate:"gt=0"`   // Maximum time to read the request headers.
+		WriteTimeout      time.Duration `mapstructure:"writeTimeout" validate:"gt=0"`        // Maximum time to answer; must exceed gatewayTimeout.
+		IdleTimeout       time.Duration `mapstructure:"idleTimeout" validate:"gt=0"`         // Keep-alive time of idle connections.
+		DrainDelay        time.Duration `mapstructure:"drainDelay" validate:"gte=0"`         // Time readiness reports down before the listener stops accepting requests.
+		ShutdownTimeout   time.Duration `mapstructure:"shutdownTimeout" validate:"gt=0"`     // Time given to in-flight requests and gateway exchanges on shutdown.
+		MaxHeaderBytes    int           `mapstructure:"maxHeaderBytes" validate:"gte=0"`     // Maximum size of the request headers.
+		MaxBodyBytes      int64         `mapstructure:"maxBodyBytes" validate:"gte=0"`       // Maximum size of a request body, 0 for no limit.
+		MaxInFlight       int           `mapstructure:"maxInFlight" validate:"gte=0"`        // Concurrent requests served before shedding with 503, 0 for no limit.
+		TrustedProxies    []string      `mapstructure:"trustedProxies" validate:"dive,cidr"` // CIDRs of the proxies whose X-Forwarded-For and X-Real-IP are honoured.
+	}
+
+	// TransactionsConfig holds the transaction store settings.
+	TransactionsConfig struct {
+		File             string              `mapstructure:"file" validate:"required"`         // JSON lines file the transactions are appended to.
+		ReversalInterval time.Duration       `mapstructure:"reversalInterval" validate:"gt=0"` // Interval between attempts to reverse the unresolved transactions.
+		PreAuthExpiry    PreAuthExpiryConfig `mapstructure:"preAuthExpiry"`                    // Expiry of the pre-authorizations left uncaptured.
+	}
+
+	// PreAuthExpiryConfig sets how long an approved pre-authorization holds its
+	// amount before it expires, counted from its last approved authorization.
+	PreAuthExpiryConfig struct {
+		Interval time.Duration `mapstructure:"interval" validate:"gt=0"` // Interval between checks for expired pre-authorizations.
+		Window   time.Duration `mapstructure:"window" validate:"gt=0"`   // Window of the merchant categories without their own.
+		Windows  []MCCWindow   `mapstructure:"windows" validate:"dive"`  // Windows of specific merchant categories, e.g. hotels.
+		Release  bool          `mapstructure:"release"`                  // Reverse expired pre-authorizations on their gateway to release the held amount.
+	}
+
+	// MCCWindow is the expiry window of the pre-authorizations of some merchant categories.
+	MCCWindow struct {
+		MCCs   []string      `mapstructure:"mccs" validate:"required,min=1,dive,len=4,numeric"` // Merchant category codes, e.g. "7011".
+		Window time.Duration `mapstructure:"window" validate:"gt=0"`
+	}
+
+	// SettlementConfig holds the batch store and the times of day the batches of
+	// every terminal are closed.
+	SettlementConfig struct {
+		File    string   `mapstructure:"file" validate:"required"`               // JSON lines file the batches are appended to.
+		CloseAt []string `mapstructure:"closeAt" validate:"dive,datetime=15:04"` // Times of day, HH:MM in timezone; none to close through the API only.
+	}
+
+	// AdminServerConfig is the address of the admin listener, kept apart from the
+	// payment port so the operational routes are never exposed with it.
+	AdminServerConfig struct {
+		Host         string        `mapstructure:"host" validate:"host"`
+		Port         int           `mapstructure:"port" validate:"min=1,max=65535"`
+		WriteTimeout time.Duration `mapstructure:"writeTimeout" validate:"gt=0"` // Long enough for the CPU profiles of /debug/pprof.
+	}
+
+	// TLSConfig holds the HTTP listener certificate and the optional client
+	// certificate verification used for mutual TLS.
+	TLSConfig struct {
+		Enabled             bool                 `mapstructure:"enabled"`                                                    // Serve HTTPS instead of plaintext HTTP.
+		CertFile            string               `mapstructure:"certFile" validate:"required_if=Enabled true"`               // PEM certificate chain of the listener.
+		KeyFile             string               `mapstructure:"keyFile" validate:"required_if=Enabled true"`                // PEM private key of the listener.
+		ClientCAFile        string               `mapstructure:"clientCAFile"`                                               // PEM bundle used to verify client certificates.
+		ClientAuth          string               `mapstructure:"clientAuth" validate:"omitempty,oneof=none request require"` // "none", "request" or "require".
+		ClientCertMerchants []ClientCertMerchant `mapstructure:"clientCertMerchants" validate:"dive"`                        // Client certificates accepted in place of an API key.
+	}
+
+	// ClientCertMerchant binds the subject common name of a verified client certificate to a merchant.
+	ClientCertMerchant struct {
+		Subject    string `mapstructure:"subject" validate:"required"`
+		MerchantID string `mapstructure:"merchantId" validate:"required"`
+	}
+
+	// GatewayConfig is an acquirer gateway, referenced by its name in the routing rules
+	// and in the transactions it handled.
+	GatewayConfig struct {
+		Name string           `mapstructure:"name" validate:"required"`
+		Host string           `mapstructure:"host" validate:"host"`
+		Port int              `mapstructure:"port" validate:"min=1,max=65535"`
+		TLS  GatewayTLSConfig `mapstructure:"tls"` // TLS settings of the connection to this gateway.
+	}
+
+	// RoutingConfig selects the gateway of the authorizations and pre-authorizations,
+	// and of the follow-up operations sent without the original transaction. Rules
+	// are evaluated in order and the first one matching the payment applies; the
+	// default gateway handles the payments matching no rule.
+	RoutingConfig struct {
+		Default  string        `mapstructure:"default"`               // Gateway of the payments matching no rule, the first gateway when empty.
+		Failover string        `mapstructure:"failover"`              // Secondary gateway of the authorizations matching no rule, none when empty.
+		Rules    []RoutingRule `mapstructure:"rules" validate:"dive"` // Routing rules, first match wins.
+	}
+
+	// RoutingRule matches a payment when every non empty condition holds.
+	RoutingRule struct {
+		BINRanges  []BINRange `mapstructure:"binRanges" validate:"dive"`                                                                                                            // Ranges one of which holds the card BIN.
+		Merchants  []string   `mapstructure:"merchants"`                                                                                                                            // Merchant IDs.
+		Currencies []string   `mapstructure:"currencies" validate:"dive,len=3,numeric"`                                                                                             // ISO 4217 numeric currency codes.
+		Operations []string   `mapstructure:"operations" validate:"dive,oneof=authorization pre_authorization incremental_authorization confirmation cancellation reversal refund"` // Operations, e.g. "authorization".
+		Gateway    string     `mapstructure:"gateway" validate:"required"`                                                                                                          // Gateway of the matching payments.
+		Failover   string     `mapstructure:"failover"`                                                                                                                             // Gateway the matching authorizations fail over to, none when empty.
+	}
+
+	// BINRange holds the card numbers whose leading digits, as many as in From and
+	// To, are between From and To inclusive.
+	BINRange struct {
+		From string `mapstructure:"from" validate:"required,numeric,min=6,max=11"`
+		To   string `mapstructure:"to" validate:"required,numeric,min=6,max=11"`
+	}
+
+	// GatewayTLSConfig holds the TLS settings used to dial a gateway.
+	GatewayTLSConfig struct {
+		Enabled      bool     `mapstructure:"enabled"`                                          // Dial the gateway over TLS.
+		CAFile       string   `mapstructure:"caFile"`                                           // PEM bundle used to verify the gateway, system roots when empty.
+		CertFile     string   `mapstructure:"certFile" validate:"required_with=KeyFile"`        // PEM client certificate presented to the gateway.
+		KeyFile      string   `mapstructure:"keyFile" validate:"required_with=CertFile"`        // PEM private key of the client certificate.
+		ServerName   string   `mapstructure:"serverName" validate:"omitempty,hostname_rfc1123"` // Expected gateway certificate name, the gateway host when empty.
+		PinnedSHA256 []string `mapstructure:"pinnedSHA256" validate:"dive,base64,len=44"`       // Base64 SHA-256 of accepted gateway public keys.
+	}
+
+	// RateLimitConfig holds the token bucket limits applied to the payment routes,
+	// keyed by merchant, terminal and client IP.
+	RateLimitConfig struct {
+		Enabled           bool                `mapstructure:"enabled"`
+		Merchant          RateLimit           `mapstructure:"merchant"`                          // Limit shared by all terminals of a merchant.
+		Terminal          RateLimit           `mapstructure:"terminal"`                          // Limit of a single terminal.
+		IP                RateLimit           `mapstructure:"ip"`                                // Limit of a single client IP.
+		MerchantOverrides []MerchantRateLimit `mapstructure:"merchantOverrides" validate:"dive"` // Per merchant replacements of the merchant and terminal limits.
+	}
+
+	// RateLimit is a token bucket refilled with Rate tokens per second up to Burst. A zero Rate disables it.
+	RateLimit struct {
+		Rate  float64 `mapstructure:"rate" validate:"gte=0"`
+		Burst int     `mapstructure:"burst" validate:"gte=0"`
+	}
+
+	// MerchantRateLimit overrides the limits of one merchant. Zero limits keep the defaults.
+	MerchantRateLimit struct {
+		MerchantID string    `mapstructure:"merchantId" validate:"required"`
+		Merchant   RateLimit `mapstructure:"merchant"`
+		Terminal   RateLimit `mapstructure:"terminal"`
+	}
+
+	// CircuitBreakerConfig holds the thresholds of the gateway circuit breaker.
+	CircuitBreakerConfig struct {
+		Enabled        bool          `mapstructure:"enabled"`
+		FailureRatio   float64       `mapstructure:"failureRatio" validate:"gt=0,lte=1"` // Ratio of failed or timed out exchanges that opens the circuit.
+		MinRequests    int           `mapstructure:"minRequests" validate:"min=1"`       // Exchanges needed in the window before the ratio is evaluated.
+		Window         time.Duration `mapstructure:"window" validate:"gt=0"`             // Length of the window over which failures are counted.
+		OpenTimeout    time.Duration `mapstructure:"openTimeout" validate:"gt=0"`        // Time the circuit stays open before probing the gateway.
+		HalfOpenProbes int           `mapstructure:"halfOpenProbes" validate:"min=1"`    // Successful probes needed to close the circuit again.
+	}
+
+	// TracingConfig selects where spans are exported and which attributes are redacted.
+	TracingConfig struct {
+		Enabled            bool              `mapstructure:"enabled"`
+		Exporter           string            `mapstructure:"exporter" validate:"oneof=otlp stdout file"`                       // "otlp", "stdout" or "file".
+		Endpoint           string            `mapstructure:"endpoint" validate:"required_if=Exporter otlp,omitempty,http_url"` // OTLP/HTTP traces URL, e.g. http://collector:4318/v1/traces.
+		Headers            map[string]Secret `mapstructure:"headers"`                                                          // Extra headers sent to the OTLP endpoint.
+		File               string            `mapstructure:"file" validate:"required_if=Exporter file"`                        // Output path of the file exporter.
+		SampleRatio        float64           `mapstructure:"sampleRatio" validate:"gte=0,lte=1"`                               // Ratio of new traces sampled, parents' decisions are kept.
+		RedactedAttributes []string          `mapstructure:"redactedAttributes"`                                               // Attribute keys whose values are never exported.
+	}
+
+	// AccessLogConfig controls the per request access log entries.
+	AccessLogConfig struct {
+		SuccessSampleRate float64 `mapstructure:"successSampleRate" validate:"gte=0,lte=1"` // Ratio of 1xx-2xx requests logged, errors are always logged.
+	}
+
+	// APIKeysConfig holds the settings used to store and verify merchant API keys.
+	APIKeysConfig struct {
+		File   string `mapstructure:"file" validate:"required"` // Path of the JSON file holding the hashed keys.
+		Pepper Secret `mapstructure:"pepper"`                   // Server-side secret mixed into every key hash and signing key.
+	}
+
+	// RequestSigningConfig controls the HMAC signature verification of requests.
+	RequestSigningConfig struct {
+		Mode      string        `mapstructure:"mode" validate:"oneof=disabled optional required"` // "disabled", "optional" or "required".
+		ClockSkew time.Duration `mapstructure:"clockSkew" validate:"gt=0"`                        // Maximum accepted distance between the signature timestamp and now.
 	}
 )
 
@@ -49,64 +268,92 @@ var (
 		return fmt.Errorf("'%s' is not a valid environment. Use one of %+v", env, allowedEnvironments)
 	}
 
-	Viper  *viper.Viper
-	Config *EnvVars
+	Viper *viper.Viper
 )
 
-// NewConfigs initializes a new EnvVars instance by reading configuration values
-// from environment variables and configuration files.
+// NewConfigs initializes a new EnvVars instance from the layers selected by opts:
+// the properties files, the environment variables and the command-line overrides.
 //
 // It returns a pointer to the EnvVars instance and an error if any occurs during
-// the configuration loading process.
-func NewConfigs() (*EnvVars, error) {
-	env := os.Getenv("ENVIRONMENT")
+// the configuration loading process, including every setting failing validation.
+func NewConfigs(opts Options) (*EnvVars, error) {
+	instance, layerFiles, err := load(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %w", err)
+	}
 
-	instance := viper.New()
+	var envVars EnvVars
+	if err := instance.Unmarshal(&envVars, decodeSecrets(true)); err != nil {
+		return nil, decodeError(err)
+	}
 
-	exePath, _ := os.Executable()
-	rootDir := filepath.Dir(exePath)
+	if err := Validate(&envVars); err != nil {
+		return nil, err
+	}
 
-	instance.SetEnvKeyReplacer(strings.NewReplacer(`.`, `_`))
-	instance.SetConfigName(mapEnvToPropertiesFilename(env))
-	instance.AddConfigPath(rootDir)
+	options, files = opts, layerFiles
+	Viper = instance
+	swap(&envVars, instance.AllSettings())
 
-	err := instance.ReadInConfig()
-	if err != nil {
-		logrus.WithError(err).Fatal("Failed to read configuration")
-	}
+	return &envVars, nil
+}
 
-	instance.AutomaticEnv()
+// LoadFile reads and validates a single properties file exactly as written, on
+// top of the base file properties.json of its directory when there is one:
+// environment variables are not applied, secret references are not resolved,
+// unknown settings are rejected and values must have the type of their setting,
+// e.g. ports are numbers.
+func LoadFile(path string) (*EnvVars, error) {
+	instance := viper.New()
 
-	err = validateEnvironment(instance.GetString("environment"))
-	if err != nil {
-		return nil, err
+	for i, file := range withBaseFile(filepath.Dir(path), path) {
+		instance.SetConfigFile(file)
+		instance.SetConfigType("json")
+
+		var err error
+		if i == 0 {
+			err = instance.ReadInConfig()
+		} else {
+			err = instance.MergeInConfig()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
 	}
 
 	var envVars EnvVars
-	if err := instance.Unmarshal(&envVars); err != nil {
-		return nil, err
+	err := instance.Unmarshal(&envVars, decodeSecrets(false), func(c *mapstructure.DecoderConfig) {
+		c.WeaklyTypedInput = false
+		c.ErrorUnused = true
+	})
+	if err != nil {
+		return nil, decodeError(err)
 	}
 
-	Viper = instance
-	Config = &envVars
+	return &envVars, Validate(&envVars)
+}
 
-	return &envVars, nil
+// decodeError drops the header of the decoder errors so each problem is on its own line.
+func decodeError(err error) error {
+	if inner := errors.Unwrap(err); inner != nil {
+		return inner
+	}
+
+	return err
 }
 
-// validateEnvironment checks if the provided environment string is valid
-// by comparing it against the list of allowed environments.
-//
-// Parameters:
-// - env: The environment string to validate.
-//
-// Returns:
-// An error if the environment is not valid; otherwise, it returns nil.
-func validateEnvironment(env string) error {
-	if !slices.Contains(allowedEnvironments, env) {
-		return ErrInvalidEnvironment(env)
+// ReadLogLevel re-reads the configuration layers and returns the effective
+// logLevel, so the level can be changed without a restart.
+func ReadLogLevel() (string, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	instance, _, err := load(options)
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	return instance.GetString("logLevel"), nil
 }
 
 // mapEnvToPropertiesFilename maps the provided environment string to the corresponding
@@ -116,23 +363,23 @@ func validateEnvironment(env string) error {
 // - env: The environment string (e.g., "local", "dev").
 //
 // Returns:
-// The name of the properties file associated with the given environment.
-func mapEnvToPropertiesFilename(env string) string {
+// The name of the properties file associated with the given environment, or
+// ErrInvalidEnvironment for an unknown one.
+func mapEnvToPropertiesFilename(env string) (string, error) {
 	switch env {
 	case "test":
 		fallthrough
 	case "local":
-		return "properties.local"
+		return "properties.local", nil
 	case "dev":
-		return "properties.dev"
+		return "properties.dev", nil
 	case "hml":
 		fallthrough
 	case "stg":
-		return "properties.stg"
+		return "properties.stg", nil
 	case "prd":
-		return "properties.prd"
+		return "properties.prd", nil
 	default:
-		return "properties.local"
-
+		return "", ErrInvalidEnvironment(env)
 	}
 }
@@ -0,0 +1,317 @@
+package configs
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// baseFilename is the optional file holding the settings shared by every
+	// environment, overridden by the environment file.
+	baseFilename = "properties.json"
+
+	// configPathEnv names the variable used in place of the --config flag.
+	configPathEnv = "CONFIG_PATH"
+
+	// systemConfigDir is the last directory searched for the properties files.
+	systemConfigDir = "/etc/go-simple-http-server"
+)
+
+type (
+	// Options select the properties files and the command-line overrides. The
+	// zero value loads properties.<ENVIRONMENT>.json from the search paths.
+	//
+	// Settings are layered, each layer overriding the previous ones: the base
+	// file properties.json, the environment file, the environment variables
+	// (e.g. RATELIMIT_ENABLED for rateLimit.enabled) and the -set flags.
+	Options struct {
+		Path        string            // Properties file, or directory holding it, given by -config or CONFIG_PATH.
+		Environment string            // Environment given by -env, ENVIRONMENT otherwise, "local" when both are empty.
+		Overrides   map[string]string // Settings given by -set key=value.
+	}
+
+	// Setting is a leaf setting with its effective value and the layer it comes from.
+	Setting struct {
+		Key    string
+		Value  any
+		Source string
+	}
+
+	// overridesFlag collects the repeatable -set key=value flag.
+	overridesFlag map[string]string
+)
+
+var (
+	// options and files are those of the configuration in effect, reused to
+	// reload it.
+	options Options
+	files   []string
+)
+
+func (f overridesFlag) String() string {
+	return ""
+}
+
+func (f overridesFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("%q is not in the key=value form", value)
+	}
+
+	f[strings.ToLower(key)] = val
+	return nil
+}
+
+// ParseFlags parses the global command-line flags and returns the remaining
+// arguments, which name a subcommand when there are any.
+func ParseFlags(args []string) (Options, []string, error) {
+	opts := Options{Overrides: map[string]string{}}
+
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	flags.StringVar(&opts.Path, "config", "", "properties file, or directory holding it; CONFIG_PATH when not set")
+	flags.StringVar(&opts.Environment, "env", "", "environment selecting properties.<env>.json; ENVIRONMENT when not set")
+	flags.Var(overridesFlag(opts.Overrides), "set", "override a setting, e.g. -set rateLimit.enabled=true (repeatable)")
+
+	if err := flags.Parse(args); err != nil {
+		return opts, nil, err
+	}
+
+	return opts, flags.Args(), nil
+}
+
+// Sources returns every setting, sorted by key, with its effective value and the
+// layer it comes from: a properties file, "env NAME", "flag -set" or "unset".
+// The environment comes from "flag -env", "env ENVIRONMENT" or "default".
+// Secret values are redacted.
+func Sources(opts Options) ([]Setting, error) {
+	instance, layerFiles, err := load(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := flatten(instance.AllSettings(), "")
+	sources := map[string]string{}
+
+	for _, file := range layerFiles {
+		layer := viper.New()
+		layer.SetConfigFile(file)
+		if err := layer.ReadInConfig(); err != nil {
+			return nil, err
+		}
+
+		for key := range flatten(layer.AllSettings(), "") {
+			sources[key] = file
+		}
+	}
+
+	// Environment variables only apply to the settings known from the files.
+	for key := range sources {
+		name := envName(key)
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			sources[key] = "env " + name
+		}
+	}
+
+	for key := range opts.Overrides {
+		sources[key] = "flag -set"
+	}
+
+	switch {
+	case opts.Environment != "":
+		sources["environment"] = "flag -env"
+	case os.Getenv("ENVIRONMENT") != "":
+		sources["environment"] = "env ENVIRONMENT"
+	default:
+		sources["environment"] = "default"
+	}
+
+	keys := schemaKeys(reflect.TypeFor[EnvVars](), "")
+	for key := range sources {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		source, ok := sources[key]
+		if !ok {
+			source = "unset"
+		}
+
+		settings = append(settings, Setting{Key: key, Value: redactValue(key, effective[key]), Source: source})
+	}
+
+	return settings, nil
+}
+
+// load layers the properties files, the environment variables and the overrides
+// of opts, and returns the files read.
+func load(opts Options) (*viper.Viper, []string, error) {
+	env, err := opts.environment()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layerFiles, err := opts.files(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	instance := viper.New()
+	instance.SetEnvKeyReplacer(strings.NewReplacer(`.`, `_`))
+
+	for i, file := range layerFiles {
+		instance.SetConfigFile(file)
+		instance.SetConfigType("json")
+
+		if i == 0 {
+			err = instance.ReadInConfig()
+		} else {
+			err = instance.MergeInConfig()
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	instance.AutomaticEnv()
+
+	for key, value := range opts.Overrides {
+		instance.Set(key, value)
+	}
+
+	// The environment that selected the files is authoritative, so neither the
+	// files, ENVIRONMENT through AutomaticEnv nor -set can report another one.
+	instance.Set("environment", env)
+
+	return instance, layerFiles, nil
+}
+
+// environment returns the environment selecting the properties file.
+func (o Options) environment() (string, error) {
+	env := o.Environment
+	if env == "" {
+		env = os.Getenv("ENVIRONMENT")
+	}
+	if env == "" {
+		return "local", nil
+	}
+
+	if err := validateEnvironment(env); err != nil {
+		return "", err
+	}
+
+	return env, nil
+}
+
+// files returns the properties files of env, the base file first when there is
+// one. An explicit path is used as is, or as the only directory searched; the
+// search paths are tried otherwise.
+func (o Options) files(env string) ([]string, error) {
+	name, err := mapEnvToPropertiesFilename(env)
+	if err != nil {
+		return nil, err
+	}
+	name += ".json"
+
+	path := o.Path
+	if path == "" {
+		path = os.Getenv(configPathEnv)
+	}
+
+	dirs := searchPaths()
+	if path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			return withBaseFile(filepath.Dir(path), path), nil
+		}
+
+		dirs = []string{path}
+	}
+
+	for _, dir := range dirs {
+		file := filepath.Join(dir, name)
+		if fileExists(file) {
+			return withBaseFile(dir, file), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s not found in %s", name, strings.Join(dirs, ", "))
+}
+
+// searchPaths returns the working directory, the directory of the executable
+// and systemConfigDir, without duplicates.
+func searchPaths() []string {
+	dirs := []string{"."}
+
+	if exePath, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(exePath))
+	}
+	dirs = append(dirs, systemConfigDir)
+
+	unique := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if !slices.Contains(unique, dir) {
+			unique = append(unique, dir)
+		}
+	}
+
+	return unique
+}
+
+func withBaseFile(dir, file string) []string {
+	base := filepath.Join(dir, baseFilename)
+	if filepath.Base(file) == baseFilename || !fileExists(base) {
+		return []string{file}
+	}
+
+	return []string{base, file}
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// envName returns the environment variable overriding key.
+func envName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// schemaKeys returns the lowercased paths of the leaf settings of t.
+func schemaKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+
+	for _, field := range reflect.VisibleFields(t) {
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" {
+			continue
+		}
+
+		key := prefix + strings.ToLower(name)
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, schemaKeys(field.Type, key+".")...)
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -0,0 +1,72 @@
+package configs
+
+import (
+	"reflect"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys lists the (lowercased, as viper stores them) keys of the Secret
+// settings, whose values must never be logged.
+var secretKeys = secretPaths(reflect.TypeFor[EnvVars](), "")
+
+// RedactedSettings returns every setting in effect, with the values of secret keys
+// replaced by a placeholder, so the effective configuration can be logged at startup.
+func RedactedSettings() map[string]any {
+	settings := applied.Load()
+	if settings == nil {
+		return map[string]any{}
+	}
+
+	return redactSettings(*settings, "")
+}
+
+func redactSettings(settings map[string]any, prefix string) map[string]any {
+	out := make(map[string]any, len(settings))
+
+	for key, value := range settings {
+		path := prefix + key
+
+		switch {
+		case isSecretKey(path) && !isSecretReference(value):
+			out[key] = redacted
+		case isMap(value):
+			out[key] = redactSettings(value.(map[string]any), path+".")
+		default:
+			out[key] = value
+		}
+	}
+
+	return out
+}
+
+// redactValue returns the placeholder in place of value when path is, or is below, a secret key.
+func redactValue(path string, value any) any {
+	if isSecretReference(value) {
+		return value
+	}
+
+	for _, key := range secretKeys {
+		if strings.EqualFold(path, key) || strings.HasPrefix(strings.ToLower(path), key+".") {
+			return redacted
+		}
+	}
+
+	return value
+}
+
+func isSecretKey(path string) bool {
+	for _, key := range secretKeys {
+		if strings.EqualFold(path, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isMap(value any) bool {
+	_, ok := value.(map[string]any)
+	return ok
+}
@@ -0,0 +1,221 @@
+package configs
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type (
+	// Change describes a reload applied to the configuration. Keys are the changed
+	// settings, lowercased as viper stores them, e.g. "ratelimit.merchant.rate".
+	Change struct {
+		Previous *EnvVars
+		Current  *EnvVars
+		Keys     []string
+	}
+
+	// Subscriber is notified of every applied reload.
+	Subscriber func(Change)
+)
+
+// reloadableKeys lists the top level settings applied at runtime. Any other
+// setting, such as the listeners, TLS or the stores, keeps the value read at
+// startup until the server restarts.
+var reloadableKeys = []string{
+	"loglevel",
+	"admintoken",
+	"gatewaytimeout",
+	"gatewaypoolsize",
+	"ratelimit",
+	"accesslog",
+	"requestsigning",
+}
+
+var (
+	current atomic.Pointer[EnvVars]
+	// applied holds the settings in effect, which differ from the ones of Viper
+	// after a rejected reload or a change to a setting requiring a restart.
+	applied atomic.Pointer[map[string]any]
+
+	reloadMu    sync.Mutex
+	subscribers []Subscriber
+)
+
+// Changed reports whether key, or any setting below it, changed. key is matched
+// case insensitively, e.g. "rateLimit" matches "ratelimit.merchant.rate".
+func (c Change) Changed(key string) bool {
+	key = strings.ToLower(key)
+
+	return slices.ContainsFunc(c.Keys, func(changed string) bool {
+		return changed == key || strings.HasPrefix(changed, key+".")
+	})
+}
+
+// Current returns the configuration in effect, including the reloaded settings.
+func Current() *EnvVars {
+	return current.Load()
+}
+
+// Subscribe registers fn to be notified, in registration order, after each reload
+// of the configuration is applied.
+func Subscribe(fn Subscriber) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	subscribers = append(subscribers, fn)
+}
+
+// Watch reloads the configuration whenever one of its properties files, or a file
+// referenced by a secret, changes. A reload is rejected as a whole, keeping the
+// current configuration, when the new settings are invalid.
+func Watch() {
+	watchSecretFiles(current.Load())
+
+	for _, file := range files {
+		watcher := viper.New()
+		watcher.SetConfigFile(file)
+		watcher.OnConfigChange(func(fsnotify.Event) { reload() })
+		watcher.WatchConfig()
+	}
+}
+
+// swap makes envs the configuration in effect, without notifying subscribers.
+func swap(envs *EnvVars, settings map[string]any) {
+	current.Store(envs)
+	applied.Store(&settings)
+}
+
+// reload loads the configuration layers again and applies their reloadable settings.
+func reload() {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	instance, _, err := load(options)
+	if err != nil {
+		logrus.WithError(err).Error("configuration reload rejected, keeping the current configuration")
+		return
+	}
+
+	previous := current.Load()
+	previousSettings := *applied.Load()
+	settings := instance.AllSettings()
+
+	var next EnvVars
+	if err := instance.Unmarshal(&next, decodeSecrets(true)); err != nil {
+		logrus.WithError(decodeError(err)).Error("configuration reload rejected, keeping the current configuration")
+		return
+	}
+
+	rotated := rotatedSecrets(previous, &next)
+	keepStructural(&next, previous)
+
+	if err := Validate(&next); err != nil {
+		logrus.WithError(err).Error("configuration reload rejected, keeping the current configuration")
+		return
+	}
+
+	before, after := flatten(previousSettings, ""), flatten(settings, "")
+
+	var keys, restartKeys []string
+	diff := logrus.Fields{}
+	for _, key := range changedKeys(before, after, rotated) {
+		top, _, _ := strings.Cut(key, ".")
+		if !slices.Contains(reloadableKeys, top) {
+			restartKeys = append(restartKeys, key)
+			continue
+		}
+
+		keys = append(keys, key)
+		if slices.Contains(rotated, key) {
+			diff[key] = "secret rotated"
+		} else {
+			diff[key] = fmt.Sprintf("%v -> %v", redactValue(key, before[key]), redactValue(key, after[key]))
+		}
+	}
+
+	if len(restartKeys) > 0 {
+		logrus.WithField("keys", restartKeys).Warn("configuration changes require a restart to take effect")
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	nextSettings := maps.Clone(previousSettings)
+	for _, key := range reloadableKeys {
+		if value, ok := settings[key]; ok {
+			nextSettings[key] = value
+		} else {
+			delete(nextSettings, key)
+		}
+	}
+
+	Viper = instance
+	swap(&next, nextSettings)
+
+	logrus.WithField("changes", diff).Info("configuration reloaded")
+
+	change := Change{Previous: previous, Current: &next, Keys: keys}
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
+// keepStructural copies the settings that cannot change at runtime from previous into next.
+func keepStructural(next, previous *EnvVars) {
+	nextValue, previousValue := reflect.ValueOf(next).Elem(), reflect.ValueOf(previous).Elem()
+
+	for i := range nextValue.NumField() {
+		key := strings.ToLower(nextValue.Type().Field(i).Tag.Get("mapstructure"))
+		if !slices.Contains(reloadableKeys, key) {
+			nextValue.Field(i).Set(previousValue.Field(i))
+		}
+	}
+}
+
+// flatten maps the dotted path of every leaf setting to its value.
+func flatten(settings map[string]any, prefix string) map[string]any {
+	out := map[string]any{}
+
+	for key, value := range settings {
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			maps.Copy(out, flatten(nested, prefix+key+"."))
+			continue
+		}
+
+		out[prefix+key] = value
+	}
+
+	return out
+}
+
+// changedKeys returns the settings whose value differs between before and after,
+// plus the extra keys, such as the rotated secrets.
+func changedKeys(before, after map[string]any, extra []string) []string {
+	keys := slices.Clone(extra)
+
+	for key, value := range after {
+		if previous, ok := before[key]; !ok || !reflect.DeepEqual(previous, value) {
+			keys = append(keys, key)
+		}
+	}
+
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	return slices.Compact(keys)
+}
@@ -0,0 +1,128 @@
+package configs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeProperties writes properties.local.json to dir: the one of the
+// repository with the settings of overrides, given as dotted paths.
+func writeProperties(t *testing.T, dir string, overrides map[string]any) {
+	t.Helper()
+
+	raw, err := os.ReadFile(filepath.Join("..", "..", "properties.local.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var properties map[string]any
+	if err := json.Unmarshal(raw, &properties); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, value := range overrides {
+		keys := strings.Split(path, ".")
+		node := properties
+		for _, key := range keys[:len(keys)-1] {
+			node = node[key].(map[string]any)
+		}
+		node[keys[len(keys)-1]] = value
+	}
+
+	raw, err = json.Marshal(properties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "properties.local.json"), raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadAppliesOnlyReloadableKeys(t *testing.T) {
+	// The settings changed below must come from the files.
+	for _, name := range []string{"ENVIRONMENT", "CONFIG_PATH", "PORT", "LOGLEVEL", "GATEWAYPOOLSIZE", "SERVER_MAXINFLIGHT", "RATELIMIT_MERCHANT_RATE"} {
+		t.Setenv(name, "")
+	}
+
+	var changes []Change
+	Subscribe(func(change Change) { changes = append(changes, change) })
+
+	tests := []struct {
+		name      string
+		overrides map[string]any // Settings changed in the file, by dotted path.
+		wantKeys  []string       // Changes notified, none when the reload is not applied.
+		check     func(t *testing.T, envs *EnvVars)
+	}{
+		{
+			name:      "reloadable settings",
+			overrides: map[string]any{"logLevel": "warn", "gatewayPoolSize": 8, "rateLimit.merchant.rate": 75},
+			wantKeys:  []string{"gatewaypoolsize", "loglevel", "ratelimit.merchant.rate"},
+			check: func(t *testing.T, envs *EnvVars) {
+				if envs.LogLevel != "warn" || envs.GatewayPoolSize != 8 || envs.RateLimit.Merchant.Rate != 75 {
+					t.Errorf("log level %s, pool size %d and merchant rate %v, want warn, 8 and 75", envs.LogLevel, envs.GatewayPoolSize, envs.RateLimit.Merchant.Rate)
+				}
+			},
+		},
+		{
+			name:      "settings requiring a restart",
+			overrides: map[string]any{"port": 4444, "server.maxInFlight": 8},
+		},
+		{
+			name:      "reloadable and restart settings together",
+			overrides: map[string]any{"port": 4444, "logLevel": "warn"},
+			wantKeys:  []string{"loglevel"},
+			check: func(t *testing.T, envs *EnvVars) {
+				if envs.Port != 3333 || envs.LogLevel != "warn" {
+					t.Errorf("port %d and log level %s, want 3333 and warn", envs.Port, envs.LogLevel)
+				}
+			},
+		},
+		{
+			name:      "invalid reloadable setting",
+			overrides: map[string]any{"logLevel": "loud", "gatewayPoolSize": 8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeProperties(t, dir, nil)
+
+			started, err := NewConfigs(Options{Path: dir, Environment: "local"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes = nil
+
+			writeProperties(t, dir, tt.overrides)
+			reload()
+
+			if tt.wantKeys == nil {
+				if len(changes) > 0 {
+					t.Fatalf("notified %v, want no change applied", changes[0].Keys)
+				}
+				if Current() != started {
+					t.Fatalf("configuration replaced, want %+v kept", started)
+				}
+				return
+			}
+
+			if len(changes) != 1 || !slices.Equal(changes[0].Keys, tt.wantKeys) {
+				t.Fatalf("changes = %+v, want one with keys %v", changes, tt.wantKeys)
+			}
+			if changes[0].Previous != started || changes[0].Current != Current() {
+				t.Fatal("change does not go from the started configuration to the current one")
+			}
+			tt.check(t, Current())
+
+			// Settings requiring a restart keep their started value whatever the files say.
+			if current := Current(); current.Port != started.Port || current.Server.MaxInFlight != started.Server.MaxInFlight {
+				t.Errorf("port %d and max in flight %d, want %d and %d", current.Port, current.Server.MaxInFlight, started.Port, started.Server.MaxInFlight)
+			}
+		})
+	}
+}
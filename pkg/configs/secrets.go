@@ -0,0 +1,220 @@
+package configs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/filewatch"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	secretFilePrefix = "file:"
+	secretEnvPrefix  = "env:"
+)
+
+type (
+	// Secret is a sensitive setting, such as a token or a key. In the properties it
+	// is either the value itself or a reference resolved when the configuration is
+	// loaded: "file:/run/secrets/name" reads the file, without its trailing newline,
+	// and "env:NAME" reads the environment variable.
+	//
+	// The value is only returned by Value: printing, logging or encoding a Secret
+	// always yields the redacted placeholder.
+	//
+	// A rotated secret is applied at runtime only when its setting is reloadable,
+	// which is the case of adminToken alone. apiKeys.pepper and tracing.headers
+	// are copied by their consumers at startup and their rotation is logged as
+	// requiring a restart.
+	Secret struct {
+		value string
+		ref   string
+	}
+)
+
+var secretType = reflect.TypeFor[Secret]()
+
+// Value returns the secret in clear.
+func (s Secret) Value() string {
+	return s.value
+}
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// Format redacts the secret for every verb, not only those using String.
+func (s Secret) Format(f fmt.State, _ rune) {
+	io.WriteString(f, redacted)
+}
+
+// MarshalText redacts the secret in JSON and in the text based log formats.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// parseSecret reads the setting raw, resolving its reference when resolve is set.
+// Unresolved references are only checked for their syntax.
+func parseSecret(raw string, resolve bool) (Secret, error) {
+	switch {
+	case strings.HasPrefix(raw, secretFilePrefix):
+		path := strings.TrimPrefix(raw, secretFilePrefix)
+		if path == "" {
+			return Secret{}, fmt.Errorf("%q names no file", raw)
+		}
+		if !resolve {
+			return Secret{ref: raw}, nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Secret{}, fmt.Errorf("reading secret: %w", err)
+		}
+
+		return Secret{value: strings.TrimRight(string(data), "\r\n"), ref: raw}, nil
+	case strings.HasPrefix(raw, secretEnvPrefix):
+		name := strings.TrimPrefix(raw, secretEnvPrefix)
+		if name == "" {
+			return Secret{}, fmt.Errorf("%q names no environment variable", raw)
+		}
+		if !resolve {
+			return Secret{ref: raw}, nil
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return Secret{}, fmt.Errorf("secret environment variable %s is not set", name)
+		}
+
+		return Secret{value: value, ref: raw}, nil
+	default:
+		return Secret{value: raw}, nil
+	}
+}
+
+// decodeSecrets adds the decoding of the Secret settings to the default hooks of viper.
+func decodeSecrets(resolve bool) viper.DecoderConfigOption {
+	hook := func(from, to reflect.Type, data any) (any, error) {
+		if to != secretType || from.Kind() != reflect.String {
+			return data, nil
+		}
+
+		return parseSecret(data.(string), resolve)
+	}
+
+	return func(c *mapstructure.DecoderConfig) {
+		c.DecodeHook = mapstructure.ComposeDecodeHookFunc(hook, c.DecodeHook)
+	}
+}
+
+// isSecretReference reports whether value is a reference, which is shown in place
+// of the redacted placeholder since it discloses nothing.
+func isSecretReference(value any) bool {
+	ref, ok := value.(string)
+	return ok && (strings.HasPrefix(ref, secretFilePrefix) || strings.HasPrefix(ref, secretEnvPrefix))
+}
+
+// secretPaths returns the lowercased paths of the Secret settings of t. A map of
+// secrets is reported by its own path.
+func secretPaths(t reflect.Type, prefix string) []string {
+	var paths []string
+
+	for _, field := range reflect.VisibleFields(t) {
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" {
+			continue
+		}
+
+		path := prefix + strings.ToLower(name)
+		switch {
+		case field.Type == secretType, field.Type.Kind() == reflect.Map && field.Type.Elem() == secretType:
+			paths = append(paths, path)
+		case field.Type.Kind() == reflect.Struct:
+			paths = append(paths, secretPaths(field.Type, path+".")...)
+		}
+	}
+
+	return paths
+}
+
+// secretValues returns the resolved value of every secret of envs by path, map
+// entries below the path of their map.
+func secretValues(envs *EnvVars) map[string]Secret {
+	values := map[string]Secret{}
+	walkSecrets(reflect.ValueOf(envs).Elem(), "", values)
+
+	return values
+}
+
+func walkSecrets(v reflect.Value, prefix string, values map[string]Secret) {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" {
+			continue
+		}
+
+		path := prefix + strings.ToLower(name)
+		switch {
+		case field.Type == secretType:
+			values[path] = v.Field(i).Interface().(Secret)
+		case field.Type.Kind() == reflect.Map && field.Type.Elem() == secretType:
+			for _, key := range v.Field(i).MapKeys() {
+				values[path+"."+strings.ToLower(key.String())] = v.Field(i).MapIndex(key).Interface().(Secret)
+			}
+		case field.Type.Kind() == reflect.Struct:
+			walkSecrets(v.Field(i), path+".", values)
+		}
+	}
+}
+
+// rotatedSecrets returns the paths of the secrets whose value differs between
+// previous and next.
+func rotatedSecrets(previous, next *EnvVars) []string {
+	before, after := secretValues(previous), secretValues(next)
+
+	var paths []string
+	for path, secret := range after {
+		if before[path].value != secret.value {
+			paths = append(paths, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// watchSecretFiles reloads the configuration when a file referenced by a secret
+// of envs changes, so rotated secrets are picked up.
+func watchSecretFiles(envs *EnvVars) {
+	var secretFiles []string
+	for _, secret := range secretValues(envs) {
+		if path, ok := strings.CutPrefix(secret.ref, secretFilePrefix); ok {
+			secretFiles = append(secretFiles, path)
+		}
+	}
+
+	if len(secretFiles) == 0 {
+		return
+	}
+
+	_, err := filewatch.New(secretFiles, reload, func(err error) {
+		logrus.WithError(err).Warn("secret file watcher error")
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to watch the secret files, rotated secrets require a restart")
+	}
+}
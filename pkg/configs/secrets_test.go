@@ -0,0 +1,87 @@
+package configs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSecretRedaction(t *testing.T) {
+	secret := Secret{value: "s3cr3t"}
+	settings := struct {
+		Token   Secret
+		Headers map[string]Secret
+	}{secret, map[string]Secret{"authorization": secret}}
+
+	outputs := map[string]string{}
+	for _, verb := range []string{"%s", "%v", "%+v", "%#v", "%q", "%x", "%d"} {
+		outputs["fmt "+verb] = fmt.Sprintf(verb, secret)
+		outputs["fmt struct "+verb] = fmt.Sprintf(verb, settings)
+	}
+
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs["json"] = string(encoded)
+
+	for name, formatter := range map[string]logrus.Formatter{"json": &logrus.JSONFormatter{}, "text": &logrus.TextFormatter{}} {
+		var buf bytes.Buffer
+		log := logrus.New()
+		log.SetOutput(&buf)
+		log.SetFormatter(formatter)
+
+		log.WithField("token", secret).WithField("settings", settings).Info("loaded")
+		outputs["logrus "+name] = buf.String()
+	}
+
+	for name, output := range outputs {
+		if strings.Contains(output, secret.Value()) || !strings.Contains(output, redacted) {
+			t.Errorf("%s: %s, want the secret redacted", name, output)
+		}
+	}
+}
+
+func TestSecretRotation(t *testing.T) {
+	for _, name := range []string{"ENVIRONMENT", "CONFIG_PATH", "ADMINTOKEN", "APIKEYS_PEPPER"} {
+		t.Setenv(name, "")
+	}
+
+	dir := t.TempDir()
+	adminTokenFile, pepperFile := filepath.Join(dir, "admin_token"), filepath.Join(dir, "api_key_pepper")
+	writeSecret := func(path, value string) {
+		if err := os.WriteFile(path, []byte(value+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeSecret(adminTokenFile, "admin-1")
+	writeSecret(pepperFile, "pepper-1")
+	writeProperties(t, dir, map[string]any{"adminToken": "file:" + adminTokenFile, "apiKeys.pepper": "file:" + pepperFile})
+
+	if _, err := NewConfigs(Options{Path: dir, Environment: "local"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []Change
+	Subscribe(func(change Change) { changes = append(changes, change) })
+
+	writeSecret(adminTokenFile, "admin-2")
+	writeSecret(pepperFile, "pepper-2")
+	reload()
+
+	// Only adminToken is reloadable, the pepper keeps its startup value.
+	if len(changes) != 1 || !slices.Equal(changes[0].Keys, []string{"admintoken"}) {
+		t.Fatalf("changes = %+v, want one with the admintoken key", changes)
+	}
+	if current := Current(); current.AdminToken.Value() != "admin-2" || current.APIKeys.Pepper.Value() != "pepper-1" {
+		t.Fatalf("admin token %q and pepper %q, want admin-2 and pepper-1", current.AdminToken.Value(), current.APIKeys.Pepper.Value())
+	}
+}
@@ -0,0 +1,231 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// schema checks the validate tags of EnvVars. Field errors are reported with the
+// property path of the field, e.g. "server.writeTimeout".
+var schema = newSchema()
+
+func newSchema() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		return name
+	})
+
+	v.RegisterAlias("host", "hostname_rfc1123|ip")
+
+	_ = v.RegisterValidation("environment", func(fl validator.FieldLevel) bool {
+		return validateEnvironment(fl.Field().String()) == nil
+	})
+	_ = v.RegisterValidation("loglevel", func(fl validator.FieldLevel) bool {
+		_, err := logrus.ParseLevel(fl.Field().String())
+		return err == nil
+	})
+
+	v.RegisterStructValidation(validateCrossFields, EnvVars{})
+
+	return v
+}
+
+// Validate checks every setting against the schema declared in the validate tags
+// of EnvVars, plus the rules spanning several settings, and reports all the
+// problems found at once. It is also run by the readiness probe.
+func Validate(envs *EnvVars) error {
+	if envs == nil {
+		return errors.New("configuration not loaded")
+	}
+
+	err := schema.Struct(envs)
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	errs := make([]error, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		errs = append(errs, describe(fieldErr))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateCrossFields checks the rules involving more than one setting.
+func validateCrossFields(sl validator.StructLevel) {
+	envs := sl.Current().Interface().(EnvVars)
+
+	if envs.Port == envs.AdminServer.Port && envs.Host == envs.AdminServer.Host {
+		sl.ReportError(envs.AdminServer.Port, "adminServer.port", "Port", "distinct_address", "")
+	}
+
+	if envs.Server.WriteTimeout > 0 && envs.Server.WriteTimeout <= envs.GatewayTimeout {
+		sl.ReportError(envs.Server.WriteTimeout, "server.writeTimeout", "WriteTimeout", "gt_gateway_timeout", envs.GatewayTimeout.String())
+	}
+
+	if (envs.TLS.ClientAuth == "request" || envs.TLS.ClientAuth == "require") && envs.TLS.ClientCAFile == "" {
+		sl.ReportError(envs.TLS.ClientCAFile, "tls.clientCAFile", "ClientCAFile", "required_with_client_auth", envs.TLS.ClientAuth)
+	}
+
+	if envs.UseLogLevelHook && envs.LogHook.Sink == "" {
+		sl.ReportError(envs.LogHook.Sink, "logHook.sink", "Sink", "required_with_hook", "")
+	}
+
+	validateRouting(sl, envs)
+}
+
+// validateRouting checks that the gateway names are unique and that the routing
+// only references configured gateways.
+func validateRouting(sl validator.StructLevel, envs EnvVars) {
+	names := make([]string, 0, len(envs.Gateways))
+	for i, gateway := range envs.Gateways {
+		if gateway.Name != "" && slices.Contains(names, gateway.Name) {
+			sl.ReportError(gateway.Name, fmt.Sprintf("gateways[%d].name", i), "Name", "unique_gateway", "")
+		}
+		names = append(names, gateway.Name)
+	}
+
+	known := func(value any, path, field, name string) {
+		if name != "" && !slices.Contains(names, name) {
+			sl.ReportError(value, path, field, "known_gateway", "")
+		}
+	}
+
+	known(envs.Routing.Default, "routing.default", "Default", envs.Routing.Default)
+	known(envs.Routing.Failover, "routing.failover", "Failover", envs.Routing.Failover)
+
+	defaultGateway := envs.Routing.Default
+	if defaultGateway == "" && len(envs.Gateways) > 0 {
+		defaultGateway = envs.Gateways[0].Name
+	}
+	if envs.Routing.Failover != "" && envs.Routing.Failover == defaultGateway {
+		sl.ReportError(envs.Routing.Failover, "routing.failover", "Failover", "distinct_failover", "")
+	}
+
+	for i, rule := range envs.Routing.Rules {
+		path := fmt.Sprintf("routing.rules[%d]", i)
+
+		known(rule.Gateway, path+".gateway", "Gateway", rule.Gateway)
+		known(rule.Failover, path+".failover", "Failover", rule.Failover)
+		if rule.Failover != "" && rule.Failover == rule.Gateway {
+			sl.ReportError(rule.Failover, path+".failover", "Failover", "distinct_failover", "")
+		}
+
+		for j, binRange := range rule.BINRanges {
+			if len(binRange.From) != len(binRange.To) || binRange.From > binRange.To {
+				sl.ReportError(binRange.To, fmt.Sprintf("%s.binRanges[%d].to", path, j), "To", "bin_range", binRange.From)
+			}
+		}
+	}
+}
+
+// describe turns a field error into a "path: problem" error.
+func describe(fieldErr validator.FieldError) error {
+	path := fieldErr.Namespace()
+	if _, rest, ok := strings.Cut(path, "."); ok {
+		path = rest
+	}
+
+	var problem string
+	switch fieldErr.Tag() {
+	case "required", "required_if", "required_with":
+		problem = "is required"
+	case "environment":
+		problem = fmt.Sprintf("%q is not a valid environment, use one of %s", fieldErr.Value(), strings.Join(allowedEnvironments, ", "))
+	case "loglevel":
+		problem = fmt.Sprintf("%q is not a log level, use one of %s", fieldErr.Value(), strings.Join(logLevelNames(), ", "))
+	case "timezone":
+		problem = fmt.Sprintf("%q is not a known IANA time zone", fieldErr.Value())
+	case "host":
+		problem = fmt.Sprintf("%q is not a host name or an IP address", fieldErr.Value())
+	case "hostname_rfc1123":
+		problem = fmt.Sprintf("%q is not a host name", fieldErr.Value())
+	case "http_url":
+		problem = fmt.Sprintf("%q is not an http(s) URL", fieldErr.Value())
+	case "cidr":
+		problem = fmt.Sprintf("%q is not a CIDR, e.g. 10.0.0.0/8", fieldErr.Value())
+	case "base64":
+		problem = "must be the base64 SHA-256 of a public key"
+	case "len":
+		if strings.HasPrefix(fieldErr.Field(), "pinnedSHA256") {
+			problem = "must be the base64 SHA-256 of a public key"
+		} else {
+			problem = fmt.Sprintf("%q must be %s characters long", fieldErr.Value(), fieldErr.Param())
+		}
+	case "oneof":
+		problem = fmt.Sprintf("%q is not valid, use one of %s", fieldErr.Value(), strings.Join(strings.Fields(fieldErr.Param()), ", "))
+	case "min", "gte":
+		if fieldErr.Kind() == reflect.String {
+			problem = fmt.Sprintf("%q must have at least %s characters", fieldErr.Value(), fieldErr.Param())
+		} else {
+			problem = fmt.Sprintf("must be at least %s, got %v", fieldErr.Param(), fieldErr.Value())
+		}
+	case "max", "lte":
+		if fieldErr.Kind() == reflect.String {
+			problem = fmt.Sprintf("%q must have at most %s characters", fieldErr.Value(), fieldErr.Param())
+		} else {
+			problem = fmt.Sprintf("must be at most %s, got %v", fieldErr.Param(), fieldErr.Value())
+		}
+	case "gt":
+		problem = fmt.Sprintf("must be greater than %s, got %v", fieldErr.Param(), fieldErr.Value())
+	case "distinct_address":
+		problem = "the admin listener must use a different address than the payment listener"
+	case "gt_gateway_timeout":
+		problem = fmt.Sprintf("%v must exceed gatewayTimeout (%s) so gateway answers can still be written", fieldErr.Value(), fieldErr.Param())
+	case "required_with_client_auth":
+		problem = fmt.Sprintf("is required when tls.clientAuth is %q", fieldErr.Param())
+	case "required_with_hook":
+		problem = "is required when logLevelHook is enabled"
+	case "unique_gateway":
+		problem = fmt.Sprintf("%q names another gateway already", fieldErr.Value())
+	case "known_gateway":
+		problem = fmt.Sprintf("%q is not a configured gateway", fieldErr.Value())
+	case "distinct_failover":
+		problem = fmt.Sprintf("%q cannot fail over to itself", fieldErr.Value())
+	case "bin_range":
+		problem = fmt.Sprintf("%q must have as many digits as, and not be below, from %q", fieldErr.Value(), fieldErr.Param())
+	case "datetime":
+		problem = fmt.Sprintf("%q does not match the %s layout", fieldErr.Value(), fieldErr.Param())
+	case "numeric":
+		problem = fmt.Sprintf("%q must only hold digits", fieldErr.Value())
+	default:
+		problem = fmt.Sprintf("fails the %s rule", fieldErr.Tag())
+	}
+
+	return fmt.Errorf("%s: %s", path, problem)
+}
+
+func logLevelNames() []string {
+	names := make([]string, 0, len(logrus.AllLevels))
+	for _, level := range logrus.AllLevels {
+		names = append(names, level.String())
+	}
+
+	return names
+}
+
+// validateEnvironment checks if the provided environment string is valid
+// by comparing it against the list of allowed environments.
+//
+// Parameters:
+// - env: The environment string to validate.
+//
+// Returns:
+// An error if the environment is not valid; otherwise, it returns nil.
+func validateEnvironment(env string) error {
+	if !slices.Contains(allowedEnvironments, env) {
+		return ErrInvalidEnvironment(env)
+	}
+
+	return nil
+}
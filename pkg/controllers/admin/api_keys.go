@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+)
+
+type (
+	APIKeysController struct {
+		service services.APIKeyService
+	}
+)
+
+func NewAPIKeysController(service services.APIKeyService) *APIKeysController {
+	return &APIKeysController{service}
+}
+
+// Post godoc
+// @Summary Issue an API key
+// @Description Issue a new API key for a merchant. With rotate=true the oldest active key is revoked when the merchant already has two active keys
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param body body models.CreateAPIKeyRequest true "API key request"
+// @Success 201 {object} models.CreateAPIKeyResponse
+// @Failure 400 {object} controllers.HTTPResponse
+// @Failure 409 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+func (c *APIKeysController) Post(w http.ResponseWriter, r *http.Request) {
+	var body models.CreateAPIKeyRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		controllers.NewResponseBuilder(w).UnformattedBody().Build()
+		return
+	}
+
+	if validationErr := controllers.BodyValidator(r.Context(), &body); validationErr != nil {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(validationErr.Message).Build()
+		return
+	}
+
+	resp, err := c.service.Issue(r.Context(), &body)
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Created().Body(resp).Build()
+}
+
+// List godoc
+// @Summary List API keys
+// @Description List the API keys of a merchant, without their hashes
+// @Tags admin
+// @Produce json
+// @Param merchant_id query string true "Merchant ID"
+// @Success 200 {array} models.APIKey
+// @Failure 400 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+func (c *APIKeysController) List(w http.ResponseWriter, r *http.Request) {
+	merchantID := r.URL.Query().Get("merchant_id")
+	if merchantID == "" {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage("merchant_id is required").Build()
+		return
+	}
+
+	keys, err := c.service.List(r.Context(), merchantID)
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Ok().Body(keys).Build()
+}
+
+// Delete godoc
+// @Summary Revoke an API key
+// @Tags admin
+// @Param id path string true "API key ID"
+// @Success 204
+// @Failure 404 {object} controllers.HTTPResponse
+// @Failure 409 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+func (c *APIKeysController) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := c.service.Revoke(r.Context(), chi.URLParam(r, "id")); err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).NoContent().Build()
+}
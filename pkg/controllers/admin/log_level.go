@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+type (
+	LogLevelController struct{}
+)
+
+func NewLogLevelController() *LogLevelController {
+	return &LogLevelController{}
+}
+
+// Get godoc
+// @Summary Read the log levels
+// @Description Read the global log level and the effective level of every component
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.LogLevelResponse
+func (c *LogLevelController) Get(w http.ResponseWriter, r *http.Request) {
+	controllers.NewResponseBuilder(w).Ok().Body(toLogLevelResponse(logger.Levels())).Build()
+}
+
+// Put godoc
+// @Summary Change a log level
+// @Description Change the global log level, or the level of a component (http, gateway, repository, service), optionally reverting it after a TTL
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param body body models.SetLogLevelRequest true "Log level request"
+// @Success 200 {object} models.LogLevelResponse
+// @Failure 400 {object} controllers.HTTPResponse
+func (c *LogLevelController) Put(w http.ResponseWriter, r *http.Request) {
+	var body models.SetLogLevelRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		controllers.NewResponseBuilder(w).UnformattedBody().Build()
+		return
+	}
+
+	if validationErr := controllers.BodyValidator(r.Context(), &body); validationErr != nil {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(validationErr.Message).Build()
+		return
+	}
+
+	level, err := logrus.ParseLevel(body.Level)
+	if err != nil {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(err.Error()).Build()
+		return
+	}
+
+	var ttl time.Duration
+	if body.TTL != "" {
+		if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+			controllers.NewResponseBuilder(w).InvalidBody().ErrMessage("ttl must be a positive duration such as 15m").Build()
+			return
+		}
+	}
+
+	if err := logger.SetLevel(body.Component, level, ttl, logger.ActorAdmin); err != nil {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(err.Error()).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Ok().Body(toLogLevelResponse(logger.Levels())).Build()
+}
+
+// Delete godoc
+// @Summary Reset a component log level
+// @Description Remove the override of a component so it follows the global level again
+// @Tags admin
+// @Param component path string true "Component"
+// @Success 204
+// @Failure 404 {object} controllers.HTTPResponse
+func (c *LogLevelController) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := logger.ResetLevel(chi.URLParam(r, "component"), logger.ActorAdmin); err != nil {
+		controllers.NewResponseBuilder(w).NotFound().ErrMessage(err.Error()).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).NoContent().Build()
+}
+
+func toLogLevelResponse(snapshot logger.LevelSnapshot) *models.LogLevelResponse {
+	resp := &models.LogLevelResponse{
+		Level:      snapshot.Level,
+		RevertAt:   snapshot.RevertAt,
+		Components: make(map[string]models.ComponentLogLevel, len(snapshot.Components)),
+	}
+
+	for name, component := range snapshot.Components {
+		resp.Components[name] = models.ComponentLogLevel{
+			Level:    component.Level,
+			Override: component.Override,
+			RevertAt: component.RevertAt,
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"net/http"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/buildinfo"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/health"
+)
+
+type (
+	OperationsController struct {
+		checker *health.Checker
+	}
+)
+
+func NewOperationsController(checker *health.Checker) *OperationsController {
+	return &OperationsController{checker}
+}
+
+// Livez godoc
+// @Summary Liveness probe
+// @Description Answers as long as the process serves HTTP; it checks no dependency
+// @Tags operations
+// @Produce json
+// @Success 200 {object} health.Report
+func (c *OperationsController) Livez(w http.ResponseWriter, r *http.Request) {
+	controllers.NewResponseBuilder(w).Ok().Body(&health.Report{Status: health.StatusUp}).Build()
+}
+
+// Readyz godoc
+// @Summary Readiness probe
+// @Description Runs the readiness checks: gateway session, circuit breaker, connection pool, repository and configuration
+// @Tags operations
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} controllers.HTTPResponse
+func (c *OperationsController) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := c.checker.Run(r.Context())
+
+	if report.Status != health.StatusUp {
+		controllers.NewResponseBuilder(w).ServiceUnavailable().ErrMessage("not ready").ErrDetails(report).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Ok().Body(report).Build()
+}
+
+// BuildInfo godoc
+// @Summary Build information
+// @Tags operations
+// @Produce json
+// @Success 200 {object} buildinfo.Info
+func (c *OperationsController) BuildInfo(w http.ResponseWriter, r *http.Request) {
+	controllers.NewResponseBuilder(w).Ok().Body(buildinfo.Get()).Build()
+}
+
+// Config godoc
+// @Summary Effective configuration
+// @Description Dump the loaded configuration with its secrets redacted
+// @Tags operations
+// @Produce json
+// @Success 200 {object} map[string]any
+func (c *OperationsController) Config(w http.ResponseWriter, r *http.Request) {
+	controllers.NewResponseBuilder(w).Ok().Body(configs.RedactedSettings()).Build()
+}
@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/health"
+)
+
+func TestReadyzWhileDraining(t *testing.T) {
+	checker := health.NewChecker()
+	checker.Add("gateway_session", func(context.Context) error { return nil })
+	controller := NewOperationsController(checker)
+
+	rec := httptest.NewRecorder()
+	controller.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d before draining, want %d", rec.Code, http.StatusOK)
+	}
+
+	checker.SetDraining()
+
+	rec = httptest.NewRecorder()
+	controller.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d while draining, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, health.ErrShuttingDown.Error()) {
+		t.Fatalf("body %s does not report the shutdown", body)
+	}
+
+	// Liveness is unaffected, the process keeps serving until it exits.
+	rec = httptest.NewRecorder()
+	controller.Livez(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("livez status = %d while draining, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -25,10 +25,14 @@ func NewAuthorizationController(service services.AuthorizationService) *Authoriz
 // @Tags financial
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param body body models.AuthorizationRequest true "Authorization request"
 // @Success 200 {object} models.AuthorizationResponse
 // @Failure 400 {object} controllers.HTTPResponse
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 403 {object} controllers.HTTPResponse
 // @Failure 500 {object} controllers.HTTPResponse
+// @Failure 503 {object} controllers.HTTPResponse
 func (c *AuthorizationController) Post(w http.ResponseWriter, r *http.Request) {
 	var body models.AuthorizationRequest
 
@@ -37,14 +41,14 @@ func (c *AuthorizationController) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if validationErr := controllers.BodyValidator(&body); validationErr != nil {
+	if validationErr := controllers.BodyValidator(r.Context(), &body); validationErr != nil {
 		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(validationErr.Message).Build()
 		return
 	}
 
 	resp, err := c.service.Process(r.Context(), &body)
 	if err != nil {
-		controllers.NewResponseBuilder(w).InternalError().ErrMessage(err.Error()).Build()
+		controllers.NewResponseBuilder(w).Error(err).Build()
 		return
 	}
 
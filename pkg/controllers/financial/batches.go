@@ -0,0 +1,83 @@
+package financial
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+)
+
+type (
+	BatchesController struct {
+		service services.BatchService
+	}
+)
+
+func NewBatchesController(service services.BatchService) *BatchesController {
+	return &BatchesController{service}
+}
+
+// Close godoc
+// @Summary Close the batch of a terminal
+// @Description Total the approved transactions of the terminal not settled yet and reconcile them in the background with each gateway (0500), uploading them (0320) when out of balance. The batches are returned open; their outcome is listed by GET /v1/batches
+// @Tags financial
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body models.BatchCloseRequest true "Batch close request"
+// @Success 202 {object} models.BatchCloseResponse
+// @Failure 400 {object} controllers.HTTPResponse
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 403 {object} controllers.HTTPResponse
+// @Failure 409 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+func (c *BatchesController) Close(w http.ResponseWriter, r *http.Request) {
+	var body models.BatchCloseRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		controllers.NewResponseBuilder(w).UnformattedBody().Build()
+		return
+	}
+
+	if validationErr := controllers.BodyValidator(r.Context(), &body); validationErr != nil {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(validationErr.Message).Build()
+		return
+	}
+
+	resp, err := c.service.Close(r.Context(), &body)
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Accepted().Body(resp).Build()
+}
+
+// List godoc
+// @Summary List the batches of a terminal
+// @Description List the batches of a terminal of the authenticated merchant, newest first, with their numbers, totals and outcome
+// @Tags financial
+// @Produce json
+// @Security ApiKeyAuth
+// @Param terminal_id query string true "Terminal ID"
+// @Success 200 {array} models.Batch
+// @Failure 400 {object} controllers.HTTPResponse
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+func (c *BatchesController) List(w http.ResponseWriter, r *http.Request) {
+	terminalID := r.URL.Query().Get("terminal_id")
+	if terminalID == "" {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage("terminal_id is required").Build()
+		return
+	}
+
+	batches, err := c.service.List(r.Context(), terminalID)
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Ok().Body(batches).Build()
+}
@@ -25,10 +25,15 @@ func NewCancellationController(service services.CancellactionService) *Cancellat
 // @Tags financial
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param body body models.CancellationRequest true "Cancellation request"
 // @Success 200 {object} models.CancellationResponse
 // @Failure 400 {object} controllers.HTTPResponse
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 403 {object} controllers.HTTPResponse
+// @Failure 422 {object} controllers.HTTPResponse
 // @Failure 500 {object} controllers.HTTPResponse
+// @Failure 503 {object} controllers.HTTPResponse
 func (c *CancellationController) Post(w http.ResponseWriter, r *http.Request) {
 	var body models.CancellationRequest
 
@@ -37,14 +42,14 @@ func (c *CancellationController) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if validationErr := controllers.BodyValidator(&body); validationErr != nil {
-		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(validationErr.Message).Build()
+	if validationErr := controllers.BodyValidator(r.Context(), &body); validationErr != nil {
+		invalidFollowUp(w, validationErr)
 		return
 	}
 
 	resp, err := c.service.Process(r.Context(), &body)
 	if err != nil {
-		controllers.NewResponseBuilder(w).InternalError().ErrMessage(err.Error()).Build()
+		controllers.NewResponseBuilder(w).Error(err).Build()
 		return
 	}
 
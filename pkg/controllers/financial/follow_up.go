@@ -0,0 +1,22 @@
+package financial
+
+import (
+	"net/http"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+)
+
+// invalidFollowUp answers an invalid follow-up body: 422 when it lacks the
+// original_transaction_id, since the operation cannot be routed without it, 400
+// for any other validation error.
+func invalidFollowUp(w http.ResponseWriter, validationErr *controllers.HTTPError) {
+	if details, ok := validationErr.Details.(map[string]string); ok {
+		if _, missing := details["OriginalTransactionID"]; missing {
+			controllers.NewResponseBuilder(w).Error(services.ErrOriginalTransactionRequired).Build()
+			return
+		}
+	}
+
+	controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(validationErr.Message).Build()
+}
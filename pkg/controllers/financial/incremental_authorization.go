@@ -0,0 +1,58 @@
+package financial
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+)
+
+type (
+	IncrementalAuthorizationController struct {
+		service services.IncrementalAuthorizationService
+	}
+)
+
+func NewIncrementalAuthorizationController(service services.IncrementalAuthorizationService) *IncrementalAuthorizationController {
+	return &IncrementalAuthorizationController{service}
+}
+
+// Post godoc
+// @Summary Process incremental authorization
+// @Description Add to the amount held by an approved pre-authorization not captured yet, e.g. for a longer hotel stay or car rental
+// @Tags financial
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body models.IncrementalAuthorizationRequest true "Incremental authorization request"
+// @Success 200 {object} models.IncrementalAuthorizationResponse
+// @Failure 400 {object} controllers.HTTPResponse
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 403 {object} controllers.HTTPResponse
+// @Failure 404 {object} controllers.HTTPResponse
+// @Failure 422 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+// @Failure 503 {object} controllers.HTTPResponse
+func (c *IncrementalAuthorizationController) Post(w http.ResponseWriter, r *http.Request) {
+	var body models.IncrementalAuthorizationRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		controllers.NewResponseBuilder(w).UnformattedBody().Build()
+		return
+	}
+
+	if validationErr := controllers.BodyValidator(r.Context(), &body); validationErr != nil {
+		invalidFollowUp(w, validationErr)
+		return
+	}
+
+	resp, err := c.service.Process(r.Context(), &body)
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Ok().Body(resp).Build()
+}
@@ -0,0 +1,267 @@
+package financial
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+// exportPageSize is the page size used to stream a CSV export.
+const exportPageSize = 500
+
+var exportColumns = []string{
+	"transaction_id", "operation", "status", "response_code", "authorization_code", "merchant_id", "terminal_id",
+	"stan", "rrn", "pan_last4", "amount", "currency", "original_transaction_id", "created_at", "updated_at",
+}
+
+type (
+	PaymentsController struct {
+		service services.PaymentService
+	}
+)
+
+func NewPaymentsController(service services.PaymentService) *PaymentsController {
+	return &PaymentsController{service}
+}
+
+// Get godoc
+// @Summary Get a payment
+// @Description Get the lifecycle state, amounts and history of a payment of the authenticated merchant
+// @Tags financial
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Transaction ID"
+// @Success 200 {object} models.Payment
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 404 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+func (c *PaymentsController) Get(w http.ResponseWriter, r *http.Request) {
+	payment, err := c.service.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Ok().Body(payment).Build()
+}
+
+// Lookup godoc
+// @Summary Look up a payment by terminal and STAN
+// @Description Find a payment of the authenticated merchant by terminal ID and STAN, for terminals that lost the response
+// @Tags financial
+// @Produce json
+// @Security ApiKeyAuth
+// @Param terminal_id query string true "Terminal ID"
+// @Param stan query string true "STAN"
+// @Param date query string false "UTC date of the payment, YYYY-MM-DD, today when empty"
+// @Success 200 {object} models.Payment
+// @Failure 400 {object} controllers.HTTPResponse
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 404 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+func (c *PaymentsController) Lookup(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	terminalID, stan := query.Get("terminal_id"), query.Get("stan")
+	if terminalID == "" || stan == "" {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage("terminal_id and stan are required").Build()
+		return
+	}
+
+	day := time.Now().UTC()
+	if date := query.Get("date"); date != "" {
+		parsed, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			controllers.NewResponseBuilder(w).InvalidBody().ErrMessage("date must be in the YYYY-MM-DD form").Build()
+			return
+		}
+		day = parsed
+	}
+
+	payment, err := c.service.Lookup(r.Context(), terminalID, stan, day)
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Ok().Body(payment).Build()
+}
+
+// List godoc
+// @Summary Search payments
+// @Description List the transactions of the authenticated merchant matching the filters, a page at a time
+// @Tags financial
+// @Produce json
+// @Security ApiKeyAuth
+// @Param merchant_id query string false "Merchant ID, the authenticated merchant"
+// @Param terminal_id query string false "Terminal ID"
+// @Param status query string false "Comma separated statuses, e.g. approved,declined"
+// @Param operation query string false "Comma separated operations, e.g. authorization,confirmation"
+// @Param from query string false "Created at or after, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Created before, RFC 3339, or YYYY-MM-DD for the whole day"
+// @Param min_amount query string false "Minimum amount, in minor units"
+// @Param max_amount query string false "Maximum amount, in minor units"
+// @Param pan_last4 query string false "Last four digits of the card number"
+// @Param response_code query string false "ISO response code"
+// @Param sort query string false "created_at, -created_at, amount or -amount; -created_at when empty"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Page size, up to 500; 50 when empty"
+// @Success 200 {object} models.PaymentPage
+// @Failure 400 {object} controllers.HTTPResponse
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 403 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+func (c *PaymentsController) List(w http.ResponseWriter, r *http.Request) {
+	req, validationErr := parseSearch(r)
+	if validationErr != nil {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(validationErr.Message).ErrDetails(validationErr.Details).Build()
+		return
+	}
+
+	page, err := c.service.Search(r.Context(), req)
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Ok().Body(page).Build()
+}
+
+// Export godoc
+// @Summary Export payments as CSV
+// @Description Export every transaction of the authenticated merchant matching the filters of List, as CSV
+// @Tags financial
+// @Produce text/csv
+// @Security ApiKeyAuth
+// @Success 200 {string} string "CSV with a header line"
+// @Failure 400 {object} controllers.HTTPResponse
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 403 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+func (c *PaymentsController) Export(w http.ResponseWriter, r *http.Request) {
+	req, validationErr := parseSearch(r)
+	if validationErr != nil {
+		controllers.NewResponseBuilder(w).InvalidBody().ErrMessage(validationErr.Message).ErrDetails(validationErr.Details).Build()
+		return
+	}
+	req.Limit = exportPageSize
+
+	// The first page is read before answering, so errors still get a JSON body.
+	page, err := c.service.Search(r.Context(), req)
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="payments.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write(exportColumns)
+
+	for {
+		for _, tx := range page.Transactions {
+			writer.Write([]string{
+				tx.TransactionID, tx.Operation, string(tx.Status), tx.ResponseCode, tx.AuthorizationCode, tx.MerchantID, tx.TerminalID,
+				tx.STAN, tx.RRN, tx.PANLast4, tx.Amount, tx.Currency, tx.OriginalTransactionID,
+				tx.CreatedAt.Format(time.RFC3339Nano), tx.UpdatedAt.Format(time.RFC3339Nano),
+			})
+		}
+		writer.Flush()
+
+		if page.NextCursor == "" || writer.Error() != nil {
+			break
+		}
+
+		req.Cursor = page.NextCursor
+		if page, err = c.service.Search(r.Context(), req); err != nil {
+			// The status is sent already: the truncated export is only logged.
+			logger.FromContext(r.Context()).WithError(err).Error("payments export interrupted")
+			return
+		}
+	}
+
+	if err := writer.Error(); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Warn("payments export not fully written")
+	}
+}
+
+// parseSearch reads the search filters of the query string.
+func parseSearch(r *http.Request) (*models.PaymentSearchRequest, *controllers.HTTPError) {
+	query := r.URL.Query()
+
+	req := &models.PaymentSearchRequest{
+		MerchantID:   query.Get("merchant_id"),
+		TerminalID:   query.Get("terminal_id"),
+		Statuses:     listParam(query, "status"),
+		Operations:   listParam(query, "operation"),
+		MinAmount:    query.Get("min_amount"),
+		MaxAmount:    query.Get("max_amount"),
+		PANLast4:     query.Get("pan_last4"),
+		ResponseCode: query.Get("response_code"),
+		Sort:         query.Get("sort"),
+		Cursor:       query.Get("cursor"),
+	}
+
+	var err error
+	if req.From, err = timeParam(query.Get("from"), false); err != nil {
+		return nil, controllers.NewHTTPError(http.StatusBadRequest, "from must be an RFC 3339 time or a YYYY-MM-DD date", nil)
+	}
+	if req.To, err = timeParam(query.Get("to"), true); err != nil {
+		return nil, controllers.NewHTTPError(http.StatusBadRequest, "to must be an RFC 3339 time or a YYYY-MM-DD date", nil)
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, controllers.NewHTTPError(http.StatusBadRequest, "limit must be a number", nil)
+		}
+	}
+
+	if validationErr := controllers.BodyValidator(r.Context(), req); validationErr != nil {
+		validationErr.Message = "invalid query"
+		return nil, validationErr
+	}
+
+	return req, nil
+}
+
+// listParam reads a parameter given as a comma separated list, repeated or not.
+func listParam(query url.Values, name string) []string {
+	var values []string
+	for _, value := range query[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+
+	return values
+}
+
+// timeParam reads an RFC 3339 time or a UTC date. A date ending a range, end set,
+// includes the whole day.
+func timeParam(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
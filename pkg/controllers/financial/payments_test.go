@@ -0,0 +1,144 @@
+package financial
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/repositories"
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+)
+
+func TestPaymentsOfAnotherMerchantAreNotFound(t *testing.T) {
+	transactions, err := repositories.NewFileTransactionRepository(filepath.Join(t.TempDir(), "transactions.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { transactions.Close() })
+
+	now := time.Now().UTC()
+	for _, tx := range []*models.Transaction{
+		{ID: "tx-m1", Operation: "authorization", MTI: "0200", MerchantID: "M1", TerminalID: "T1", STAN: "000123", Status: models.TransactionApproved, CreatedAt: now, UpdatedAt: now},
+		{ID: "tx-m2", Operation: "authorization", MTI: "0200", MerchantID: "M2", TerminalID: "T2", STAN: "000456", Status: models.TransactionApproved, CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := transactions.Create(context.Background(), tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	controller := NewPaymentsController(services.NewPaymentService(transactions))
+	router := chi.NewRouter()
+	router.Get("/v1/payments/lookup", controller.Lookup)
+	router.Get("/v1/payments/{id}", controller.Get)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{name: "own payment", target: "/v1/payments/tx-m1", wantStatus: http.StatusOK},
+		{name: "payment of another merchant", target: "/v1/payments/tx-m2", wantStatus: http.StatusNotFound},
+		{name: "unknown payment", target: "/v1/payments/tx-unknown", wantStatus: http.StatusNotFound},
+		{name: "own lookup", target: "/v1/payments/lookup?terminal_id=T1&stan=000123", wantStatus: http.StatusOK},
+		{name: "lookup of another merchant", target: "/v1/payments/lookup?terminal_id=T2&stan=000456", wantStatus: http.StatusNotFound},
+	}
+
+	var notFoundBody string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{MerchantID: "M1", KeyID: "key-1"}))
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			// Other merchants' payments get the answer of unknown ones, disclosing nothing.
+			if rec.Code == http.StatusNotFound {
+				if notFoundBody != "" && rec.Body.String() != notFoundBody {
+					t.Fatalf("body %s, want the one of every payment not found %s", rec.Body, notFoundBody)
+				}
+				notFoundBody = rec.Body.String()
+			}
+		})
+	}
+}
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		wantErr        bool
+		wantSort       string
+		wantLimit      int
+		wantStatuses   []string
+		wantFrom       time.Time
+		wantTo         time.Time
+		wantCursor     string
+		wantOperations []string
+	}{
+		{name: "default order", query: ""},
+		{name: "created at ascending", query: "sort=created_at", wantSort: "created_at"},
+		{name: "created at descending", query: "sort=-created_at", wantSort: "-created_at"},
+		{name: "amount ascending", query: "sort=amount", wantSort: "amount"},
+		{name: "amount descending", query: "sort=-amount", wantSort: "-amount"},
+		{name: "cursor kept with its sort", query: "sort=-amount&cursor=abc", wantSort: "-amount", wantCursor: "abc"},
+		{name: "unknown sort field", query: "sort=price", wantErr: true},
+		{name: "sort on a filter only field", query: "sort=-status", wantErr: true},
+		{name: "limit", query: "limit=20", wantLimit: 20},
+		{name: "limit above the maximum", query: "limit=501", wantErr: true},
+		{name: "limit not a number", query: "limit=ten", wantErr: true},
+		{name: "comma separated and repeated lists", query: "status=approved,declined&status=reversed&operation=cancellation", wantStatuses: []string{"approved", "declined", "reversed"}, wantOperations: []string{"cancellation"}},
+		{name: "unknown status", query: "status=settled", wantErr: true},
+		{
+			name:     "dates cover whole days",
+			query:    "from=2026-10-01&to=2026-10-19",
+			wantFrom: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{name: "to before from", query: "from=2026-10-19&to=2026-10-01", wantErr: true},
+		{name: "invalid date", query: "from=19/10/2026", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, validationErr := parseSearch(httptest.NewRequest("GET", "/v1/payments?"+tt.query, nil))
+			if (validationErr != nil) != tt.wantErr {
+				t.Fatalf("validation error = %v, want error %t", validationErr, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if req.Sort != tt.wantSort {
+				t.Errorf("sort = %q, want %q", req.Sort, tt.wantSort)
+			}
+			if req.Cursor != tt.wantCursor {
+				t.Errorf("cursor = %q, want %q", req.Cursor, tt.wantCursor)
+			}
+			if req.Limit != tt.wantLimit {
+				t.Errorf("limit = %d, want %d", req.Limit, tt.wantLimit)
+			}
+			if !slices.Equal(req.Statuses, tt.wantStatuses) {
+				t.Errorf("statuses = %v, want %v", req.Statuses, tt.wantStatuses)
+			}
+			if !slices.Equal(req.Operations, tt.wantOperations) {
+				t.Errorf("operations = %v, want %v", req.Operations, tt.wantOperations)
+			}
+			if !req.From.Equal(tt.wantFrom) || !req.To.Equal(tt.wantTo) {
+				t.Errorf("range = [%s, %s), want [%s, %s)", req.From, req.To, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package financial
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/models"
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+)
+
+type (
+	RefundController struct {
+		service services.RefundService
+	}
+)
+
+func NewRefundController(service services.RefundService) *RefundController {
+	return &RefundController{service}
+}
+
+// Post godoc
+// @Summary Process refund
+// @Description Refund part or all of the captured amount of a payment; partial refunds can be repeated up to the captured amount
+// @Tags financial
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body models.RefundRequest true "Refund request"
+// @Success 200 {object} models.RefundResponse
+// @Failure 400 {object} controllers.HTTPResponse
+// @Failure 401 {object} controllers.HTTPResponse
+// @Failure 403 {object} controllers.HTTPResponse
+// @Failure 404 {object} controllers.HTTPResponse
+// @Failure 422 {object} controllers.HTTPResponse
+// @Failure 500 {object} controllers.HTTPResponse
+// @Failure 503 {object} controllers.HTTPResponse
+func (c *RefundController) Post(w http.ResponseWriter, r *http.Request) {
+	var body models.RefundRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		controllers.NewResponseBuilder(w).UnformattedBody().Build()
+		return
+	}
+
+	if validationErr := controllers.BodyValidator(r.Context(), &body); validationErr != nil {
+		invalidFollowUp(w, validationErr)
+		return
+	}
+
+	resp, err := c.service.Process(r.Context(), &body)
+	if err != nil {
+		controllers.NewResponseBuilder(w).Error(err).Build()
+		return
+	}
+
+	controllers.NewResponseBuilder(w).Ok().Body(resp).Build()
+}
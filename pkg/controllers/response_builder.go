@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -9,11 +10,20 @@ type (
 	ResponseBuilder interface {
 		Ok() ResponseBuilder
 		Created() ResponseBuilder
+		Accepted() ResponseBuilder
 		Body(body any) ResponseBuilder
 		Headers(headers map[string]string) ResponseBuilder
 		UnformattedBody() ResponseBuilder
 		InvalidBody() ResponseBuilder
 		InternalError() ResponseBuilder
+		Unauthorized() ResponseBuilder
+		Forbidden() ResponseBuilder
+		NotFound() ResponseBuilder
+		TooManyRequests() ResponseBuilder
+		ServiceUnavailable() ResponseBuilder
+		PayloadTooLarge() ResponseBuilder
+		NoContent() ResponseBuilder
+		Error(err error) ResponseBuilder
 		ErrMessage(msg string) ResponseBuilder
 		ErrDetails(details any) ResponseBuilder
 		Build()
@@ -28,6 +38,16 @@ type (
 		errDetails any
 	}
 
+	// statusCoder is implemented by domain errors that map to a specific HTTP status.
+	statusCoder interface {
+		HTTPStatus() int
+	}
+
+	// detailer is implemented by domain errors carrying additional details.
+	detailer interface {
+		ErrorDetails() any
+	}
+
 	// HTTP Error Response
 	HTTPError struct {
 		StatusCode int    `json:"status_code" example:"400"`
@@ -50,6 +70,11 @@ func (resp *responseBuilder) Created() ResponseBuilder {
 	return resp
 }
 
+func (resp *responseBuilder) Accepted() ResponseBuilder {
+	resp.statusCode = http.StatusAccepted
+	return resp
+}
+
 func (resp *responseBuilder) Body(body any) ResponseBuilder {
 	resp.body = body
 	return resp
@@ -78,6 +103,67 @@ func (resp *responseBuilder) InternalError() ResponseBuilder {
 	return resp
 }
 
+func (resp *responseBuilder) Unauthorized() ResponseBuilder {
+	resp.statusCode = http.StatusUnauthorized
+	resp.errMessage = "unauthorized"
+	return resp
+}
+
+func (resp *responseBuilder) Forbidden() ResponseBuilder {
+	resp.statusCode = http.StatusForbidden
+	resp.errMessage = "forbidden"
+	return resp
+}
+
+func (resp *responseBuilder) NotFound() ResponseBuilder {
+	resp.statusCode = http.StatusNotFound
+	resp.errMessage = "not found"
+	return resp
+}
+
+func (resp *responseBuilder) TooManyRequests() ResponseBuilder {
+	resp.statusCode = http.StatusTooManyRequests
+	resp.errMessage = "too many requests"
+	return resp
+}
+
+func (resp *responseBuilder) ServiceUnavailable() ResponseBuilder {
+	resp.statusCode = http.StatusServiceUnavailable
+	resp.errMessage = "service unavailable"
+	return resp
+}
+
+func (resp *responseBuilder) PayloadTooLarge() ResponseBuilder {
+	resp.statusCode = http.StatusRequestEntityTooLarge
+	resp.errMessage = "payload too large"
+	return resp
+}
+
+func (resp *responseBuilder) NoContent() ResponseBuilder {
+	resp.statusCode = http.StatusNoContent
+	return resp
+}
+
+// Error sets the status code, message and details from err. Errors exposing an
+// HTTPStatus method keep their status code, anything else is reported as an
+// internal error.
+func (resp *responseBuilder) Error(err error) ResponseBuilder {
+	var coder statusCoder
+	if errors.As(err, &coder) {
+		resp.statusCode = coder.HTTPStatus()
+	} else {
+		resp.statusCode = http.StatusInternalServerError
+	}
+
+	var withDetails detailer
+	if errors.As(err, &withDetails) {
+		resp.errDetails = withDetails.ErrorDetails()
+	}
+
+	resp.errMessage = err.Error()
+	return resp
+}
+
 func (resp *responseBuilder) ErrMessage(message string) ResponseBuilder {
 	resp.errMessage = message
 	return resp
@@ -97,6 +183,11 @@ func (resp *responseBuilder) Build() {
 		}
 	}
 
+	if resp.statusCode == http.StatusNoContent {
+		resp.writer.WriteHeader(resp.statusCode)
+		return
+	}
+
 	header.Add("Content-Type", "application/json; charset=utf-8")
 	resp.writer.WriteHeader(resp.statusCode)
 
@@ -1,13 +1,20 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel/attribute"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/tracing"
 )
 
-func BodyValidator(body any) *HTTPError {
+func BodyValidator(ctx context.Context, body any) *HTTPError {
+	_, span := tracing.Start(ctx, "controllers.BodyValidator")
+	defer span.End()
+
 	val := validator.New()
 	err := val.Struct(body)
 
@@ -15,6 +22,8 @@ func BodyValidator(body any) *HTTPError {
 		return nil
 	}
 
+	span.SetAttributes(attribute.Bool("validation.failed", true))
+
 	validationErrors := err.(validator.ValidationErrors)
 	messages := make(map[string]string, len(validationErrors))
 
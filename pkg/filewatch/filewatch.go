@@ -0,0 +1,89 @@
+// Package filewatch notifies the changes of files that may be replaced
+// atomically, such as mounted certificates and secrets.
+package filewatch
+
+import (
+	"path/filepath"
+	"slices"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type (
+	// Watcher watches a set of files through their directories.
+	Watcher struct {
+		watcher *fsnotify.Watcher
+		files   []string
+	}
+)
+
+// New starts watching files, calling onChange whenever one of them is written,
+// created or replaced and onError when the watcher reports a failure. Both are
+// called from the goroutine of the watcher. Empty paths are ignored.
+//
+// Directories are watched instead of the files themselves so atomic
+// replacements (rename, Kubernetes secret symlink swaps) are noticed.
+func New(files []string, onChange func(), onError func(error)) (*Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{watcher: watcher}
+
+	dirs := map[string]bool{}
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+
+		file = filepath.Clean(file)
+		w.files = append(w.files, file)
+
+		dir := filepath.Dir(file)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	go w.watch(onChange, onError)
+
+	return w, nil
+}
+
+// Close stops watching the files.
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
+
+func (w *Watcher) watch(onChange func(), onError func(error)) {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if w.concerns(event.Name) && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				onChange()
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			onError(err)
+		}
+	}
+}
+
+// concerns reports whether a file system event may affect the watched files.
+// Kubernetes mounts swap a "..data" symlink, so events on it count as well.
+func (w *Watcher) concerns(name string) bool {
+	return filepath.Base(name) == "..data" || slices.Contains(w.files, filepath.Clean(name))
+}
@@ -0,0 +1,47 @@
+package filewatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherNoticesAtomicReplacements(t *testing.T) {
+	dir := t.TempDir()
+	watched := filepath.Join(dir, "tls.crt")
+	if err := os.WriteFile(watched, []byte("v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan struct{}, 16)
+	watcher, err := New([]string{watched, ""}, func() { changed <- struct{}{} }, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { watcher.Close() })
+
+	// Files of the directory that are not watched are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "other"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+		t.Fatal("change notified for a file not watched")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The watched file is replaced by a rename, as renewals usually do.
+	staged := filepath.Join(dir, "tls.crt.tmp")
+	if err := os.WriteFile(staged, []byte("v2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(staged, watched); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("replacement of the watched file not notified")
+	}
+}
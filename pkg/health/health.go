@@ -0,0 +1,130 @@
+// Package health aggregates the readiness checks served by the admin listener.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// checkTimeout bounds every check so a hanging dependency cannot block the probe.
+const checkTimeout = 2 * time.Second
+
+type (
+	// Check reports whether a dependency is usable; a nil error means healthy.
+	Check func(ctx context.Context) error
+
+	Checker struct {
+		mu            sync.RWMutex
+		names         []string
+		checks        map[string]Check
+		informational map[string]bool
+		draining      atomic.Bool
+	}
+
+	Report struct {
+		Status string                 `json:"status"`
+		Checks map[string]CheckResult `json:"checks,omitempty"`
+	}
+
+	CheckResult struct {
+		Status        string  `json:"status"`
+		Error         string  `json:"error,omitempty"`
+		DurationMs    float64 `json:"duration_ms"`
+		Informational bool    `json:"informational,omitempty"` // The check does not affect the report status.
+	}
+)
+
+func NewChecker() *Checker {
+	return &Checker{checks: map[string]Check{}, informational: map[string]bool{}}
+}
+
+// Add registers check under name, replacing a previous check with the same name.
+func (c *Checker) Add(name string, check Check) {
+	c.add(name, check, false)
+}
+
+// AddInformational registers a check that is reported but never makes the
+// report down, for dependencies the server can work without.
+func (c *Checker) AddInformational(name string, check Check) {
+	c.add(name, check, true)
+}
+
+func (c *Checker) add(name string, check Check, informational bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+	c.informational[name] = informational
+}
+
+// SetDraining makes every following report down, so the load balancers stop
+// sending requests before the listener closes.
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Run runs every check concurrently. The report is down when any check not
+// informational fails or once SetDraining was called.
+func (c *Checker) Run(ctx context.Context) *Report {
+	if c.draining.Load() {
+		return &Report{
+			Status: StatusDown,
+			Checks: map[string]CheckResult{"shutdown": {Status: StatusDown, Error: ErrShuttingDown.Error()}},
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	report := &Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(c.names))}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+
+	for _, name := range c.names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+
+			informational := c.informational[name]
+			result := CheckResult{Status: StatusUp, DurationMs: float64(time.Since(start).Microseconds()) / 1000, Informational: informational}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[name] = result
+			if err != nil && !informational {
+				report.Status = StatusDown
+			}
+		}(name, c.checks[name])
+	}
+
+	wg.Wait()
+
+	return report
+}
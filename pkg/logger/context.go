@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"context"
+	"maps"
+	"sync"
+
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// requestFields accumulates the fields of a request as they become known
+	// (request ID first, then merchant, terminal, STAN...). It is stored by
+	// pointer in the context so fields added deep in the call stack are seen by
+	// every later log entry of the request.
+	requestFields struct {
+		mu     sync.RWMutex
+		fields logrus.Fields
+	}
+
+	requestFieldsCtxKey struct{}
+)
+
+// WithFields returns a copy of ctx carrying a new field set initialised with
+// fields. It is called once per request; use AddFields afterwards.
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return context.WithValue(ctx, requestFieldsCtxKey{}, &requestFields{fields: maps.Clone(fields)})
+}
+
+// AddFields adds fields to the field set of ctx. It is a no-op when ctx was not
+// prepared with WithFields.
+func AddFields(ctx context.Context, fields logrus.Fields) {
+	holder, ok := ctx.Value(requestFieldsCtxKey{}).(*requestFields)
+	if !ok {
+		return
+	}
+
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+
+	maps.Copy(holder.fields, fields)
+}
+
+// FromContext returns an entry carrying the request fields of ctx, the chi route
+// pattern once the request was routed, and ctx itself so hooks such as the
+// TraceHook can read it.
+func FromContext(ctx context.Context) *logrus.Entry {
+	return fromLogger(ctx, logrus.StandardLogger())
+}
+
+func fromLogger(ctx context.Context, l *logrus.Logger) *logrus.Entry {
+	entry := l.WithContext(ctx)
+
+	if holder, ok := ctx.Value(requestFieldsCtxKey{}).(*requestFields); ok {
+		holder.mu.RLock()
+		entry = entry.WithFields(holder.fields)
+		holder.mu.RUnlock()
+	}
+
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			entry = entry.WithField("route", pattern)
+		}
+	}
+
+	return entry
+}
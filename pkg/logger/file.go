@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+// RotatingFile is a log file rotated when it reaches a size, every fixed interval,
+// or on demand. Rotated files are renamed with a timestamp, optionally gzipped, and
+// removed once they exceed the configured age or count.
+type RotatingFile struct {
+	*lumberjack.Logger
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// logFile is the file the standard logger writes to, nil when file output is disabled.
+var logFile *RotatingFile
+
+// NewRotatingFile creates the file described by cfg. When cfg.RotateEvery is set,
+// it is also rotated at every multiple of that interval (since the zero time, so
+// 24h rotates at midnight UTC) until Close is called.
+func NewRotatingFile(cfg configs.LogFileConfig) *RotatingFile {
+	file := &RotatingFile{
+		Logger: &lumberjack.Logger{
+			Filename:   cfg.Path,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxAge:     cfg.MaxAgeDays,
+			MaxBackups: cfg.MaxBackups,
+			Compress:   cfg.Compress,
+		},
+		stop: make(chan struct{}),
+	}
+
+	if cfg.RotateEvery > 0 {
+		go file.rotateEvery(cfg.RotateEvery)
+	}
+
+	return file
+}
+
+func (f *RotatingFile) rotateEvery(interval time.Duration) {
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(interval).Add(interval).Sub(now))
+
+		select {
+		case <-f.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			// Nothing was logged since the last rotation, keep the file.
+			if info, err := os.Stat(f.Filename); err == nil && info.Size() == 0 {
+				continue
+			}
+
+			if err := f.Rotate(); err != nil {
+				FromContext(context.Background()).WithError(err).Error("Failed to rotate log file")
+			}
+		}
+	}
+}
+
+// Reopen closes the file so the next write reopens it by name. It is used after an
+// external tool such as logrotate moved the file away.
+func (f *RotatingFile) Reopen() error {
+	return f.Logger.Close()
+}
+
+// Close stops the time based rotation and closes the file.
+func (f *RotatingFile) Close() error {
+	f.once.Do(func() { close(f.stop) })
+
+	return f.Logger.Close()
+}
+
+// ReopenFile reopens the log file, if any. It is called on SIGUSR1.
+func ReopenFile() error {
+	if logFile == nil {
+		return nil
+	}
+
+	return logFile.Reopen()
+}
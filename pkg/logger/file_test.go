@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+func TestRotatingFileRotateEverySkipsEmptyFiles(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	dir := t.TempDir()
+	file := NewRotatingFile(configs.LogFileConfig{Path: filepath.Join(dir, "server.log"), RotateEvery: interval})
+	t.Cleanup(func() { file.Close() })
+
+	// rotated polls the rotated files until there are want of them or a second passes.
+	rotated := func(want int) int {
+		t.Helper()
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			backups, err := filepath.Glob(filepath.Join(dir, "server-*.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(backups) >= want || time.Now().After(deadline) {
+				return len(backups)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if _, err := file.Write([]byte("first entry\n")); err != nil {
+		t.Fatal(err)
+	}
+	if n := rotated(1); n != 1 {
+		t.Fatalf("%d rotated files after the first interval, want 1", n)
+	}
+
+	// Nothing is written for several intervals, so the empty file is kept.
+	time.Sleep(5 * interval)
+	if n := rotated(0); n != 1 {
+		t.Fatalf("%d rotated files after idle intervals, want still 1", n)
+	}
+
+	if _, err := file.Write([]byte("second entry\n")); err != nil {
+		t.Fatal(err)
+	}
+	if n := rotated(2); n != 2 {
+		t.Fatalf("%d rotated files after writing again, want 2", n)
+	}
+}
@@ -0,0 +1,349 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+// Components whose level can be changed independently of the global level.
+const (
+	ComponentHTTP       = "http"
+	ComponentGateway    = "gateway"
+	ComponentRepository = "repository"
+	ComponentService    = "service"
+)
+
+// Actors recorded in the audit events of level changes.
+const (
+	ActorAdmin  = "admin"
+	ActorSIGHUP = "sighup"
+	ActorTTL    = "ttl"
+	ActorReload = "config_reload"
+)
+
+// Components lists the components accepted by SetLevel and ResetLevel.
+var Components = []string{ComponentHTTP, ComponentGateway, ComponentRepository, ComponentService}
+
+var ErrUnknownComponent = errors.New("unknown log component")
+
+type (
+	// LevelSnapshot is the current global level and the per component overrides.
+	LevelSnapshot struct {
+		Level      string
+		RevertAt   *time.Time
+		Components map[string]ComponentLevel
+	}
+
+	// ComponentLevel is the effective level of a component. Override is false
+	// when the component follows the global level.
+	ComponentLevel struct {
+		Level    string
+		Override bool
+		RevertAt *time.Time
+	}
+
+	// pendingRevert restores a level once the TTL of a change expires. to is nil
+	// when the component had no override before the change.
+	pendingRevert struct {
+		timer *time.Timer
+		at    time.Time
+		to    *logrus.Level
+	}
+
+	// levelRegistry holds one logger per component, sharing the output, formatter
+	// and hooks of the standard logger but with their own level.
+	levelRegistry struct {
+		mu        sync.Mutex
+		overrides map[string]logrus.Level
+		loggers   map[string]*logrus.Logger
+		reverts   map[string]*pendingRevert
+	}
+
+	// stdFormatter and stdWriter forward to the standard logger so component
+	// loggers follow SetupLogger whenever they are created.
+	stdFormatter struct{}
+	stdWriter    struct{}
+)
+
+// globalKey is the key of the global level in the reverts map.
+const globalKey = ""
+
+var (
+	levels = &levelRegistry{
+		overrides: map[string]logrus.Level{},
+		loggers:   map[string]*logrus.Logger{},
+		reverts:   map[string]*pendingRevert{},
+	}
+
+	// auditLogger always logs at info level so level changes are recorded even
+	// when they raise the global level above it.
+	auditLogger = newStdLogger(logrus.InfoLevel)
+)
+
+func (stdFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return logrus.StandardLogger().Formatter.Format(entry)
+}
+
+func (stdWriter) Write(p []byte) (int, error) {
+	return logrus.StandardLogger().Out.Write(p)
+}
+
+func newStdLogger(level logrus.Level) *logrus.Logger {
+	std := logrus.StandardLogger()
+
+	return &logrus.Logger{
+		Out:          stdWriter{},
+		Formatter:    stdFormatter{},
+		Hooks:        std.Hooks,
+		Level:        level,
+		ExitFunc:     std.ExitFunc,
+		ReportCaller: std.ReportCaller,
+	}
+}
+
+// Component returns the entry of FromContext bound to the logger of component, so
+// it is filtered with the component level, and tagged with a component field.
+func Component(ctx context.Context, component string) *logrus.Entry {
+	return fromLogger(ctx, levels.logger(component)).WithField("component", component)
+}
+
+func (r *levelRegistry) logger(component string) *logrus.Logger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.loggers[component]
+	if !ok {
+		l = newStdLogger(r.effectiveLocked(component))
+		r.loggers[component] = l
+	}
+
+	return l
+}
+
+func (r *levelRegistry) effectiveLocked(component string) logrus.Level {
+	if level, ok := r.overrides[component]; ok {
+		return level
+	}
+
+	return logrus.GetLevel()
+}
+
+// syncLevels propagates a global level set outside SetLevel to the component loggers.
+func syncLevels() {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	levels.applyLocked()
+}
+
+// applyLocked propagates the global level and the overrides to the component loggers.
+func (r *levelRegistry) applyLocked() {
+	for component, l := range r.loggers {
+		l.SetLevel(r.effectiveLocked(component))
+	}
+}
+
+// SetLevel changes the global level, when component is empty, or the level of a
+// component. With a positive ttl the previous level is restored once it expires;
+// successive changes before that keep restoring the level that preceded the first one.
+// The change is logged as an audit event attributed to actor.
+func SetLevel(component string, level logrus.Level, ttl time.Duration, actor string) error {
+	if component != globalKey && !slices.Contains(Components, component) {
+		return fmt.Errorf("%w %q, use one of %s", ErrUnknownComponent, component, strings.Join(Components, ", "))
+	}
+
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	from := levels.describeLocked(component)
+	previous := levels.currentLocked(component)
+
+	pending, hasPending := levels.reverts[component]
+	if hasPending {
+		pending.timer.Stop()
+		delete(levels.reverts, component)
+		previous = pending.to
+	}
+
+	levels.setLocked(component, &level)
+
+	if ttl > 0 {
+		pending := &pendingRevert{at: time.Now().Add(ttl), to: previous}
+		pending.timer = time.AfterFunc(ttl, func() { levels.revert(component, pending) })
+		levels.reverts[component] = pending
+	}
+
+	audit(component, from, level.String(), ttl, actor)
+
+	return nil
+}
+
+// ResetLevel removes the override of component so it follows the global level again.
+func ResetLevel(component string, actor string) error {
+	if !slices.Contains(Components, component) {
+		return fmt.Errorf("%w %q, use one of %s", ErrUnknownComponent, component, strings.Join(Components, ", "))
+	}
+
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	if pending, ok := levels.reverts[component]; ok {
+		pending.timer.Stop()
+		delete(levels.reverts, component)
+	}
+
+	from := levels.describeLocked(component)
+	levels.setLocked(component, nil)
+
+	audit(component, from, levels.describeLocked(component), 0, actor)
+
+	return nil
+}
+
+// ReloadLevel re-reads the properties file and applies its logLevel globally.
+// It is called on SIGHUP.
+func ReloadLevel() error {
+	value, err := configs.ReadLogLevel()
+	if err != nil {
+		return err
+	}
+
+	level, err := logrus.ParseLevel(value)
+	if err != nil {
+		return err
+	}
+
+	return SetLevel(globalKey, level, 0, ActorSIGHUP)
+}
+
+// applyReloadedLevel applies the logLevel of a configuration reload globally.
+func applyReloadedLevel(change configs.Change) {
+	if !change.Changed("logLevel") {
+		return
+	}
+
+	// The reloaded configuration was validated, the level parses.
+	level, _ := logrus.ParseLevel(change.Current.LogLevel)
+	_ = SetLevel(globalKey, level, 0, ActorReload)
+}
+
+// Levels returns the current global level and the level of every component.
+func Levels() LevelSnapshot {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	snapshot := LevelSnapshot{
+		Level:      logrus.GetLevel().String(),
+		RevertAt:   levels.revertAtLocked(globalKey),
+		Components: make(map[string]ComponentLevel, len(Components)),
+	}
+
+	for _, component := range Components {
+		_, override := levels.overrides[component]
+		snapshot.Components[component] = ComponentLevel{
+			Level:    levels.effectiveLocked(component).String(),
+			Override: override,
+			RevertAt: levels.revertAtLocked(component),
+		}
+	}
+
+	return snapshot
+}
+
+// revert applies pending unless it was superseded by a later change while its
+// timer was firing.
+func (r *levelRegistry) revert(component string, pending *pendingRevert) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reverts[component] != pending {
+		return
+	}
+	delete(r.reverts, component)
+
+	from := r.describeLocked(component)
+	r.setLocked(component, pending.to)
+
+	audit(component, from, r.describeLocked(component), 0, ActorTTL)
+}
+
+// currentLocked returns the level set for component, nil when it has no override.
+func (r *levelRegistry) currentLocked(component string) *logrus.Level {
+	if component == globalKey {
+		level := logrus.GetLevel()
+		return &level
+	}
+
+	level, ok := r.overrides[component]
+	if !ok {
+		return nil
+	}
+
+	return &level
+}
+
+// setLocked sets the level of component; a nil level removes its override.
+func (r *levelRegistry) setLocked(component string, level *logrus.Level) {
+	switch {
+	case component == globalKey:
+		logrus.SetLevel(*level)
+	case level == nil:
+		delete(r.overrides, component)
+	default:
+		r.overrides[component] = *level
+	}
+
+	r.applyLocked()
+}
+
+func (r *levelRegistry) describeLocked(component string) string {
+	if component == globalKey {
+		return logrus.GetLevel().String()
+	}
+
+	if level, ok := r.overrides[component]; ok {
+		return level.String()
+	}
+
+	return "inherit (" + logrus.GetLevel().String() + ")"
+}
+
+func (r *levelRegistry) revertAtLocked(component string) *time.Time {
+	pending, ok := r.reverts[component]
+	if !ok {
+		return nil
+	}
+
+	at := pending.at
+	return &at
+}
+
+func audit(component, from, to string, ttl time.Duration, actor string) {
+	if component == globalKey {
+		component = "global"
+	}
+
+	entry := auditLogger.WithFields(logrus.Fields{
+		"audit":     true,
+		"event":     "log_level_changed",
+		"component": component,
+		"from":      from,
+		"to":        to,
+		"actor":     actor,
+	})
+
+	if ttl > 0 {
+		entry = entry.WithField("ttl", ttl.String())
+	}
+
+	entry.Info("log level changed")
+}
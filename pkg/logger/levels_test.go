@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// resetLevels restores the info global level and drops every override and
+// pending revert once the test ends.
+func resetLevels(t *testing.T) {
+	t.Helper()
+
+	logrus.SetLevel(logrus.InfoLevel)
+	t.Cleanup(func() {
+		for _, component := range Components {
+			ResetLevel(component, ActorAdmin)
+		}
+		SetLevel(globalKey, logrus.InfoLevel, 0, ActorAdmin)
+	})
+}
+
+// waitForLevels polls Levels until ok accepts the snapshot or a second passes.
+func waitForLevels(t *testing.T, ok func(LevelSnapshot) bool) LevelSnapshot {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		snapshot := Levels()
+		if ok(snapshot) || time.Now().After(deadline) {
+			return snapshot
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSetLevelWithTTLReverts(t *testing.T) {
+	t.Run("global", func(t *testing.T) {
+		resetLevels(t)
+
+		if err := SetLevel(globalKey, logrus.DebugLevel, 50*time.Millisecond, ActorAdmin); err != nil {
+			t.Fatal(err)
+		}
+		// A second change before the TTL expires still reverts to info.
+		if err := SetLevel(globalKey, logrus.TraceLevel, 50*time.Millisecond, ActorAdmin); err != nil {
+			t.Fatal(err)
+		}
+
+		snapshot := Levels()
+		if snapshot.Level != "trace" || snapshot.RevertAt == nil {
+			t.Fatalf("level %s reverting at %v, want trace with a revert pending", snapshot.Level, snapshot.RevertAt)
+		}
+		if level := Component(context.Background(), ComponentHTTP).Logger.GetLevel(); level != logrus.TraceLevel {
+			t.Fatalf("http component at %s, want the global trace", level)
+		}
+
+		snapshot = waitForLevels(t, func(s LevelSnapshot) bool { return s.RevertAt == nil })
+		if snapshot.Level != "info" || snapshot.RevertAt != nil {
+			t.Fatalf("level %s reverting at %v after the ttl, want info with no revert pending", snapshot.Level, snapshot.RevertAt)
+		}
+		if level := Component(context.Background(), ComponentHTTP).Logger.GetLevel(); level != logrus.InfoLevel {
+			t.Fatalf("http component at %s after the ttl, want the global info", level)
+		}
+	})
+
+	t.Run("component without a previous override", func(t *testing.T) {
+		resetLevels(t)
+
+		if err := SetLevel(ComponentGateway, logrus.DebugLevel, 50*time.Millisecond, ActorAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if gateway := Levels().Components[ComponentGateway]; gateway.Level != "debug" || !gateway.Override || gateway.RevertAt == nil {
+			t.Fatalf("gateway %+v, want a debug override with a revert pending", gateway)
+		}
+
+		snapshot := waitForLevels(t, func(s LevelSnapshot) bool { return s.Components[ComponentGateway].RevertAt == nil })
+		if gateway := snapshot.Components[ComponentGateway]; gateway.Level != "info" || gateway.Override {
+			t.Fatalf("gateway %+v after the ttl, want it to follow the global info again", gateway)
+		}
+	})
+
+	t.Run("component with a previous override", func(t *testing.T) {
+		resetLevels(t)
+
+		if err := SetLevel(ComponentGateway, logrus.WarnLevel, 0, ActorAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetLevel(ComponentGateway, logrus.DebugLevel, 50*time.Millisecond, ActorAdmin); err != nil {
+			t.Fatal(err)
+		}
+
+		snapshot := waitForLevels(t, func(s LevelSnapshot) bool { return s.Components[ComponentGateway].RevertAt == nil })
+		if gateway := snapshot.Components[ComponentGateway]; gateway.Level != "warning" || !gateway.Override {
+			t.Fatalf("gateway %+v after the ttl, want the warning override back", gateway)
+		}
+	})
+}
+
+func TestResetLevel(t *testing.T) {
+	resetLevels(t)
+
+	if err := SetLevel(ComponentHTTP, logrus.WarnLevel, 0, ActorAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if level := Component(context.Background(), ComponentHTTP).Logger.GetLevel(); level != logrus.WarnLevel {
+		t.Fatalf("http component at %s, want warning", level)
+	}
+
+	if err := ResetLevel(ComponentHTTP, ActorAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if http := Levels().Components[ComponentHTTP]; http.Level != "info" || http.Override {
+		t.Fatalf("http %+v after the reset, want it to follow the global info", http)
+	}
+	if level := Component(context.Background(), ComponentHTTP).Logger.GetLevel(); level != logrus.InfoLevel {
+		t.Fatalf("http component at %s after the reset, want info", level)
+	}
+
+	// A reset cancels the pending revert of a change with a TTL.
+	if err := SetLevel(ComponentHTTP, logrus.DebugLevel, 0, ActorAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLevel(ComponentHTTP, logrus.TraceLevel, 20*time.Millisecond, ActorAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := ResetLevel(ComponentHTTP, ActorAdmin); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if http := Levels().Components[ComponentHTTP]; http.Override || http.RevertAt != nil {
+		t.Fatalf("http %+v once the ttl passed, want no override", http)
+	}
+
+	if err := ResetLevel("scheduler", ActorAdmin); !errors.Is(err, ErrUnknownComponent) {
+		t.Fatalf("unknown component: err = %v, want %v", err, ErrUnknownComponent)
+	}
+}
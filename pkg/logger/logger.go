@@ -10,6 +10,10 @@
 package logger
 
 import (
+	"context"
+	"errors"
+	"io"
+	"os"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -37,7 +41,17 @@ var formatterEnvMap = map[string]logrus.Formatter{
 // The function selects the appropriate log formatter based on the environment. If no formatter
 // is found for the specified environment, it falls back to the standard formatter. Additionally,
 // it parses the log level and sets it accordingly, defaulting to INFO level if the provided level
-// is invalid. If timezone logging is enabled, it adds a timezone hook to the logger.
+// is invalid. If timezone logging is enabled, it adds a timezone hook to the logger. The trace
+// hook is always added so entries logged with a context carry the trace and span IDs.
+//
+// If `logFile.enabled` is set, the output is a RotatingFile, alongside stderr when
+// `logFile.console` is set.
+//
+// If the log level hook is enabled, entries at least as severe as `logHook.level` are also
+// forwarded to the sink configured in `logHook` (see NewSinkHook). The returned FlushFunc
+// flushes that sink, closes the log file and must be called before the process exits.
+//
+// A reload of `logLevel` in the properties file is applied globally, like SIGHUP.
 //
 // This function logs the final logging level set for the application.
 //
@@ -48,7 +62,7 @@ var formatterEnvMap = map[string]logrus.Formatter{
 // If no formatter is found for the environment, a warning is logged, and the standard formatter is used.
 //
 // If the log level is invalid, a warning is logged, and the default level INFO is used.
-func SetupLogger(envs *configs.EnvVars) {
+func SetupLogger(ctx context.Context, envs *configs.EnvVars) (FlushFunc, error) {
 	formatter, ok := formatterEnvMap[envs.Env]
 	if !ok {
 		logrus.Warnf("No formatter found for environment '%s'. Falling back to standard formatter", envs.Env)
@@ -57,6 +71,18 @@ func SetupLogger(envs *configs.EnvVars) {
 
 	logrus.SetFormatter(formatter)
 
+	closeFile := func() error { return nil }
+	if envs.LogFile.Enabled {
+		logFile = NewRotatingFile(envs.LogFile)
+		closeFile = logFile.Close
+
+		if envs.LogFile.Console {
+			logrus.SetOutput(io.MultiWriter(os.Stderr, logFile))
+		} else {
+			logrus.SetOutput(logFile)
+		}
+	}
+
 	logLevel, err := logrus.ParseLevel(envs.LogLevel)
 	if err != nil {
 		logrus.WithError(err).Warn("Failed to parse log level. Falling back to INFO level")
@@ -64,10 +90,38 @@ func SetupLogger(envs *configs.EnvVars) {
 	}
 
 	logrus.SetLevel(logLevel)
+	syncLevels()
+	configs.Subscribe(applyReloadedLevel)
 
 	if envs.UseTimezoneLogHook {
 		logrus.AddHook(NewTimezoneHook(envs.Timezone))
 	}
 
+	logrus.AddHook(NewTraceHook())
+
 	logrus.Infof("Logging level set to %s", strings.ToUpper(logLevel.String()))
+
+	if envs.LogFile.Enabled {
+		logrus.Infof("Writing logs to %s", envs.LogFile.Path)
+	}
+
+	if !envs.UseLogLevelHook {
+		return func(context.Context) error { return closeFile() }, nil
+	}
+
+	hookLevel, err := logrus.ParseLevel(envs.LogHook.Level)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to parse log hook level. Falling back to WARN level")
+		hookLevel = logrus.WarnLevel
+	}
+
+	sink, flushSink, err := NewSinkHook(ctx, envs)
+	if err != nil {
+		return nil, errors.Join(err, closeFile())
+	}
+
+	logrus.AddHook(NewLogLevelFilterHook(sink, hookLevel))
+	logrus.Infof("Forwarding %s and more severe entries to the %s sink", strings.ToUpper(hookLevel.String()), envs.LogHook.Sink)
+
+	return func(ctx context.Context) error { return errors.Join(flushSink(ctx), closeFile()) }, nil
 }
@@ -2,7 +2,7 @@ package logger
 
 import "github.com/sirupsen/logrus"
 
-// CustomHook wraps the otellogrus Hook to add log level filtering
+// LogLeveFilterlHook wraps a forwarding hook (file, syslog or otellogrus) to add log level filtering
 type LogLeveFilterlHook struct {
 	levelsAllowed    []logrus.Level
 	levelsAllowedMap map[logrus.Level]bool
@@ -13,23 +13,23 @@ type LogLeveFilterlHook struct {
 //
 // @Arg<inner>: Receive the logrus default hooks and the
 //
-// @Arg<level>: Will be the level that will be filtered, all log levels grater or equal than the
-// this arg will be sent the others will be ignored
+// @Arg<level>: Will be the level that will be filtered, all log levels as or more severe than
+// this arg (down to PanicLevel) will be sent the others will be ignored
 func NewLogLevelFilterHook(inner logrus.Hook, level logrus.Level) *LogLeveFilterlHook {
-	graterOrEqual := uint32(level)
 	levelsAllowed := []logrus.Level{}
 	levelsAllowedMap := map[logrus.Level]bool{}
 
-	for i := graterOrEqual; i != 0; i-- {
-		l := logrus.Level(i)
-		levelsAllowed = append(levelsAllowed, l)
-		levelsAllowedMap[l] = true
+	for _, l := range logrus.AllLevels {
+		if l <= level {
+			levelsAllowed = append(levelsAllowed, l)
+			levelsAllowedMap[l] = true
+		}
 	}
 
 	return &LogLeveFilterlHook{levelsAllowed, levelsAllowedMap, inner}
 }
 
-// Levels filters log levels; it only returns levels >= logrus.WarnLevel
+// Levels filters log levels; it only returns the levels at least as severe as the configured one
 func (c *LogLeveFilterlHook) Levels() []logrus.Level {
 	return c.levelsAllowed
 }
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// recordingHook keeps the levels of the entries fired.
+type recordingHook struct {
+	fired []logrus.Level
+}
+
+func (h *recordingHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *recordingHook) Fire(entry *logrus.Entry) error {
+	h.fired = append(h.fired, entry.Level)
+	return nil
+}
+
+func TestLogLevelFilterHook(t *testing.T) {
+	tests := []struct {
+		level logrus.Level
+		want  []logrus.Level
+	}{
+		{level: logrus.PanicLevel, want: []logrus.Level{logrus.PanicLevel}},
+		{level: logrus.ErrorLevel, want: []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}},
+		{level: logrus.WarnLevel, want: []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel}},
+		{level: logrus.TraceLevel, want: logrus.AllLevels},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			inner := &recordingHook{}
+			hook := NewLogLevelFilterHook(inner, tt.level)
+
+			if got := hook.Levels(); !slices.Equal(got, tt.want) {
+				t.Fatalf("Levels() = %v, want %v", got, tt.want)
+			}
+
+			for _, level := range logrus.AllLevels {
+				if err := hook.Fire(&logrus.Entry{Level: level}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !slices.Equal(inner.fired, tt.want) {
+				t.Fatalf("forwarded %v, want %v", inner.fired, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	lsyslog "github.com/sirupsen/logrus/hooks/syslog"
+	"go.opentelemetry.io/contrib/bridges/otellogrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+const (
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+	SinkOTLP   = "otlp"
+)
+
+// FlushFunc flushes and closes the resources held by a log sink.
+type FlushFunc func(ctx context.Context) error
+
+// WriterHook writes every entry it receives to a writer, formatted as JSON.
+type WriterHook struct {
+	mu        sync.Mutex
+	writer    io.Writer
+	formatter logrus.Formatter
+}
+
+// NewWriterHook creates a new WriterHook instance writing JSON entries to w.
+func NewWriterHook(w io.Writer) *WriterHook {
+	return &WriterHook{writer: w, formatter: &logrus.JSONFormatter{}}
+}
+
+// Fire formats the entry and writes it to the underlying writer.
+func (hook *WriterHook) Fire(entry *logrus.Entry) error {
+	line, err := hook.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+
+	_, err = hook.writer.Write(line)
+	return err
+}
+
+// Levels returns all log levels; filtering is left to the LogLevelFilterHook wrapping it.
+func (hook *WriterHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// NewSinkHook creates the hook forwarding entries to the sink selected by
+// envs.LogHook.Sink:
+//
+//   - file: JSON lines appended to LogHook.File.
+//   - syslog: the syslog daemon at LogHook.SyslogNetwork/LogHook.SyslogAddress, or the
+//     local one when both are empty.
+//   - otlp: an OpenTelemetry collector at LogHook.OTLPEndpoint (OTLP/HTTP).
+//
+// The returned FlushFunc must be called before the process exits.
+func NewSinkHook(ctx context.Context, envs *configs.EnvVars) (logrus.Hook, FlushFunc, error) {
+	cfg := envs.LogHook
+
+	switch cfg.Sink {
+	case SinkFile:
+		if err := os.MkdirAll(filepath.Dir(cfg.File), 0o750); err != nil {
+			return nil, nil, err
+		}
+
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return NewWriterHook(file), func(context.Context) error { return file.Close() }, nil
+	case SinkSyslog:
+		hook, err := lsyslog.NewSyslogHook(cfg.SyslogNetwork, cfg.SyslogAddress, syslog.LOG_INFO|syslog.LOG_DAEMON, envs.AppName)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return hook, func(context.Context) error { return hook.Writer.Close() }, nil
+	case SinkOTLP:
+		exporter, err := otlploghttp.New(ctx, otlploghttp.WithEndpointURL(cfg.OTLPEndpoint))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		provider := sdklog.NewLoggerProvider(
+			sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+			sdklog.WithResource(resource.NewSchemaless(
+				attribute.String("service.name", envs.AppName),
+				attribute.String("deployment.environment", envs.Env),
+			)),
+		)
+
+		return otellogrus.NewHook(envs.AppName, otellogrus.WithLoggerProvider(provider)), provider.Shutdown, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown logHook.sink %q, use file, syslog or otlp", cfg.Sink)
+	}
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceHook adds the trace and span IDs of the active span to entries logged
+// with a context (logrus.WithContext).
+type TraceHook struct{}
+
+// NewTraceHook creates a new TraceHook instance.
+func NewTraceHook() *TraceHook {
+	return &TraceHook{}
+}
+
+// Fire copies the span context of entry.Context, when there is one, into the entry fields.
+func (hook *TraceHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+
+	spanContext := trace.SpanContextFromContext(entry.Context)
+	if !spanContext.IsValid() {
+		return nil
+	}
+
+	entry.Data["trace_id"] = spanContext.TraceID().String()
+	entry.Data["span_id"] = spanContext.SpanID().String()
+
+	return nil
+}
+
+// Levels returns all log levels, every entry may belong to a trace.
+func (hook *TraceHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
@@ -0,0 +1,125 @@
+// Package metrics defines the Prometheus collectors of the server and the
+// handler exposing them.
+//
+// Every collector is registered in Registry, a dedicated registry instead of
+// the global default one, so only the metrics defined here (plus the Go runtime
+// and process collectors) are exported.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "payments"
+
+// gatewayBuckets cover acquirer response times, which are much slower than the HTTP defaults assume.
+var gatewayBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60}
+
+var (
+	Registry = prometheus.NewRegistry()
+
+	// HTTPRequests counts handled requests. The route label is the chi route
+	// pattern, never the raw path, to keep cardinality bounded.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests handled, by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+
+	HTTPShed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_shed_total",
+		Help:      "HTTP requests rejected with 503 because the in-flight limit was reached.",
+	})
+
+	GatewayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "round_trip_seconds",
+		Help:      "Gateway round trip latency, by gateway, request MTI and outcome (ok, error, timeout).",
+		Buckets:   gatewayBuckets,
+	}, []string{"gateway", "mti", "outcome"})
+
+	GatewayConnectionsOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "pool_connections_open",
+		Help:      "Connections currently open, by gateway.",
+	}, []string{"gateway"})
+
+	GatewayConnectionsIdle = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "pool_connections_idle",
+		Help:      "Open connections waiting to be reused, by gateway.",
+	}, []string{"gateway"})
+
+	GatewayCircuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state of each gateway: 0 closed, 1 half-open, 2 open.",
+	}, []string{"gateway"})
+
+	ResponseCodes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "response_codes_total",
+		Help:      "ISO 8583 response codes returned by the gateway, by operation.",
+	}, []string{"operation", "response_code"})
+
+	Reversals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reversals_total",
+		Help:      "Reversals sent to the gateway, by origin.",
+	}, []string{"origin"})
+
+	Batches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batches_total",
+		Help:      "Batches closed, by gateway and outcome.",
+	}, []string{"gateway", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		HTTPInFlight,
+		HTTPShed,
+		GatewayDuration,
+		GatewayConnectionsOpen,
+		GatewayConnectionsIdle,
+		GatewayCircuitState,
+		ResponseCodes,
+		Reversals,
+		Batches,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
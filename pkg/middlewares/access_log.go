@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+// AccessLog writes one structured entry per request through the configured
+// logrus formatter, replacing chi's text based middleware.Logger. It must run
+// after RequestLogger so the entry carries the request fields, including the
+// merchant added later by the authentication middlewares.
+//
+// 5xx responses are logged at error level, 4xx at warning level and the rest at
+// info level; successful requests are sampled with cfg.SuccessSampleRate, which
+// follows the reloads of accessLog.
+func AccessLog(cfg configs.AccessLogConfig) func(next http.Handler) http.Handler {
+	var sampleRate atomic.Uint64
+	sampleRate.Store(math.Float64bits(cfg.SuccessSampleRate))
+
+	configs.Subscribe(func(change configs.Change) {
+		if change.Changed("accessLog") {
+			sampleRate.Store(math.Float64bits(change.Current.AccessLog.SuccessSampleRate))
+		}
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			status := max(ww.Status(), http.StatusOK)
+			if status < http.StatusMultipleChoices && rand.Float64() >= math.Float64frombits(sampleRate.Load()) {
+				return
+			}
+
+			level := logrus.InfoLevel
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = logrus.ErrorLevel
+			case status >= http.StatusBadRequest:
+				level = logrus.WarnLevel
+			}
+
+			logger.Component(r.Context(), logger.ComponentHTTP).WithFields(logrus.Fields{
+				"method":      r.Method,
+				"route":       RoutePattern(r),
+				"path":        r.URL.Path,
+				"proto":       r.Proto,
+				"status":      status,
+				"bytes":       ww.BytesWritten(),
+				"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+				"client_ip":   clientIP(r),
+				"user_agent":  r.UserAgent(),
+			}).Log(level, "http request")
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+)
+
+// AdminAuth protects the administrative routes with a bearer token. When no token
+// is configured every request is rejected, so admin routes are never accidentally
+// left open. A rotated adminToken applies to the next requests.
+func AdminAuth(secret configs.Secret) func(next http.Handler) http.Handler {
+	var current atomic.Pointer[configs.Secret]
+	current.Store(&secret)
+
+	configs.Subscribe(func(change configs.Change) {
+		if change.Changed("adminToken") {
+			current.Store(&change.Current.AdminToken)
+		}
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := current.Load().Value()
+			scheme, presented, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+			if token == "" || !ok || !strings.EqualFold(scheme, "Bearer") ||
+				subtle.ConstantTimeCompare([]byte(strings.TrimSpace(presented)), []byte(token)) != 1 {
+				controllers.NewResponseBuilder(w).Unauthorized().Build()
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Package middlewares contains the chi middlewares applied to the HTTP routes.
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/internal/services"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+// APIKeyHeader is the alternative to the Authorization bearer header for sending the API key.
+const APIKeyHeader = "X-Api-Key"
+
+// APIKeyAuth authenticates the caller with the API key sent either as
+// "Authorization: Bearer <key>" or in the X-Api-Key header, and binds the
+// owning merchant to the request context. Requests already authenticated by a
+// client certificate are passed through.
+func APIKeyAuth(service services.APIKeyService) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := auth.PrincipalFromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rawKey := extractAPIKey(r)
+			if rawKey == "" {
+				controllers.NewResponseBuilder(w).Unauthorized().ErrMessage("missing api key").Build()
+				return
+			}
+
+			key, err := service.Authenticate(r.Context(), rawKey)
+			if err != nil {
+				logger.Component(r.Context(), logger.ComponentHTTP).WithError(err).Debug("api key authentication failed")
+				controllers.NewResponseBuilder(w).Error(err).Build()
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), &auth.Principal{MerchantID: key.MerchantID, KeyID: key.ID})
+			logger.AddFields(ctx, logrus.Fields{"merchant_id": key.MerchantID, "key_id": key.ID})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func extractAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
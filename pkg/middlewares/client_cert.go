@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+// ClientCertAuth authenticates requests arriving with a verified client
+// certificate whose subject common name is mapped to a merchant. Requests
+// without a mapped certificate pass through untouched so APIKeyAuth can
+// authenticate them instead.
+func ClientCertAuth(mappings []configs.ClientCertMerchant) func(next http.Handler) http.Handler {
+	merchants := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		merchants[mapping.Subject] = mapping.MerchantID
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(merchants) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			subject := r.TLS.VerifiedChains[0][0].Subject.CommonName
+			merchantID, ok := merchants[subject]
+			if !ok {
+				logger.Component(r.Context(), logger.ComponentHTTP).WithField("subject", subject).Debug("client certificate not mapped to a merchant")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), &auth.Principal{MerchantID: merchantID})
+			logger.AddFields(ctx, logrus.Fields{"merchant_id": merchantID, "client_cert": subject})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+)
+
+// MaxBodySize rejects with 413 the requests whose body exceeds limit bytes. Bodies
+// of unknown length are buffered up to the limit, so the handlers always get a
+// complete body. A limit of 0 disables the check.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				controllers.NewResponseBuilder(w).PayloadTooLarge().ErrMessage(fmt.Sprintf("request body exceeds %d bytes", limit)).Build()
+				return
+			}
+
+			if r.ContentLength < 0 {
+				body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+				if err != nil {
+					controllers.NewResponseBuilder(w).UnformattedBody().Build()
+					return
+				}
+
+				if int64(len(body)) > limit {
+					controllers.NewResponseBuilder(w).PayloadTooLarge().ErrMessage(fmt.Sprintf("request body exceeds %d bytes", limit)).Build()
+					return
+				}
+
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				r.ContentLength = int64(len(body))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxInFlight sheds load once limit requests are being served, answering 503
+// with Retry-After instead of queueing. A limit of 0 disables the check.
+func MaxInFlight(limit int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+
+		slots := make(chan struct{}, limit)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				next.ServeHTTP(w, r)
+			default:
+				metrics.HTTPShed.Inc()
+				logger.Component(r.Context(), logger.ComponentHTTP).WithField("limit", limit).Warn("in-flight limit reached, shedding request")
+
+				controllers.NewResponseBuilder(w).
+					ServiceUnavailable().
+					Headers(map[string]string{"Retry-After": "1"}).
+					ErrMessage("server busy, retry later").
+					Build()
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int64
+		body       string
+		chunked    bool
+		wantStatus int
+	}{
+		{name: "within the limit", limit: 16, body: `{"amount":"10"}`, wantStatus: http.StatusOK},
+		{name: "at the limit", limit: 16, body: strings.Repeat("a", 16), wantStatus: http.StatusOK},
+		{name: "declared length above the limit", limit: 16, body: strings.Repeat("a", 17), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked within the limit", limit: 16, body: `{"amount":"10"}`, chunked: true, wantStatus: http.StatusOK},
+		{name: "chunked above the limit", limit: 16, body: strings.Repeat("a", 17), chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "no limit", body: strings.Repeat("a", 1<<16), wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := MaxBodySize(tt.limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(body)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/payments/sale", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && got != tt.body {
+				t.Fatalf("handler read %d bytes, want the %d sent", len(got), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	const limit = 2
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := MaxInFlight(limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var wg sync.WaitGroup
+	statuses := make([]int, limit)
+	for i := range limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/payments", nil))
+			statuses[i] = rec.Code
+		}()
+		<-entered
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/payments", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("request above the limit: status %d with Retry-After %q, want %d with %q", rec.Code, rec.Header().Get("Retry-After"), http.StatusServiceUnavailable, "1")
+	}
+
+	close(release)
+	wg.Wait()
+	for i, status := range statuses {
+		if status != http.StatusOK {
+			t.Fatalf("request %d within the limit: status %d, want %d", i, status, http.StatusOK)
+		}
+	}
+
+	// The slots are free again once the requests in flight complete.
+	go func() { <-entered }()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/payments", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("request after the others completed: status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
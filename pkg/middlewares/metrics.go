@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+)
+
+// unmatchedRoute labels requests that did not match any route, so scans of
+// random paths cannot create new series.
+const unmatchedRoute = "unmatched"
+
+// Metrics records the request count, latency and in-flight gauge of every
+// request, labelled by the chi route pattern.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		metrics.HTTPInFlight.Inc()
+		defer metrics.HTTPInFlight.Dec()
+
+		next.ServeHTTP(ww, r)
+
+		status := strconv.Itoa(max(ww.Status(), http.StatusOK))
+		route := RoutePattern(r)
+
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RoutePattern returns the chi pattern of the matched route. It is only
+// complete once the router has dispatched the request.
+func RoutePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return unmatchedRoute
+}
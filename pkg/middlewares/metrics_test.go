@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+)
+
+func TestMetricsLabelsByRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Metrics)
+	r.Get("/v1/payments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		route  string
+		status string
+	}{
+		{name: "matched route", path: "/v1/payments/3f9c2a", route: "/v1/payments/{id}", status: "200"},
+		{name: "another id on the same route", path: "/v1/payments/7d01be", route: "/v1/payments/{id}", status: "200"},
+		{name: "unknown path", path: "/wp-login.php", route: unmatchedRoute, status: "404"},
+		{name: "another unknown path", path: "/.env", route: unmatchedRoute, status: "404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := metrics.HTTPRequests.WithLabelValues(http.MethodGet, tt.route, tt.status)
+			before := testutil.ToFloat64(counter)
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Fatalf("requests_total{route=%q,status=%q} grew by %v, want 1", tt.route, tt.status, got)
+			}
+		})
+	}
+
+	// Four paths, but only the route pattern and the unmatched label as series.
+	if n := testutil.CollectAndCount(metrics.HTTPRequests); n != 2 {
+		t.Fatalf("requests_total has %d series, want 2", n)
+	}
+}
@@ -0,0 +1,230 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/ratelimit"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RetryAfterHeader         = "Retry-After"
+)
+
+type (
+	rateLimitBucket struct {
+		key   string
+		limit configs.RateLimit
+	}
+
+	rateLimitCtxKey struct{}
+
+	// rateLimits is the configuration in effect with the overrides indexed by merchant.
+	rateLimits struct {
+		configs.RateLimitConfig
+		overrides map[string]configs.MerchantRateLimit
+	}
+)
+
+// IPRateLimit applies the client IP token bucket to the payment routes. It must
+// run before authentication, so floods of unauthenticated requests are
+// throttled before any API key lookup or signature check. Reloads of rateLimit
+// apply to the next requests.
+func IPRateLimit(cfg configs.RateLimitConfig, store ratelimit.Store) func(next http.Handler) http.Handler {
+	limits := watchRateLimits(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := limits.Load()
+			if !cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reported, ok := takeRateLimits(w, r, store, []rateLimitBucket{{"ip:" + clientIP(r), cfg.IP}}, nil)
+			if !ok {
+				return
+			}
+
+			if reported != nil {
+				r = r.WithContext(context.WithValue(r.Context(), rateLimitCtxKey{}, reported))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimit applies the merchant and terminal token buckets to the payment
+// routes. It must run after authentication so the merchant is known; the
+// terminal is read from the terminal_id of the JSON body.
+//
+// The headers of the most constrained bucket, the client IP one of IPRateLimit
+// included, are reported on every response, and requests exceeding any bucket
+// are answered with 429 and Retry-After.
+//
+// Reloads of rateLimit, including enabling it and the merchant overrides, apply
+// to the next requests.
+func RateLimit(cfg configs.RateLimitConfig, store ratelimit.Store) func(next http.Handler) http.Handler {
+	limits := watchRateLimits(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := limits.Load()
+			if !cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			merchantID := auth.MerchantFromContext(r.Context())
+			if merchantID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			merchantLimit, terminalLimit := cfg.Merchant, cfg.Terminal
+			if override, ok := cfg.overrides[merchantID]; ok {
+				if override.Merchant.Rate > 0 {
+					merchantLimit = override.Merchant
+				}
+				if override.Terminal.Rate > 0 {
+					terminalLimit = override.Terminal
+				}
+			}
+
+			terminalID, err := peekTerminalID(r)
+			if err != nil {
+				controllers.NewResponseBuilder(w).UnformattedBody().Build()
+				return
+			}
+
+			buckets := []rateLimitBucket{{"merchant:" + merchantID, merchantLimit}}
+			if terminalID != "" {
+				buckets = append(buckets, rateLimitBucket{fmt.Sprintf("terminal:%s:%s", merchantID, terminalID), terminalLimit})
+			}
+
+			reported, _ := r.Context().Value(rateLimitCtxKey{}).(*ratelimit.Result)
+			if _, ok := takeRateLimits(w, r, store, buckets, reported); ok {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// takeRateLimits takes a token from every bucket, sets the headers of the most
+// constrained one, reported included, and answers 429 when a bucket has no
+// token left. It returns the bucket reported and whether the request may go on.
+func takeRateLimits(w http.ResponseWriter, r *http.Request, store ratelimit.Store, buckets []rateLimitBucket, reported *ratelimit.Result) (*ratelimit.Result, bool) {
+	var takes []ratelimit.Take
+	for _, bucket := range buckets {
+		if bucket.limit.Rate > 0 {
+			takes = append(takes, ratelimit.Take{Key: bucket.key, Limit: ratelimit.Limit{Rate: bucket.limit.Rate, Burst: bucket.limit.Burst}})
+		}
+	}
+
+	if len(takes) == 0 {
+		return reported, true
+	}
+
+	results, err := store.TakeAll(r.Context(), takes)
+	if err != nil {
+		// Failing open keeps payments flowing when a shared store is unavailable.
+		logger.Component(r.Context(), logger.ComponentHTTP).WithError(err).Warn("rate limit store unavailable")
+		return reported, true
+	}
+
+	for i := range results {
+		result := &results[i]
+		if !result.Allowed {
+			logger.Component(r.Context(), logger.ComponentHTTP).WithField("bucket", takes[i].Key).Info("rate limit exceeded")
+		}
+
+		if reported == nil || (reported.Allowed && (!result.Allowed || result.Remaining < reported.Remaining)) {
+			reported = result
+		}
+	}
+
+	header := w.Header()
+	header.Set(RateLimitLimitHeader, strconv.Itoa(reported.Limit))
+	header.Set(RateLimitRemainingHeader, strconv.Itoa(reported.Remaining))
+	header.Set(RateLimitResetHeader, strconv.Itoa(int(math.Ceil(reported.Reset.Seconds()))))
+
+	if !reported.Allowed {
+		header.Set(RetryAfterHeader, strconv.Itoa(int(math.Ceil(reported.RetryAfter.Seconds()))))
+		controllers.NewResponseBuilder(w).TooManyRequests().Build()
+		return reported, false
+	}
+
+	return reported, true
+}
+
+// watchRateLimits holds cfg and replaces it on every reload of rateLimit.
+func watchRateLimits(cfg configs.RateLimitConfig) *atomic.Pointer[rateLimits] {
+	var limits atomic.Pointer[rateLimits]
+	limits.Store(newRateLimits(cfg))
+
+	configs.Subscribe(func(change configs.Change) {
+		if change.Changed("rateLimit") {
+			limits.Store(newRateLimits(change.Current.RateLimit))
+		}
+	})
+
+	return &limits
+}
+
+func newRateLimits(cfg configs.RateLimitConfig) *rateLimits {
+	overrides := make(map[string]configs.MerchantRateLimit, len(cfg.MerchantOverrides))
+	for _, override := range cfg.MerchantOverrides {
+		overrides[override.MerchantID] = override
+	}
+
+	return &rateLimits{RateLimitConfig: cfg, overrides: overrides}
+}
+
+// clientIP returns the IP of the caller. RealIP has already replaced RemoteAddr
+// with the address forwarded by a trusted proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// peekTerminalID reads the terminal_id of a JSON body and restores the body for
+// the next handlers. Requests without a body have no terminal.
+func peekTerminalID(r *http.Request) (string, error) {
+	if r.Body == nil {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil || len(body) == 0 {
+		return "", err
+	}
+
+	var payload struct {
+		TerminalID string `json:"terminal_id"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", err
+	}
+
+	return payload.TerminalID, nil
+}
@@ -0,0 +1,243 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/ratelimit"
+)
+
+// fakeRateLimitStore answers with the results of results, allowed with a full
+// bucket when missing, and records the takes it was asked for.
+type fakeRateLimitStore struct {
+	mu      sync.Mutex
+	results map[string]ratelimit.Result
+	takes   []ratelimit.Take
+}
+
+func (s *fakeRateLimitStore) TakeAll(_ context.Context, takes []ratelimit.Take) ([]ratelimit.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.takes = append(s.takes, takes...)
+
+	results := make([]ratelimit.Result, len(takes))
+	for i, take := range takes {
+		result, ok := s.results[take.Key]
+		if !ok {
+			result = ratelimit.Result{Allowed: true, Limit: take.Limit.Burst, Remaining: take.Limit.Burst - 1}
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+var testRateLimits = configs.RateLimitConfig{
+	Enabled:  true,
+	Merchant: configs.RateLimit{Rate: 50, Burst: 100},
+	Terminal: configs.RateLimit{Rate: 5, Burst: 10},
+	IP:       configs.RateLimit{Rate: 20, Burst: 40},
+	MerchantOverrides: []configs.MerchantRateLimit{
+		{MerchantID: "M2", Merchant: configs.RateLimit{Rate: 500, Burst: 1000}},
+		{MerchantID: "M3", Terminal: configs.RateLimit{Rate: 1, Burst: 2}},
+	},
+}
+
+// newRateLimitedHandler chains both rate limit stages around an authentication
+// binding merchantID, as the payment routes do.
+func newRateLimitedHandler(cfg configs.RateLimitConfig, store ratelimit.Store, merchantID string) http.Handler {
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if merchantID != "" {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{MerchantID: merchantID, KeyID: "key"}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	return IPRateLimit(cfg, store)(authenticate(RateLimit(cfg, store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))))
+}
+
+func newPaymentRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/payments/sale", strings.NewReader(body))
+	req.RemoteAddr = "192.0.2.10:51000"
+	return req
+}
+
+func TestRateLimitOverrides(t *testing.T) {
+	tests := []struct {
+		name         string
+		merchantID   string
+		wantMerchant ratelimit.Limit
+		wantTerminal ratelimit.Limit
+	}{
+		{name: "defaults", merchantID: "M1", wantMerchant: ratelimit.Limit{Rate: 50, Burst: 100}, wantTerminal: ratelimit.Limit{Rate: 5, Burst: 10}},
+		{name: "merchant override", merchantID: "M2", wantMerchant: ratelimit.Limit{Rate: 500, Burst: 1000}, wantTerminal: ratelimit.Limit{Rate: 5, Burst: 10}},
+		{name: "terminal override", merchantID: "M3", wantMerchant: ratelimit.Limit{Rate: 50, Burst: 100}, wantTerminal: ratelimit.Limit{Rate: 1, Burst: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeRateLimitStore{}
+			rec := httptest.NewRecorder()
+			newRateLimitedHandler(testRateLimits, store, tt.merchantID).ServeHTTP(rec, newPaymentRequest(`{"terminal_id":"T1"}`))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			want := []ratelimit.Take{
+				{Key: "ip:192.0.2.10", Limit: ratelimit.Limit{Rate: 20, Burst: 40}},
+				{Key: "merchant:" + tt.merchantID, Limit: tt.wantMerchant},
+				{Key: "terminal:" + tt.merchantID + ":T1", Limit: tt.wantTerminal},
+			}
+			if !slices.Equal(store.takes, want) {
+				t.Fatalf("takes = %+v, want %+v", store.takes, want)
+			}
+		})
+	}
+}
+
+func TestRateLimitReportsTheMostConstrainedBucket(t *testing.T) {
+	allowed := func(limit, remaining int) ratelimit.Result {
+		return ratelimit.Result{Allowed: true, Limit: limit, Remaining: remaining, Reset: time.Second}
+	}
+	denied := func(limit int, retryAfter time.Duration) ratelimit.Result {
+		return ratelimit.Result{Limit: limit, Reset: 3 * time.Second, RetryAfter: retryAfter}
+	}
+
+	tests := []struct {
+		name           string
+		results        map[string]ratelimit.Result
+		wantStatus     int
+		wantLimit      string
+		wantRemaining  string
+		wantRetryAfter string
+	}{
+		{
+			name:          "merchant bucket lowest",
+			results:       map[string]ratelimit.Result{"ip:192.0.2.10": allowed(40, 30), "merchant:M1": allowed(100, 3), "terminal:M1:T1": allowed(10, 7)},
+			wantStatus:    http.StatusOK,
+			wantLimit:     "100",
+			wantRemaining: "3",
+		},
+		{
+			name:          "client ip bucket lowest",
+			results:       map[string]ratelimit.Result{"ip:192.0.2.10": allowed(40, 1), "merchant:M1": allowed(100, 3), "terminal:M1:T1": allowed(10, 7)},
+			wantStatus:    http.StatusOK,
+			wantLimit:     "40",
+			wantRemaining: "1",
+		},
+		{
+			name:           "terminal bucket exhausted",
+			results:        map[string]ratelimit.Result{"ip:192.0.2.10": allowed(40, 30), "merchant:M1": allowed(100, 0), "terminal:M1:T1": denied(10, 1500*time.Millisecond)},
+			wantStatus:     http.StatusTooManyRequests,
+			wantLimit:      "10",
+			wantRemaining:  "0",
+			wantRetryAfter: "2",
+		},
+		{
+			name:           "client ip bucket exhausted",
+			results:        map[string]ratelimit.Result{"ip:192.0.2.10": denied(40, 200*time.Millisecond)},
+			wantStatus:     http.StatusTooManyRequests,
+			wantLimit:      "40",
+			wantRemaining:  "0",
+			wantRetryAfter: "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newRateLimitedHandler(testRateLimits, &fakeRateLimitStore{results: tt.results}, "M1").ServeHTTP(rec, newPaymentRequest(`{"terminal_id":"T1"}`))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			header := rec.Header()
+			if header.Get(RateLimitLimitHeader) != tt.wantLimit || header.Get(RateLimitRemainingHeader) != tt.wantRemaining {
+				t.Fatalf("limit %q and remaining %q, want %q and %q", header.Get(RateLimitLimitHeader), header.Get(RateLimitRemainingHeader), tt.wantLimit, tt.wantRemaining)
+			}
+			if header.Get(RetryAfterHeader) != tt.wantRetryAfter {
+				t.Fatalf("Retry-After = %q, want %q", header.Get(RetryAfterHeader), tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestIPRateLimitRunsBeforeAuthentication(t *testing.T) {
+	cfg := configs.RateLimitConfig{Enabled: true, IP: configs.RateLimit{Rate: 0.5, Burst: 2}}
+
+	authenticated := 0
+	handler := IPRateLimit(cfg, ratelimit.NewMemoryStore(time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	statuses := []int{}
+	for range 3 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newPaymentRequest("{}"))
+		statuses = append(statuses, rec.Code)
+
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get(RetryAfterHeader) != "2" {
+			t.Fatalf("Retry-After = %q, want %q", rec.Header().Get(RetryAfterHeader), "2")
+		}
+	}
+
+	if want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}; !slices.Equal(statuses, want) {
+		t.Fatalf("statuses = %v, want %v", statuses, want)
+	}
+	if authenticated != 2 {
+		t.Fatalf("authenticated %d requests, want the 2 within the limit", authenticated)
+	}
+}
+
+func TestRateLimitTerminal(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantKeys   []string
+	}{
+		{name: "terminal in the body", method: http.MethodPost, body: `{"terminal_id":"T1"}`, wantStatus: http.StatusOK, wantKeys: []string{"ip:192.0.2.10", "merchant:M1", "terminal:M1:T1"}},
+		{name: "no terminal in the body", method: http.MethodPost, body: `{}`, wantStatus: http.StatusOK, wantKeys: []string{"ip:192.0.2.10", "merchant:M1"}},
+		{name: "no body", method: http.MethodGet, wantStatus: http.StatusOK, wantKeys: []string{"ip:192.0.2.10", "merchant:M1"}},
+		{name: "malformed body", method: http.MethodPost, body: `{"terminal_id":`, wantStatus: http.StatusBadRequest, wantKeys: []string{"ip:192.0.2.10"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/payments", strings.NewReader(tt.body))
+			req.RemoteAddr = "192.0.2.10:51000"
+
+			store := &fakeRateLimitStore{}
+			rec := httptest.NewRecorder()
+			newRateLimitedHandler(testRateLimits, store, "M1").ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			keys := []string{}
+			for _, take := range store.takes {
+				keys = append(keys, take.Key)
+			}
+			if !slices.Equal(keys, tt.wantKeys) {
+				t.Fatalf("buckets = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
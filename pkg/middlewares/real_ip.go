@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	forwardedForHeader = "X-Forwarded-For"
+	realIPHeader       = "X-Real-IP"
+)
+
+// RealIP replaces RemoteAddr with the client address forwarded by a trusted
+// proxy. X-Forwarded-For and X-Real-IP are only honoured when the connection
+// comes from one of the trustedProxies CIDRs; the client is the rightmost
+// X-Forwarded-For address that is not itself a trusted proxy. With no trusted
+// proxies the headers are ignored and RemoteAddr is kept.
+func RealIP(trustedProxies []string) func(http.Handler) http.Handler {
+	var networks []*net.IPNet
+	for _, cidr := range trustedProxies {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			networks = append(networks, network)
+		}
+	}
+
+	trusted := func(ip net.IP) bool {
+		for _, network := range networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(networks) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer := net.ParseIP(clientIP(r))
+			if peer == nil || !trusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if ip := forwardedClient(r.Header.Values(forwardedForHeader), trusted); ip != "" {
+				r.RemoteAddr = ip
+			} else if ip := net.ParseIP(strings.TrimSpace(r.Header.Get(realIPHeader))); ip != nil {
+				r.RemoteAddr = ip.String()
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient walks the X-Forwarded-For chain from the nearest hop and
+// returns the first address not belonging to a trusted proxy, or the farthest
+// one when every hop is trusted. Addresses before a malformed entry are not
+// trusted, since any hop could have written them.
+func forwardedClient(values []string, trusted func(net.IP) bool) string {
+	var hops []string
+	for _, value := range values {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+
+		client = ip.String()
+		if !trusted(ip) {
+			break
+		}
+	}
+
+	return client
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		peer           string
+		forwardedFor   []string
+		realIP         string
+		want           string
+	}{
+		{name: "no trusted proxies", peer: "10.0.0.1:4000", forwardedFor: []string{"198.51.100.7"}, want: "10.0.0.1:4000"},
+		{name: "untrusted peer", trustedProxies: []string{"10.0.0.0/8"}, peer: "192.0.2.10:4000", forwardedFor: []string{"198.51.100.7"}, realIP: "198.51.100.8", want: "192.0.2.10:4000"},
+		{name: "trusted peer", trustedProxies: []string{"10.0.0.0/8"}, peer: "10.0.0.1:4000", forwardedFor: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{
+			name:           "address spoofed before the client",
+			trustedProxies: []string{"10.0.0.0/8"},
+			peer:           "10.0.0.1:4000",
+			forwardedFor:   []string{"203.0.113.66, 198.51.100.7, 10.0.0.2"},
+			want:           "198.51.100.7",
+		},
+		{name: "chain split across headers", trustedProxies: []string{"10.0.0.0/8"}, peer: "10.0.0.1:4000", forwardedFor: []string{"198.51.100.7", "10.0.0.2"}, want: "198.51.100.7"},
+		{name: "every hop trusted", trustedProxies: []string{"10.0.0.0/8"}, peer: "10.0.0.1:4000", forwardedFor: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "malformed hop", trustedProxies: []string{"10.0.0.0/8"}, peer: "10.0.0.1:4000", forwardedFor: []string{"198.51.100.7, garbage, 10.0.0.2"}, want: "10.0.0.2"},
+		{name: "real ip header from a trusted peer", trustedProxies: []string{"10.0.0.0/8"}, peer: "10.0.0.1:4000", realIP: "198.51.100.8", want: "198.51.100.8"},
+		{name: "invalid real ip header", trustedProxies: []string{"10.0.0.0/8"}, peer: "10.0.0.1:4000", realIP: "garbage", want: "10.0.0.1:4000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			for _, value := range tt.forwardedFor {
+				req.Header.Add(forwardedForHeader, value)
+			}
+			if tt.realIP != "" {
+				req.Header.Set(realIPHeader, tt.realIP)
+			}
+
+			var got string
+			RealIP(tt.trustedProxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Fatalf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+// RequestLogger binds a request scoped field set, starting with the request
+// ID, to the request context and echoes the ID in the X-Request-Id response
+// header. It must run after middleware.RequestID.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := middleware.GetReqID(r.Context())
+		w.Header().Set(middleware.RequestIDHeader, requestID)
+
+		ctx := logger.WithFields(r.Context(), logrus.Fields{"request_id": requestID})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
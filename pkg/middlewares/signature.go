@@ -0,0 +1,155 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/logger"
+)
+
+const (
+	SigningModeDisabled = "disabled"
+	SigningModeOptional = "optional"
+	SigningModeRequired = "required"
+)
+
+type (
+	// signedResponseWriter buffers the response so the body can be signed before
+	// the headers are sent.
+	signedResponseWriter struct {
+		http.ResponseWriter
+		statusCode int
+		body       bytes.Buffer
+	}
+)
+
+// RequestSigning verifies the HMAC signature of authenticated requests and signs
+// their responses with the same scheme. It must run after APIKeyAuth, since the
+// signing key is derived from the authenticated API key.
+//
+// In optional mode only requests carrying a signature are verified and signed;
+// in required mode unsigned requests are rejected. Reloads of requestSigning
+// apply to the next requests. Like in NewAPIKeyService, pepper is the
+// apiKeys.pepper read at startup, which requires a restart to change.
+//
+// Requests to streamedPaths are verified but their responses are written through
+// unsigned, since signing would require buffering the whole body.
+func RequestSigning(cfg configs.RequestSigningConfig, pepper string, nonces auth.NonceStore, streamedPaths ...string) func(next http.Handler) http.Handler {
+	streamed := make(map[string]struct{}, len(streamedPaths))
+	for _, path := range streamedPaths {
+		streamed[path] = struct{}{}
+	}
+
+	var settings atomic.Pointer[configs.RequestSigningConfig]
+	settings.Store(&cfg)
+
+	configs.Subscribe(func(change configs.Change) {
+		if change.Changed("requestSigning") {
+			settings.Store(&change.Current.RequestSigning)
+		}
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := settings.Load()
+			if cfg.Mode == "" || cfg.Mode == SigningModeDisabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Principals authenticated by a client certificate have no signing
+			// secret; mutual TLS already authenticates and protects their requests.
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if ok && principal.KeyID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			signature := r.Header.Get(auth.SignatureHeader)
+			if signature == "" && cfg.Mode != SigningModeRequired {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !ok {
+				controllers.NewResponseBuilder(w).Unauthorized().ErrMessage("request signing requires api key authentication").Build()
+				return
+			}
+
+			timestamp := r.Header.Get(auth.SignatureTimestampHeader)
+			nonce := r.Header.Get(auth.SignatureNonceHeader)
+			if signature == "" || timestamp == "" || nonce == "" {
+				controllers.NewResponseBuilder(w).Unauthorized().ErrMessage("missing request signature").Build()
+				return
+			}
+
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				controllers.NewResponseBuilder(w).Unauthorized().ErrMessage("invalid signature timestamp").Build()
+				return
+			}
+
+			skew := time.Since(time.Unix(unix, 0))
+			if skew > cfg.ClockSkew || skew < -cfg.ClockSkew {
+				controllers.NewResponseBuilder(w).Unauthorized().ErrMessage("signature timestamp outside the allowed clock skew").Build()
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				controllers.NewResponseBuilder(w).UnformattedBody().Build()
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key := auth.DeriveSigningKey(pepper, principal.KeyID)
+			base := auth.SignatureBase(r.Method, r.URL.Path, r.URL.RawQuery, timestamp, nonce, body)
+			if !auth.VerifySignature(key, base, signature) {
+				logger.Component(r.Context(), logger.ComponentHTTP).Warn("invalid request signature")
+				controllers.NewResponseBuilder(w).Unauthorized().ErrMessage("invalid request signature").Build()
+				return
+			}
+
+			// A nonce only needs to be remembered while its timestamp is acceptable.
+			if !nonces.Use(principal.KeyID+":"+nonce, 2*cfg.ClockSkew) {
+				logger.Component(r.Context(), logger.ComponentHTTP).Warn("replayed request nonce")
+				controllers.NewResponseBuilder(w).Unauthorized().ErrMessage("nonce already used").Build()
+				return
+			}
+
+			if _, ok := streamed[r.URL.Path]; ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sw := &signedResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(sw, r)
+
+			respTimestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			respBase := auth.SignatureBase(r.Method, r.URL.Path, r.URL.RawQuery, respTimestamp, nonce, sw.body.Bytes())
+
+			header := w.Header()
+			header.Set(auth.SignatureTimestampHeader, respTimestamp)
+			header.Set(auth.SignatureNonceHeader, nonce)
+			header.Set(auth.SignatureHeader, auth.Sign(key, respBase))
+
+			w.WriteHeader(sw.statusCode)
+			w.Write(sw.body.Bytes())
+		})
+	}
+}
+
+func (w *signedResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+}
+
+func (w *signedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
@@ -0,0 +1,291 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/auth"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+)
+
+const (
+	testPepper = "pepper"
+	testKeyID  = "0123456789abcdef"
+	testSkew   = 5 * time.Minute
+)
+
+// signedRequest describes a request to path, /v1/payments/sale when empty,
+// signed by the holder of keyID. sentQuery and sentBody, when set, replace the
+// query and body after signing.
+type signedRequest struct {
+	path      string
+	keyID     string
+	query     string
+	body      string
+	timestamp time.Time
+	nonce     string
+	sentQuery *string
+	sentBody  *string
+}
+
+func (s signedRequest) build(authenticated bool) *http.Request {
+	path := s.path
+	if path == "" {
+		path = "/v1/payments/sale"
+	}
+
+	timestamp := strconv.FormatInt(s.timestamp.Unix(), 10)
+	key := auth.DeriveSigningKey(testPepper, s.keyID)
+	signature := auth.Sign(key, auth.SignatureBase(http.MethodPost, path, s.query, timestamp, s.nonce, []byte(s.body)))
+
+	query, body := s.query, s.body
+	if s.sentQuery != nil {
+		query = *s.sentQuery
+	}
+	if s.sentBody != nil {
+		body = *s.sentBody
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path+"?"+query, strings.NewReader(body))
+	req.Header.Set(auth.SignatureTimestampHeader, timestamp)
+	req.Header.Set(auth.SignatureNonceHeader, s.nonce)
+	req.Header.Set(auth.SignatureHeader, signature)
+
+	if authenticated {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{MerchantID: "M1", KeyID: testKeyID}))
+	}
+	return req
+}
+
+func newSigningHandler(mode string, nonces auth.NonceStore) http.Handler {
+	cfg := configs.RequestSigningConfig{Mode: mode, ClockSkew: testSkew}
+
+	return RequestSigning(cfg, testPepper, nonces, "/v1/payments/export")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"approved"}`))
+	}))
+}
+
+func TestRequestSigning(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	valid := signedRequest{
+		keyID:     testKeyID,
+		query:     "capture=true&installments=2",
+		body:      `{"amount":"000000001000"}`,
+		timestamp: time.Now(),
+		nonce:     "nonce",
+	}
+	with := func(change func(s *signedRequest)) signedRequest {
+		s := valid
+		change(&s)
+		return s
+	}
+
+	tests := []struct {
+		name       string
+		request    signedRequest
+		unsigned   bool
+		mode       string
+		anonymous  bool
+		wantStatus int
+	}{
+		{name: "valid", request: valid, wantStatus: http.StatusCreated},
+		{
+			name:       "timestamp within the skew window",
+			request:    with(func(s *signedRequest) { s.timestamp = time.Now().Add(-testSkew + time.Minute) }),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "timestamp before the skew window",
+			request:    with(func(s *signedRequest) { s.timestamp = time.Now().Add(-testSkew - time.Minute) }),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "timestamp after the skew window",
+			request:    with(func(s *signedRequest) { s.timestamp = time.Now().Add(testSkew + time.Minute) }),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "tampered body",
+			request:    with(func(s *signedRequest) { s.sentBody = ptr(`{"amount":"000000009000"}`) }),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "tampered query",
+			request:    with(func(s *signedRequest) { s.sentQuery = ptr("capture=true&installments=12") }),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "query parameter added",
+			request:    with(func(s *signedRequest) { s.sentQuery = ptr("capture=true&installments=2&debug=1") }),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "reordered query",
+			request:    with(func(s *signedRequest) { s.sentQuery = ptr("installments=2&capture=true") }),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "signed with another key",
+			request:    with(func(s *signedRequest) { s.keyID = "fedcba9876543210" }),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "missing nonce",
+			request:    with(func(s *signedRequest) { s.nonce = "" }),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{name: "unsigned in optional mode", request: valid, unsigned: true, wantStatus: http.StatusCreated},
+		{name: "unsigned in required mode", request: valid, unsigned: true, mode: SigningModeRequired, wantStatus: http.StatusUnauthorized},
+		{name: "signed but not authenticated with an api key", request: valid, anonymous: true, wantStatus: http.StatusUnauthorized},
+		{name: "disabled", request: with(func(s *signedRequest) { s.sentBody = ptr("tampered") }), mode: SigningModeDisabled, wantStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := tt.mode
+			if mode == "" {
+				mode = SigningModeOptional
+			}
+
+			req := tt.request.build(!tt.anonymous)
+			if tt.unsigned {
+				req.Header.Del(auth.SignatureHeader)
+			}
+
+			rec := httptest.NewRecorder()
+			newSigningHandler(mode, auth.NewMemoryNonceStore()).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			wantSigned := !tt.unsigned && mode != SigningModeDisabled && tt.wantStatus == http.StatusCreated
+			if signed := rec.Header().Get(auth.SignatureHeader) != ""; signed != wantSigned {
+				t.Fatalf("response signed = %t, want %t", signed, wantSigned)
+			}
+		})
+	}
+}
+
+func TestRequestSigningRejectsReplayedNonces(t *testing.T) {
+	handler := newSigningHandler(SigningModeRequired, auth.NewMemoryNonceStore())
+	request := signedRequest{keyID: testKeyID, body: "{}", timestamp: time.Now(), nonce: "nonce"}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, request.build(true))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("first request: status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, request.build(true))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed request: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	request.nonce = "other nonce"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, request.build(true))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("request with a new nonce: status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+}
+
+func TestRequestSigningSignsResponses(t *testing.T) {
+	request := signedRequest{keyID: testKeyID, query: "b=2&a=1", body: "{}", timestamp: time.Now(), nonce: "nonce"}
+	req := request.build(true)
+
+	rec := httptest.NewRecorder()
+	newSigningHandler(SigningModeRequired, auth.NewMemoryNonceStore()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if nonce := rec.Header().Get(auth.SignatureNonceHeader); nonce != request.nonce {
+		t.Fatalf("response nonce = %q, want the request one %q", nonce, request.nonce)
+	}
+
+	key := auth.DeriveSigningKey(testPepper, testKeyID)
+	base := auth.SignatureBase(http.MethodPost, "/v1/payments/sale", "a=1&b=2", rec.Header().Get(auth.SignatureTimestampHeader), request.nonce, rec.Body.Bytes())
+	if !auth.VerifySignature(key, base, rec.Header().Get(auth.SignatureHeader)) {
+		t.Fatal("response signature does not verify")
+	}
+}
+
+func TestRequestSigningSkipsClientCertificates(t *testing.T) {
+	handler := ClientCertAuth([]configs.ClientCertMerchant{{Subject: "terminal-1", MerchantID: "M1"}})(
+		newSigningHandler(SigningModeRequired, auth.NewMemoryNonceStore()),
+	)
+
+	tests := []struct {
+		name       string
+		subject    string
+		wantStatus int
+	}{
+		{name: "mapped certificate", subject: "terminal-1", wantStatus: http.StatusCreated},
+		{name: "certificate not mapped to a merchant", subject: "terminal-2", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/payments/sale", strings.NewReader("{}"))
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: tt.subject}}
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if signature := rec.Header().Get(auth.SignatureHeader); signature != "" {
+				t.Fatalf("response signed with %q, want it unsigned", signature)
+			}
+		})
+	}
+}
+
+func TestRequestSigningStreamedPaths(t *testing.T) {
+	handler := newSigningHandler(SigningModeRequired, auth.NewMemoryNonceStore())
+	tampered := "{\"tampered\":true}"
+
+	tests := []struct {
+		name       string
+		request    signedRequest
+		wantStatus int
+	}{
+		{
+			name:       "signed request",
+			request:    signedRequest{path: "/v1/payments/export", keyID: testKeyID, body: "{}", timestamp: time.Now(), nonce: "nonce-1"},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "request still verified",
+			request:    signedRequest{path: "/v1/payments/export", keyID: testKeyID, body: "{}", timestamp: time.Now(), nonce: "nonce-2", sentBody: &tampered},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tt.request.build(true))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if signature := rec.Header().Get(auth.SignatureHeader); signature != "" {
+				t.Fatalf("streamed response signed with %q, want it unsigned", signature)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package ratelimit implements token bucket rate limiting behind a Store
+// interface, so the in-memory backend can be replaced by a shared one when the
+// server runs with several replicas.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type (
+	// Limit describes a token bucket: Rate tokens are added per second up to Burst.
+	// A zero Rate disables the limit.
+	Limit struct {
+		Rate  float64
+		Burst int
+	}
+
+	// Take names the bucket a token is taken from and its limit.
+	Take struct {
+		Key   string
+		Limit Limit
+	}
+
+	// Result is the outcome of taking one token from a bucket.
+	Result struct {
+		Allowed    bool
+		Limit      int
+		Remaining  int
+		Reset      time.Duration // Time until the bucket is full again.
+		RetryAfter time.Duration // Time until a token is available, zero when allowed.
+	}
+
+	// Store keeps the buckets. Implementations must be safe for concurrent use.
+	Store interface {
+		// TakeAll takes one token from every bucket only when all of them have
+		// one, so a denied request does not consume the other limits. Results
+		// are in the order of takes.
+		TakeAll(ctx context.Context, takes []Take) ([]Result, error)
+	}
+
+	bucket struct {
+		tokens float64
+		last   time.Time
+	}
+
+	memoryStore struct {
+		mu        sync.Mutex
+		buckets   map[string]*bucket
+		idleTTL   time.Duration
+		nextSweep time.Time
+	}
+)
+
+// NewMemoryStore creates a process local Store. Buckets untouched for idleTTL
+// are full again and dropped to bound memory.
+func NewMemoryStore(idleTTL time.Duration) Store {
+	return &memoryStore{buckets: map[string]*bucket{}, idleTTL: idleTTL}
+}
+
+func (s *memoryStore) TakeAll(_ context.Context, takes []Take) ([]Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	buckets := make([]*bucket, len(takes))
+	allowed := true
+	for i, take := range takes {
+		b, ok := s.buckets[take.Key]
+		if !ok {
+			b = &bucket{tokens: float64(take.Limit.Burst), last: now}
+			s.buckets[take.Key] = b
+		}
+
+		b.tokens = math.Min(float64(take.Limit.Burst), b.tokens+now.Sub(b.last).Seconds()*take.Limit.Rate)
+		b.last = now
+
+		buckets[i] = b
+		allowed = allowed && b.tokens >= 1
+	}
+
+	results := make([]Result, len(takes))
+	for i, take := range takes {
+		b, limit := buckets[i], take.Limit
+
+		result := Result{Limit: limit.Burst, Allowed: b.tokens >= 1}
+		if allowed {
+			b.tokens--
+		} else if !result.Allowed {
+			result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+		}
+
+		result.Remaining = int(b.tokens)
+		result.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > s.idleTTL {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.nextSweep = now.Add(s.idleTTL)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTakeAll(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	wide := Take{Key: "merchant", Limit: Limit{Rate: 1, Burst: 3}}
+	narrow := Take{Key: "terminal", Limit: Limit{Rate: 0.5, Burst: 1}}
+
+	results, err := store.TakeAll(context.Background(), []Take{wide, narrow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Allowed || !results[1].Allowed || results[0].Remaining != 2 || results[1].Remaining != 0 {
+		t.Fatalf("first take: %+v, want both allowed with 2 and 0 remaining", results)
+	}
+
+	// The narrow bucket is empty, so no token is taken from the wide one.
+	for range 2 {
+		results, err = store.TakeAll(context.Background(), []Take{wide, narrow})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !results[0].Allowed || results[1].Allowed {
+			t.Fatalf("take with an empty bucket: %+v, want only the wide bucket allowed", results)
+		}
+		if results[0].Remaining != 2 {
+			t.Fatalf("wide bucket has %d tokens left, want 2", results[0].Remaining)
+		}
+		if retryAfter := results[1].RetryAfter; retryAfter <= time.Second || retryAfter > 2*time.Second {
+			t.Fatalf("retry after %s, want about 2s", retryAfter)
+		}
+	}
+
+	results, err = store.TakeAll(context.Background(), []Take{wide})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Allowed || results[0].Remaining != 1 {
+		t.Fatalf("take from the wide bucket alone: %+v, want allowed with 1 remaining", results[0])
+	}
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers/admin"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/middlewares"
+)
+
+func RegisterAdminRoutes(
+	r chi.Router,
+	adminToken configs.Secret,
+	apiKeys *admin.APIKeysController,
+	logLevel *admin.LogLevelController,
+) {
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(middlewares.AdminAuth(adminToken))
+
+		logrus.Debug("POST /admin/api-keys")
+		r.Post("/api-keys", apiKeys.Post)
+
+		logrus.Debug("GET /admin/api-keys")
+		r.Get("/api-keys", apiKeys.List)
+
+		logrus.Debug("DELETE /admin/api-keys/{id}")
+		r.Delete("/api-keys/{id}", apiKeys.Delete)
+
+		logrus.Debug("GET /admin/log-level")
+		r.Get("/log-level", logLevel.Get)
+
+		logrus.Debug("PUT /admin/log-level")
+		r.Put("/log-level", logLevel.Put)
+
+		logrus.Debug("DELETE /admin/log-level/{component}")
+		r.Delete("/log-level/{component}", logLevel.Delete)
+	})
+}
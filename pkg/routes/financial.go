@@ -1,10 +1,7 @@
 package routes
 
 import (
-	"net/http"
-
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
 	"github.com/sirupsen/logrus"
 	httpSwagger "github.com/swaggo/http-swagger"
 
@@ -12,29 +9,61 @@ import (
 )
 
 func RegisterFinancialRoutes(
-	r *chi.Mux,
+	r chi.Router,
+	guards chi.Middlewares,
 	auth *financial.AuthorizationController,
 	preAuth *financial.PreAuthorizationController,
+	incremental *financial.IncrementalAuthorizationController,
 	confirmation *financial.ConfirmationController,
 	cancellation *financial.CancellationController,
 	reversal *financial.ReversalController,
+	refund *financial.RefundController,
+	payments *financial.PaymentsController,
+	batches *financial.BatchesController,
 ) {
 	logrus.Debug("GET /swagger/*")
 	r.Mount("/swagger/", httpSwagger.WrapHandler)
-	r.Use(middleware.Heartbeat("/ping"))
 
-	logrus.Debug("POST /v1/payments/authorization")
-	r.Post("/v1/payments/authorization", auth.Post)
+	r.Group(func(r chi.Router) {
+		r.Use(guards...)
+
+		logrus.Debug("POST /v1/payments/authorization")
+		r.Post("/v1/payments/authorization", auth.Post)
+
+		logrus.Debug("POST /v1/payments/pre_authorization")
+		r.Post("/v1/payments/pre_authorization", preAuth.Post)
+
+		logrus.Debug("POST /v1/payments/incremental_authorization")
+		r.Post("/v1/payments/incremental_authorization", incremental.Post)
+
+		logrus.Debug("POST /v1/payments/confirmation")
+		r.Post("/v1/payments/confirmation", confirmation.Post)
+
+		logrus.Debug("POST /v1/payments/cancellation")
+		r.Post("/v1/payments/cancellation", cancellation.Post)
+
+		logrus.Debug("POST /v1/payments/reversal")
+		r.Post("/v1/payments/reversal", reversal.Post)
+
+		logrus.Debug("POST /v1/payments/refund")
+		r.Post("/v1/payments/refund", refund.Post)
+
+		logrus.Debug("GET /v1/payments")
+		r.Get("/v1/payments", payments.List)
+
+		logrus.Debug("GET /v1/payments/export")
+		r.Get("/v1/payments/export", payments.Export)
 
-	logrus.Debug("POST /v1/payments/pre_authorization")
-	r.Post("/v1/payments/pre_authorization", preAuth.Post)
+		logrus.Debug("GET /v1/payments/lookup")
+		r.Get("/v1/payments/lookup", payments.Lookup)
 
-	logrus.Debug("POST /v1/payments/confirmation")
-	r.Post("/v1/payments/confirmation", confirmation.Post)
+		logrus.Debug("GET /v1/payments/{id}")
+		r.Get("/v1/payments/{id}", payments.Get)
 
-	logrus.Debug("POST /v1/payments/cancellation")
-	r.Post("/v1/payments/cancellation", cancellation.Post)
+		logrus.Debug("POST /v1/batches/close")
+		r.Post("/v1/batches/close", batches.Close)
 
-	logrus.Debug("POST /v1/payments/reversal")
-	r.Post("/v1/payments/reversal", reversal.Post)
+		logrus.Debug("GET /v1/batches")
+		r.Get("/v1/batches", batches.List)
+	})
 }
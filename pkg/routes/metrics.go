@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/metrics"
+)
+
+func RegisterMetricsRoutes(r chi.Router) {
+	logrus.Debug("GET /metrics")
+	r.Method("GET", "/metrics", metrics.Handler())
+}
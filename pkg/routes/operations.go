@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/sirupsen/logrus"
+
+	"githib.com/ralvescosta/go-simple-http-server/pkg/configs"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/controllers/admin"
+	"githib.com/ralvescosta/go-simple-http-server/pkg/middlewares"
+)
+
+// RegisterOperationsRoutes registers the probes and build information, left
+// unauthenticated for the orchestrator, and the profiler and configuration dump
+// behind the admin token. They belong to the admin listener only.
+func RegisterOperationsRoutes(
+	r chi.Router,
+	adminToken configs.Secret,
+	operations *admin.OperationsController,
+) {
+	logrus.Debug("GET /livez")
+	r.Get("/livez", operations.Livez)
+
+	logrus.Debug("GET /readyz")
+	r.Get("/readyz", operations.Readyz)
+
+	logrus.Debug("GET /buildinfo")
+	r.Get("/buildinfo", operations.BuildInfo)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.AdminAuth(adminToken))
+
+		logrus.Debug("GET /config")
+		r.Get("/config", operations.Config)
+
+		logrus.Debug("GET /debug/pprof/*")
+		r.Mount("/debug", middleware.Profiler())
+	})
+}
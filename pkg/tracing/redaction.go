@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"regexp"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// panPattern matches card numbers anywhere in a string value, including inside
+// Track 2 data, so they are masked even under unexpected attribute keys.
+var panPattern = regexp.MustCompile(`\d{13,19}`)
+
+type (
+	// redactingExporter rewrites the attributes of every span before handing it
+	// to the real exporter.
+	redactingExporter struct {
+		sdktrace.SpanExporter
+		keys []string
+	}
+
+	redactedSpan struct {
+		sdktrace.ReadOnlySpan
+		attrs []attribute.KeyValue
+	}
+)
+
+func newRedactingExporter(exporter sdktrace.SpanExporter, keys []string) sdktrace.SpanExporter {
+	return &redactingExporter{exporter, keys}
+}
+
+func (e *redactingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	redactedSpans := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, span := range spans {
+		redactedSpans[i] = &redactedSpan{span, e.redact(span.Attributes())}
+	}
+
+	return e.SpanExporter.ExportSpans(ctx, redactedSpans)
+}
+
+func (e *redactingExporter) redact(attrs []attribute.KeyValue) []attribute.KeyValue {
+	result := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		switch {
+		case slices.Contains(e.keys, string(attr.Key)):
+			result[i] = attr.Key.String(redacted)
+		case attr.Value.Type() == attribute.STRING:
+			result[i] = attr.Key.String(MaskPAN(attr.Value.AsString()))
+		default:
+			result[i] = attr
+		}
+	}
+
+	return result
+}
+
+func (s *redactedSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
+
+// MaskPAN keeps the BIN and the last four digits of every card number in value.
+func MaskPAN(value string) string {
+	return panPattern.ReplaceAllStringFunc(value, func(pan string) string {
+		masked := []byte(pan)
+		for i := 6; i < len(masked)-4; i++ {
+			masked[i] = '*'
+		}
+		return string(masked)
+	})
+}